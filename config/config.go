@@ -1,20 +1,35 @@
 package config
 
 import (
+	"strings"
+	"time"
+
 	"github.com/jinzhu/configor"
 )
 
 type Config struct {
-	AppConfig    AppConfig
-	DBConfig     DBConfig
-	PulsarConfig PulsarConfig
-	KafkaConfig  KafkaConfig
+	AppConfig      AppConfig
+	DBConfig       DBConfig
+	PulsarConfig   PulsarConfig
+	KafkaConfig    KafkaConfig
+	SyncConfig     SyncConfig
+	ConsumerConfig ConsumerConfig
+	BatchConfig    BatchConfig
+	OutboxConfig   OutboxConfig
+	RetryConfig    RetryConfig
+	TracingConfig  TracingConfig
 }
 
 type AppConfig struct {
 	APPName string `default:"anime-api"`
 	Port    int    `env:"PORT" default:"3000"`
 	Version string `default:"x.x.x"`
+	// ShutdownTimeoutMs is how long in-flight messages get to finish after SIGTERM before the serve commands give up
+	ShutdownTimeoutMs int `default:"30000" env:"SHUTDOWN_TIMEOUT_MS"`
+}
+
+func (c AppConfig) ShutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeoutMs) * time.Millisecond
 }
 
 type DBConfig struct {
@@ -32,6 +47,27 @@ type PulsarConfig struct {
 	SubscribtionName     string `default:"my-sub" env:"PULSARSUBSCRIPTIONNAME"`
 	ProducerAlgoliaTopic string `default:"public/default/myanimelist.public.anime-algolia" env:"PULSARALGOLIATOPIC"`
 	ProducerImageTopic   string `default:"public/default/myanimelist.public.anime-image" env:"PULSARIMAGETOPIC"`
+	// ProducerDisableBatching sends every message on its own instead of batching the messages sent within ProducerBatchDelayMs
+	ProducerDisableBatching  bool `default:"false" env:"PULSAR_PRODUCER_DISABLE_BATCHING"`
+	ProducerBatchMaxMessages uint `default:"1000" env:"PULSAR_PRODUCER_BATCH_MAX_MESSAGES"`
+	ProducerBatchDelayMs     int  `default:"10" env:"PULSAR_PRODUCER_BATCH_DELAY_MS"`
+	// ProducerCompression is one of none, lz4, zlib or zstd
+	ProducerCompression   string `default:"lz4" env:"PULSAR_PRODUCER_COMPRESSION"`
+	ProducerSendTimeoutMs int    `default:"30000" env:"PULSAR_PRODUCER_SEND_TIMEOUT_MS"`
+	// NackRedeliveryDelayMs is how long Pulsar waits before redelivering a message that failed
+	NackRedeliveryDelayMs int `default:"10000" env:"PULSAR_NACK_REDELIVERY_DELAY_MS"`
+}
+
+func (c PulsarConfig) ProducerBatchDelay() time.Duration {
+	return time.Duration(c.ProducerBatchDelayMs) * time.Millisecond
+}
+
+func (c PulsarConfig) ProducerSendTimeout() time.Duration {
+	return time.Duration(c.ProducerSendTimeoutMs) * time.Millisecond
+}
+
+func (c PulsarConfig) NackRedeliveryDelay() time.Duration {
+	return time.Duration(c.NackRedeliveryDelayMs) * time.Millisecond
 }
 
 type KafkaConfig struct {
@@ -41,6 +77,162 @@ type KafkaConfig struct {
 	Topic             string `default:"anime-db.public.anime" env:"KAFKA_TOPIC"`
 	ProducerTopic     string `default:"image-sync" env:"KAFKA_PRODUCER_TOPIC"`
 	AlgoliaTopic      string `default:"algolia-sync" env:"KAFKA_ALGOLIA_TOPIC"`
+	// Topics is a comma separated list of topics consumed by serve-all-kafka, defaults to Topic
+	Topics string `env:"KAFKA_TOPICS"`
+	// TopicTables maps topics to source tables ("topic=table,topic=table") for messages without source.table
+	TopicTables string `env:"KAFKA_TOPIC_TABLES"`
+	// ClientID identifies this instance in the consumer group for readiness and lag, defaults to the hostname
+	ClientID string `env:"KAFKA_CLIENT_ID"`
+}
+
+type SyncConfig struct {
+	MaintainInverseRelations bool `default:"false" env:"SYNC_MAINTAIN_INVERSE_RELATIONS"`
+	// ForceReplay applies anime, episode and season events older than the last applied one, for backfills
+	ForceReplay bool `default:"false" env:"SYNC_FORCE_REPLAY"`
+}
+
+// ConsumerConfig controls how the serve commands spread messages over workers
+type ConsumerConfig struct {
+	// Workers process messages of different keys concurrently, messages with the same key stay in
+	// order. The serve commands override it with --workers. With BATCH_ENABLED the anime and episode
+	// commands apply their messages one partition at a time and ignore it
+	Workers int `default:"1" env:"CONSUMER_WORKERS"`
+}
+
+// BatchConfig controls how snapshot events of anime and episodes are applied. With batching enabled
+// they are collected per partition and written with multi row upserts, live changes are still applied one by one
+type BatchConfig struct {
+	Enabled bool `default:"false" env:"BATCH_ENABLED"`
+	// Size is how many snapshot events of a partition are applied together
+	Size int `default:"500" env:"BATCH_SIZE"`
+	// WaitMs is how long a partition collects events before an incomplete batch is applied
+	WaitMs int `default:"1000" env:"BATCH_WAIT_MS"`
+}
+
+func (c BatchConfig) Wait() time.Duration {
+	return time.Duration(c.WaitMs) * time.Millisecond
+}
+
+type OutboxConfig struct {
+	// Enabled writes algolia and image messages to the outbox table in the entity transaction instead of producing them directly
+	Enabled bool `default:"false" env:"OUTBOX_ENABLED"`
+	// ExternalRelay disables the relay started by the serve commands, use it when serve-outbox-relay runs on its own.
+	// Several relays take turns on the pending rows, they don't publish in parallel
+	ExternalRelay  bool `default:"false" env:"OUTBOX_EXTERNAL_RELAY"`
+	BatchSize      int  `default:"100" env:"OUTBOX_BATCH_SIZE"`
+	PollIntervalMs int  `default:"1000" env:"OUTBOX_POLL_INTERVAL_MS"`
+	// RetentionHours is how long sent messages are kept before the relay deletes them
+	RetentionHours int `default:"24" env:"OUTBOX_RETENTION_HOURS"`
+}
+
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp. otlp is set up with the standard OTEL_EXPORTER_OTLP_* variables
+	Exporter    string `default:"none" env:"TRACING_EXPORTER"`
+	ServiceName string `default:"anime-sync" env:"TRACING_SERVICE_NAME"`
+	// SampleRatio is the share of new traces recorded, traces started upstream follow the upstream decision
+	SampleRatio float64 `default:"1" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// RetryConfig is the retry policy shared by all Kafka processors. Each entity can override single
+// fields through its own policy, configor names those variables after the struct path, e.g.
+// CONFIGOR_RETRYCONFIG_ANIME_MAXRETRIES or CONFIGOR_RETRYCONFIG_CHARACTERSTAFFLINK_MAXINTERVALMS
+type RetryConfig struct {
+	MaxRetries int `default:"3" env:"RETRY_MAX_RETRIES"`
+	// intervals and multiplier left at 0 use the exponential backoff defaults
+	InitialIntervalMs int     `env:"RETRY_INITIAL_INTERVAL_MS"`
+	MaxIntervalMs     int     `env:"RETRY_MAX_INTERVAL_MS"`
+	Multiplier        float64 `env:"RETRY_MULTIPLIER"`
+	// TopicSuffix and DLQTopicSuffix are appended to the consumed topic for the retry and dead letter topics
+	TopicSuffix    string `default:"-retry" env:"RETRY_TOPIC_SUFFIX"`
+	DLQTopicSuffix string `default:"-dlq" env:"RETRY_DLQ_TOPIC_SUFFIX"`
+
+	Anime              RetryPolicy
+	Episode            RetryPolicy
+	Season             RetryPolicy
+	Character          RetryPolicy
+	Staff              RetryPolicy
+	CharacterStaffLink RetryPolicy
+	Relation           RetryPolicy
+}
+
+// RetryPolicy is the retry policy of one processor, zero fields fall back to the RetryConfig defaults
+type RetryPolicy struct {
+	MaxRetries        int
+	InitialIntervalMs int
+	MaxIntervalMs     int
+	Multiplier        float64
+	TopicSuffix       string
+	DLQTopicSuffix    string
+	// DLQTopic replaces the consumed topic + DLQTopicSuffix as dead letter topic
+	DLQTopic string
+}
+
+// Resolve fills the zero fields of an entity policy with the shared defaults
+func (c RetryConfig) Resolve(policy RetryPolicy) RetryPolicy {
+	return policy.Or(RetryPolicy{
+		MaxRetries:        c.MaxRetries,
+		InitialIntervalMs: c.InitialIntervalMs,
+		MaxIntervalMs:     c.MaxIntervalMs,
+		Multiplier:        c.Multiplier,
+		TopicSuffix:       c.TopicSuffix,
+		DLQTopicSuffix:    c.DLQTopicSuffix,
+	})
+}
+
+// Or returns the policy with its zero fields taken from fallback
+func (p RetryPolicy) Or(fallback RetryPolicy) RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = fallback.MaxRetries
+	}
+	if p.InitialIntervalMs == 0 {
+		p.InitialIntervalMs = fallback.InitialIntervalMs
+	}
+	if p.MaxIntervalMs == 0 {
+		p.MaxIntervalMs = fallback.MaxIntervalMs
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = fallback.Multiplier
+	}
+	if p.TopicSuffix == "" {
+		p.TopicSuffix = fallback.TopicSuffix
+	}
+	if p.DLQTopicSuffix == "" {
+		p.DLQTopicSuffix = fallback.DLQTopicSuffix
+	}
+	if p.DLQTopic == "" {
+		p.DLQTopic = fallback.DLQTopic
+	}
+	return p
+}
+
+// RetryTopic returns the topic failed messages of topic are re-queued on. Retry topics map onto
+// themselves so consuming one does not create a retry topic of the retry topic
+func (p RetryPolicy) RetryTopic(topic string) string {
+	return p.SourceTopic(topic) + p.TopicSuffix
+}
+
+// SourceTopic strips the retry suffix from a consumed topic
+func (p RetryPolicy) SourceTopic(topic string) string {
+	if p.TopicSuffix == "" {
+		return topic
+	}
+	return strings.TrimSuffix(topic, p.TopicSuffix)
+}
+
+// DeadLetterTopic returns the topic messages of topic are dead lettered on
+func (p RetryPolicy) DeadLetterTopic(topic string) string {
+	if p.DLQTopic != "" {
+		return p.DLQTopic
+	}
+	return p.SourceTopic(topic) + p.DLQTopicSuffix
+}
+
+func (p RetryPolicy) InitialInterval() time.Duration {
+	return time.Duration(p.InitialIntervalMs) * time.Millisecond
+}
+
+func (p RetryPolicy) MaxInterval() time.Duration {
+	return time.Duration(p.MaxIntervalMs) * time.Millisecond
 }
 
 func LoadConfigOrPanic() Config {
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	retryConfig := RetryConfig{
+		MaxRetries:     3,
+		TopicSuffix:    "-retry",
+		DLQTopicSuffix: "-dlq",
+		Anime:          RetryPolicy{MaxRetries: 5, DLQTopic: "anime-failures"},
+	}
+
+	t.Run("FallsBackToDefaults", func(t *testing.T) {
+		policy := retryConfig.Resolve(retryConfig.Episode)
+		assert.Equal(t, 3, policy.MaxRetries)
+		assert.Equal(t, "episodes-retry", policy.RetryTopic("episodes"))
+		assert.Equal(t, "episodes-retry", policy.RetryTopic("episodes-retry"))
+		assert.Equal(t, "episodes-dlq", policy.DeadLetterTopic("episodes-retry"))
+	})
+
+	t.Run("EntityOverridesWin", func(t *testing.T) {
+		policy := retryConfig.Resolve(retryConfig.Anime)
+		assert.Equal(t, 5, policy.MaxRetries)
+		assert.Equal(t, "anime-failures", policy.DeadLetterTopic("anime"))
+	})
+
+	t.Run("LoadsEntityOverridesFromEnv", func(t *testing.T) {
+		t.Setenv("RETRY_MAX_RETRIES", "4")
+		t.Setenv("CONFIGOR_RETRYCONFIG_ANIME_MAXRETRIES", "7")
+		t.Setenv("CONFIGOR_RETRYCONFIG_STAFF_TOPICSUFFIX", "-staff-retry")
+
+		cfg := LoadConfigOrPanic()
+		assert.Equal(t, 4, cfg.RetryConfig.Resolve(cfg.RetryConfig.Episode).MaxRetries)
+		assert.Equal(t, 7, cfg.RetryConfig.Resolve(cfg.RetryConfig.Anime).MaxRetries)
+		assert.Equal(t, "staff-staff-retry", cfg.RetryConfig.Resolve(cfg.RetryConfig.Staff).RetryTopic("staff"))
+		assert.Equal(t, "-dlq", cfg.RetryConfig.DLQTopicSuffix)
+	})
+}
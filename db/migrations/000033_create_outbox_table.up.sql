@@ -0,0 +1,16 @@
+-- Create outbox table, rows are written in the same transaction as the entity change
+-- and published to kafka by the outbox relay
+CREATE TABLE outbox
+(
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    topic        VARCHAR(255) NOT NULL,
+    message_key  VARBINARY(255) NULL,
+    payload      LONGBLOB     NOT NULL,
+    attempts     INT          NOT NULL DEFAULT 0,
+    last_error   TEXT         NULL,
+    created_at   TIMESTAMP    DEFAULT CURRENT_TIMESTAMP,
+    sent_at      TIMESTAMP    NULL,
+    -- set once kafka rejected the row for good, the relay skips it so it doesn't block the rows behind it
+    dead_at      TIMESTAMP    NULL,
+    INDEX idx_outbox_pending (sent_at, dead_at, id)
+);
@@ -0,0 +1,2 @@
+-- Drop sync_positions table
+DROP TABLE IF EXISTS sync_positions;
@@ -0,0 +1,12 @@
+-- Create sync_positions table, holds the debezium source position of the last event applied to a row
+-- so replayed or reordered older events can be skipped
+CREATE TABLE sync_positions
+(
+    entity     VARCHAR(64) NOT NULL,
+    entity_id  VARCHAR(36) NOT NULL,
+    lsn        BIGINT      NOT NULL DEFAULT 0,
+    ts_ms      BIGINT      NOT NULL DEFAULT 0,
+    tx_id      BIGINT      NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity, entity_id)
+);
@@ -0,0 +1,2 @@
+-- Drop dlq_resolutions table
+DROP TABLE IF EXISTS dlq_resolutions;
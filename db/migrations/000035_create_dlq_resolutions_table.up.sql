@@ -0,0 +1,10 @@
+-- Create dlq_resolutions table, records which dead lettered kafka messages were replayed or discarded
+CREATE TABLE dlq_resolutions
+(
+    topic           VARCHAR(255) NOT NULL,
+    kafka_partition INT          NOT NULL,
+    kafka_offset    BIGINT       NOT NULL,
+    action          VARCHAR(16)  NOT NULL,
+    resolved_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (topic, kafka_partition, kafka_offset)
+);
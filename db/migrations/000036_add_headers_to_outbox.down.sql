@@ -0,0 +1,2 @@
+ALTER TABLE outbox
+    DROP COLUMN headers;
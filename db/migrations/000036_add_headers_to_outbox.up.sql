@@ -0,0 +1,3 @@
+-- Kafka headers of outbox messages, carries the trace context from the entity change to the relayed message
+ALTER TABLE outbox
+    ADD COLUMN headers JSON NULL AFTER payload;
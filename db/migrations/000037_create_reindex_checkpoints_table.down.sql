@@ -0,0 +1,2 @@
+-- Drop reindex_checkpoints table
+DROP TABLE IF EXISTS reindex_checkpoints;
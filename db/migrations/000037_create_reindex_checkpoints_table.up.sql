@@ -0,0 +1,11 @@
+-- Create reindex_checkpoints table, records how far a reindex run got per table so it can be resumed
+CREATE TABLE reindex_checkpoints
+(
+    name         VARCHAR(255) NOT NULL,
+    table_name   VARCHAR(64)  NOT NULL,
+    last_id      VARCHAR(36)  NOT NULL DEFAULT '',
+    published    BIGINT       NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP    NULL,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (name, table_name)
+);
@@ -0,0 +1,2 @@
+-- Drop anime_title_history table
+DROP TABLE IF EXISTS anime_title_history;
@@ -0,0 +1,12 @@
+-- Create anime_title_history table, records every change of an anime title so search keeps matching previous names
+CREATE TABLE anime_title_history
+(
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    anime_id   VARCHAR(36) NOT NULL,
+    field      VARCHAR(32) NOT NULL,
+    old_title  TEXT        NULL,
+    new_title  TEXT        NULL,
+    changed_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (anime_id) REFERENCES anime (id) ON DELETE CASCADE,
+    INDEX idx_anime_title_history_anime_id (anime_id)
+);
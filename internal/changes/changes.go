@@ -0,0 +1,79 @@
+package changes
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Set holds the json names of the fields that differ between two versions of a row
+type Set map[string]bool
+
+// Diff compares the exported fields of before and after and returns the json names of the fields
+// whose values differ. Pointer fields are compared by the values they point to, so a field that is
+// nil on one side and set on the other is changed. Fields named in ignore are never reported
+func Diff[T any](before *T, after *T, ignore ...string) Set {
+	ignored := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+
+	set := Set{}
+	b := reflect.ValueOf(before).Elem()
+	a := reflect.ValueOf(after).Elem()
+	for i := 0; i < b.NumField(); i++ {
+		field := b.Type().Field(i)
+		name := fieldName(field)
+		if !field.IsExported() || name == "" || ignored[name] {
+			continue
+		}
+		if !reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+// fieldName is the json name of field, empty for fields left out of json
+func fieldName(field reflect.StructField) string {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// Has reports whether any of fields changed
+func (s Set) Has(fields ...string) bool {
+	for _, field := range fields {
+		if s[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty reports whether no field changed
+func (s Set) Empty() bool {
+	return len(s) == 0
+}
+
+// Columns returns the changed fields, sorted, leaving out except, e.g. fields that are not stored
+func (s Set) Columns(except ...string) []string {
+	columns := make([]string, 0, len(s))
+	for field := range s {
+		if !slices.Contains(except, field) {
+			columns = append(columns, field)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
@@ -0,0 +1,55 @@
+package changes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type row struct {
+	ID        string     `json:"id"`
+	Title     *string    `json:"title"`
+	Episodes  *int       `json:"episodes"`
+	Aired     *time.Time `json:"aired"`
+	UpdatedAt *int64     `json:"updated_at"`
+	Tags      []string   `json:"tags,omitempty"`
+	Internal  string     `json:"-"`
+	Untagged  string
+	hidden    string
+}
+
+func TestDiff(t *testing.T) {
+	str := func(value string) *string { return &value }
+	num := func(value int) *int { return &value }
+	ts := func(value int64) *int64 { return &value }
+	aired := time.Date(1998, 4, 3, 0, 0, 0, 0, time.UTC)
+	airedCopy := aired
+
+	before := &row{ID: "a1", Title: str("Cowboy Bebop"), Episodes: num(26), Aired: &aired, UpdatedAt: ts(1), Tags: []string{"Space"}, Internal: "x", hidden: "x"}
+
+	t.Run("EqualValuesBehindDifferentPointers", func(t *testing.T) {
+		after := &row{ID: "a1", Title: str("Cowboy Bebop"), Episodes: num(26), Aired: &airedCopy, UpdatedAt: ts(1), Tags: []string{"Space"}}
+		assert.True(t, Diff(before, after).Empty())
+	})
+
+	t.Run("ChangedFields", func(t *testing.T) {
+		after := &row{ID: "a1", Title: str("Cowboy Bebop: The Movie"), Episodes: nil, Aired: &aired, UpdatedAt: ts(2), Tags: []string{"Space"}, Untagged: "y"}
+		set := Diff(before, after)
+		assert.Equal(t, Set{"title": true, "episodes": true, "updated_at": true, "Untagged": true}, set)
+		assert.True(t, set.Has("aired", "episodes"))
+		assert.False(t, set.Has("aired", "tags"))
+	})
+
+	t.Run("IgnoredFields", func(t *testing.T) {
+		after := *before
+		after.UpdatedAt = ts(2)
+		assert.True(t, Diff(before, &after, "updated_at").Empty())
+	})
+}
+
+func TestSetColumns(t *testing.T) {
+	set := Set{"title": true, "season": true, "episodes": true}
+	assert.Equal(t, []string{"episodes", "title"}, set.Columns("season"))
+	assert.Empty(t, Set{"season": true}.Columns("season"))
+}
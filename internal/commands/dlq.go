@@ -0,0 +1,35 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// dlqTopic is the dead letter topic the dlq subcommands work on, empty means KAFKA_TOPIC + RETRY_DLQ_TOPIC_SUFFIX
+var dlqTopic string
+
+// dlqCmd represents the dlq command
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect, replay or discard dead lettered messages",
+	Long: `Messages that still fail after their last retry are published to the
+dead letter topic of their source topic (<topic>-dlq by default) with the
+error, retry count, processor name and source topic in their headers. The dlq
+subcommands list those messages and replay them onto the source topic or
+discard them. Replayed and discarded messages are recorded in the
+dlq_resolutions table.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// error need to call subcommand
+		return fmt.Errorf("please call subcommand")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dlqCmd)
+
+	dlqCmd.PersistentFlags().StringVar(&dlqTopic, "topic", "", "dead letter topic, defaults to KAFKA_TOPIC + RETRY_DLQ_TOPIC_SUFFIX")
+}
@@ -0,0 +1,29 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+)
+
+var dlqDiscardAll bool
+
+// dlqDiscardCmd represents the dlq discard command
+var dlqDiscardCmd = &cobra.Command{
+	Use:   "discard [partition:offset...]",
+	Short: "Discard dead lettered messages",
+	Long: `Marks the given messages, or every pending one with --all, as discarded
+so they no longer show up in dlq list. The messages stay in the topic until
+its retention removes them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return eventing.DLQDiscard(cmd.OutOrStdout(), dlqTopic, args, dlqDiscardAll)
+	},
+}
+
+func init() {
+	dlqCmd.AddCommand(dlqDiscardCmd)
+
+	dlqDiscardCmd.Flags().BoolVar(&dlqDiscardAll, "all", false, "discard every pending message")
+}
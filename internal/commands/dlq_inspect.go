@@ -0,0 +1,24 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+)
+
+// dlqInspectCmd represents the dlq inspect command
+var dlqInspectCmd = &cobra.Command{
+	Use:   "inspect <partition:offset>",
+	Short: "Show a dead lettered message",
+	Long:  `Prints the headers, error and original payload of a dead lettered message.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return eventing.DLQInspect(cmd.OutOrStdout(), dlqTopic, args[0])
+	},
+}
+
+func init() {
+	dlqCmd.AddCommand(dlqInspectCmd)
+}
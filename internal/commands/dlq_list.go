@@ -0,0 +1,29 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+)
+
+var dlqListAll bool
+
+// dlqListCmd represents the dlq list command
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List dead lettered messages",
+	Long: `Lists the messages of the dead letter topic that were not replayed or
+discarded yet, with their partition:offset reference, processor, retry count
+and error. Use --all to include resolved messages.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return eventing.DLQList(cmd.OutOrStdout(), dlqTopic, dlqListAll)
+	},
+}
+
+func init() {
+	dlqCmd.AddCommand(dlqListCmd)
+
+	dlqListCmd.Flags().BoolVar(&dlqListAll, "all", false, "include replayed and discarded messages")
+}
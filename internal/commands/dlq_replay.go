@@ -0,0 +1,29 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+)
+
+var dlqReplayAll bool
+
+// dlqReplayCmd represents the dlq replay command
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay [partition:offset...]",
+	Short: "Replay dead lettered messages onto their source topic",
+	Long: `Produces the given messages, or every pending one with --all, back onto
+their source topic. The dlq and retry headers are dropped so the message gets
+its full retry budget again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return eventing.DLQReplay(cmd.OutOrStdout(), dlqTopic, args, dlqReplayAll)
+	},
+}
+
+func init() {
+	dlqCmd.AddCommand(dlqReplayCmd)
+
+	dlqReplayCmd.Flags().BoolVar(&dlqReplayAll, "all", false, "replay every pending message")
+}
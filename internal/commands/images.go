@@ -0,0 +1,27 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// imagesCmd represents the images command
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Manage the image sync requests sent to the image topic",
+	Long: `The sync processors send an image sync request to KAFKA_PRODUCER_TOPIC
+when an anime, character or staff member with an image is created or updated.
+The images subcommands send those requests for rows that never got one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// error need to call subcommand
+		return fmt.Errorf("please call subcommand")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+}
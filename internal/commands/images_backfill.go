@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"github.com/weeb-vip/anime-sync/internal/services/image_backfill"
+)
+
+var (
+	imagesBackfillOptions      image_backfill.Options
+	imagesBackfillUpdatedSince string
+)
+
+// imagesBackfillCmd represents the images backfill command
+var imagesBackfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Send an image sync request for every anime, character and staff member with an image",
+	Long: `Scans the anime, anime_character and anime_staff tables and sends an
+image sync request to KAFKA_PRODUCER_TOPIC for every row with an image url,
+named the way the processors name them. Use it for rows inserted before image
+sync existed or whose images failed. Requests carry the name images were
+stored under before names were slugged, a full backfill moves every stored
+image to its new name. --dry-run prints the requests without sending them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if imagesBackfillUpdatedSince != "" {
+			updatedSince, err := parseUpdatedSince(imagesBackfillUpdatedSince)
+			if err != nil {
+				return err
+			}
+			imagesBackfillOptions.UpdatedSince = &updatedSince
+		}
+		return eventing.ImagesBackfill(cmd.OutOrStdout(), imagesBackfillOptions)
+	},
+}
+
+func init() {
+	imagesCmd.AddCommand(imagesBackfillCmd)
+
+	imagesBackfillCmd.Flags().StringSliceVar(&imagesBackfillOptions.Types, "types", image_backfill.Types, "entity types to backfill")
+	imagesBackfillCmd.Flags().BoolVar(&imagesBackfillOptions.DryRun, "dry-run", false, "print the requests without sending them")
+	imagesBackfillCmd.Flags().StringVar(&imagesBackfillUpdatedSince, "updated-since", "", "only rows updated at or after this date (2006-01-02 or RFC3339)")
+	imagesBackfillCmd.Flags().StringSliceVar(&imagesBackfillOptions.IDs, "ids", nil, "only these anime and their characters and staff")
+	imagesBackfillCmd.Flags().StringVar(&imagesBackfillOptions.Status, "status", "", "only anime with this status and their characters and staff")
+	imagesBackfillCmd.Flags().IntVar(&imagesBackfillOptions.BatchSize, "batch-size", 500, "rows read per batch")
+	imagesBackfillCmd.Flags().Float64Var(&imagesBackfillOptions.RatePerSecond, "rate", 20, "requests sent per second, 0 for no limit")
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"github.com/weeb-vip/anime-sync/internal/services/reindex"
+)
+
+var (
+	reindexOptions      reindex.Options
+	reindexUpdatedSince string
+)
+
+// reindexCmd represents the reindex command
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Publish every anime and season to the Algolia topic again",
+	Long: `Pages through the anime and anime_seasons tables and publishes an update
+document for every row to KAFKA_ALGOLIA_TOPIC, anime documents include their
+tags. Use it to rebuild the search index after it was lost or its schema
+changed. Progress is checkpointed in reindex_checkpoints after every batch,
+an interrupted run continues where it stopped with --resume.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reindexUpdatedSince != "" {
+			updatedSince, err := parseUpdatedSince(reindexUpdatedSince)
+			if err != nil {
+				return err
+			}
+			reindexOptions.UpdatedSince = &updatedSince
+		}
+		return eventing.Reindex(cmd.OutOrStdout(), reindexOptions)
+	},
+}
+
+// parseUpdatedSince accepts a RFC3339 timestamp or a plain date
+func parseUpdatedSince(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --updated-since %q, expected 2006-01-02 or RFC3339", value)
+	}
+	return parsed, nil
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().StringSliceVar(&reindexOptions.Tables, "tables", reindex.Tables, "tables to reindex")
+	reindexCmd.Flags().StringVar(&reindexUpdatedSince, "updated-since", "", "only rows updated at or after this date (2006-01-02 or RFC3339)")
+	reindexCmd.Flags().StringSliceVar(&reindexOptions.IDs, "ids", nil, "only these anime and their seasons")
+	reindexCmd.Flags().StringVar(&reindexOptions.Status, "status", "", "only anime with this status and their seasons")
+	reindexCmd.Flags().IntVar(&reindexOptions.BatchSize, "batch-size", 500, "rows read and checkpointed per batch")
+	reindexCmd.Flags().Float64Var(&reindexOptions.RatePerSecond, "rate", 200, "documents published per second, 0 for no limit")
+	reindexCmd.Flags().StringVar(&reindexOptions.Checkpoint, "checkpoint", "reindex", "name the progress of this run is stored under")
+	reindexCmd.Flags().BoolVar(&reindexOptions.Resume, "resume", false, "continue the run stored under --checkpoint")
+}
@@ -0,0 +1,29 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"log"
+)
+
+// serveAllKafkaCmd represents the serve-all-kafka command
+var serveAllKafkaCmd = &cobra.Command{
+	Use:   "serve-all-kafka",
+	Short: "Sync every table from Debezium CDC events in a single process",
+	Long: `Subscribes to every topic in KAFKA_TOPICS (comma separated, defaults to KAFKA_TOPIC)
+and dispatches each message to the processor for its Debezium source table.
+Messages without payload.source.table are routed with KAFKA_TOPIC_TABLES,
+e.g. "anime-db.public.anime=anime,anime-db.public.episodes=episodes".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Println("Running all tables eventing...")
+		return eventing.EventingAllKafka(serveOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveAllKafkaCmd)
+	addServeFlags(serveAllKafkaCmd)
+}
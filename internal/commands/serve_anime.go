@@ -21,12 +21,13 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Println("Running anime eventing...")
-		return eventing.EventingAnime()
+		return eventing.EventingAnime(serveOptions)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveAnimeCmd)
+	addServeFlags(serveAnimeCmd)
 
 	// Here you will define your flags and configuration settings.
 
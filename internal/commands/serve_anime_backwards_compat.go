@@ -19,12 +19,13 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return eventing.EventingAnime()
+		return eventing.EventingAnime(serveOptions)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
+	addServeFlags(serveCmd)
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,27 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"log"
+)
+
+// serveAnimeCharacterKafkaCmd represents the serve-anime-character-kafka command
+var serveAnimeCharacterKafkaCmd = &cobra.Command{
+	Use:   "serve-anime-character-kafka",
+	Short: "Sync anime_character rows from Debezium CDC events on Kafka",
+	Long: `Consumes anime_character change events from KAFKA_TOPIC, upserts or deletes
+the matching rows and forwards character images to KAFKA_PRODUCER_TOPIC.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Println("Running anime character eventing...")
+		return eventing.EventingAnimeCharacterKafka(serveOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveAnimeCharacterKafkaCmd)
+	addServeFlags(serveAnimeCharacterKafkaCmd)
+}
@@ -0,0 +1,28 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"log"
+)
+
+// serveAnimeCharacterStaffLinkKafkaCmd represents the serve-anime-character-staff-link-kafka command
+var serveAnimeCharacterStaffLinkKafkaCmd = &cobra.Command{
+	Use:   "serve-anime-character-staff-link-kafka",
+	Short: "Sync anime_character_staff_link rows from Debezium CDC events on Kafka",
+	Long: `Consumes anime_character_staff_link change events from KAFKA_TOPIC and its
+"-retry" topic. Links whose character or staff row has not been synced yet are
+re-queued on the retry topic instead of failing on the foreign key.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Println("Running anime character staff link eventing...")
+		return eventing.EventingAnimeCharacterStaffLinkKafka(serveOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveAnimeCharacterStaffLinkKafkaCmd)
+	addServeFlags(serveAnimeCharacterStaffLinkKafkaCmd)
+}
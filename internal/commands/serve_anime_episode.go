@@ -21,12 +21,13 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Println("Running anime episode eventing...")
-		return eventing.EventingAnimeEpisode()
+		return eventing.EventingAnimeEpisode(serveOptions)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveAnimeEpisodeCmd)
+	addServeFlags(serveAnimeEpisodeCmd)
 
 	// Here you will define your flags and configuration settings.
 
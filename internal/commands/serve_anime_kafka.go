@@ -21,12 +21,13 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Println("Running anime eventing...")
-		return eventing.EventingAnimeKafka()
+		return eventing.EventingAnimeKafka(serveOptions)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveAnimeKafkaCmd)
+	addServeFlags(serveAnimeKafkaCmd)
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,28 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"log"
+)
+
+// serveAnimeRelationKafkaCmd represents the serve-anime-relation-kafka command
+var serveAnimeRelationKafkaCmd = &cobra.Command{
+	Use:   "serve-anime-relation-kafka",
+	Short: "Sync anime_relations rows from Debezium CDC events on Kafka",
+	Long: `Consumes anime_relations change events from KAFKA_TOPIC and upserts or deletes
+the matching edges. With SYNC_MAINTAIN_INVERSE_RELATIONS=true the reverse edge is
+kept in sync as well, e.g. a sequel A→B also writes a prequel B→A.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Println("Running anime relation eventing...")
+		return eventing.EventingAnimeRelationKafka(serveOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveAnimeRelationKafkaCmd)
+	addServeFlags(serveAnimeRelationKafkaCmd)
+}
@@ -0,0 +1,28 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"log"
+)
+
+// serveAnimeStaffKafkaCmd represents the serve-anime-staff-kafka command
+var serveAnimeStaffKafkaCmd = &cobra.Command{
+	Use:   "serve-anime-staff-kafka",
+	Short: "Sync anime_staff rows from Debezium CDC events on Kafka",
+	Long: `Consumes anime_staff change events from KAFKA_TOPIC, upserts or deletes
+the matching rows, sends search documents to KAFKA_ALGOLIA_TOPIC and forwards
+staff images to KAFKA_PRODUCER_TOPIC.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Println("Running anime staff eventing...")
+		return eventing.EventingAnimeStaffKafka(serveOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveAnimeStaffKafkaCmd)
+	addServeFlags(serveAnimeStaffKafkaCmd)
+}
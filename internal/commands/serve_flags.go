@@ -0,0 +1,17 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+)
+
+// serveOptions holds the flags of the serve command being run
+var serveOptions eventing.ServeOptions
+
+// addServeFlags adds the flags shared by the serve commands to cmd
+func addServeFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&serveOptions.Workers, "workers", 0, "messages processed concurrently, messages with the same key stay in order (default CONSUMER_WORKERS)")
+}
@@ -0,0 +1,28 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/weeb-vip/anime-sync/internal/eventing"
+	"log"
+)
+
+// serveOutboxRelayCmd represents the serve-outbox-relay command
+var serveOutboxRelayCmd = &cobra.Command{
+	Use:   "serve-outbox-relay",
+	Short: "Publish pending outbox rows to Kafka",
+	Long: `Polls the outbox table for messages written by the sync processors when
+OUTBOX_ENABLED is set, produces them to their Algolia or image topic and marks
+them sent. Set OUTBOX_EXTERNAL_RELAY on the serve commands when running this
+on its own.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Println("Running outbox relay...")
+		return eventing.EventingOutboxRelay()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveOutboxRelayCmd)
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"time"
@@ -19,6 +20,11 @@ func NewDB(cfg config.DBConfig) *DB {
 		panic("failed to connect database")
 	}
 
+	// statements run with a traced context show up as spans of the message being processed
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		panic("failed to register tracing plugin")
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		panic("failed to get database connection")
@@ -42,3 +48,12 @@ func NewDB(cfg config.DBConfig) *DB {
 
 	return &DB{DB: db}
 }
+
+// Close closes the connection pool
+func (d *DB) Close() error {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -30,6 +30,9 @@ type Anime struct {
 	Ranking       *int         `gorm:"column:ranking;null" json:"ranking"`
 	CreatedAt     time.Time    `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt     time.Time    `gorm:"column:updated_at" json:"updated_at"`
+
+	// Season is not persisted, migration 000019 moved the column to the anime_seasons table
+	Season *string `gorm:"-" json:"season"`
 }
 
 // set table name
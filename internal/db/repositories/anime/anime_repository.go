@@ -3,13 +3,45 @@ package anime
 import (
 	"context"
 	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"time"
 )
 
+// upsertBatchSize is how many rows go into one INSERT ... ON DUPLICATE KEY UPDATE statement
+const upsertBatchSize = 500
+
 type RECORD_TYPE string
 
 type AnimeRepositoryImpl interface {
-	Upsert(anime *Anime, oldTitle *string) error
+	Upsert(anime *Anime) error
+	UpsertMany(animes []*Anime) error
+	UpdateColumns(anime *Anime, columns []string) error
 	Delete(anime *Anime) error
+	FindPage(filter Filter, afterID string, limit int) ([]Anime, error)
+	Count(filter Filter) (int64, error)
+	WithTx(tx *db.DB) AnimeRepositoryImpl
+}
+
+// Filter narrows the anime paged through by FindPage, zero fields match every row
+type Filter struct {
+	UpdatedSince *time.Time
+	IDs          []string
+	Status       string
+}
+
+func (f Filter) apply(query *gorm.DB) *gorm.DB {
+	if f.UpdatedSince != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedSince)
+	}
+	if len(f.IDs) > 0 {
+		query = query.Where("id IN ?", f.IDs)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	return query
 }
 
 type AnimeRepository struct {
@@ -20,7 +52,12 @@ func NewAnimeRepository(db *db.DB) AnimeRepositoryImpl {
 	return &AnimeRepository{db: db}
 }
 
-func (a *AnimeRepository) Upsert(anime *Anime, oldTitle *string) error {
+// WithTx returns a repository bound to the given transaction
+func (a *AnimeRepository) WithTx(tx *db.DB) AnimeRepositoryImpl {
+	return &AnimeRepository{db: tx}
+}
+
+func (a *AnimeRepository) Upsert(anime *Anime) error {
 	// Log the anime struct before saving for debugging
 	if anime.TheTVDBID != nil {
 		a.db.DB.Logger.Info(context.Background(), "Upserting anime with TheTVDBID: ID=%s, TheTVDBID=%s", anime.ID, *anime.TheTVDBID)
@@ -35,6 +72,34 @@ func (a *AnimeRepository) Upsert(anime *Anime, oldTitle *string) error {
 	return nil
 }
 
+// UpsertMany writes the anime with multi row INSERT ... ON DUPLICATE KEY UPDATE statements,
+// stored rows get every column but created_at replaced
+func (a *AnimeRepository) UpsertMany(animes []*Anime) error {
+	if len(animes) == 0 {
+		return nil
+	}
+	return a.db.DB.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(animes, upsertBatchSize).Error
+}
+
+// UpdateColumns writes only the given columns of the anime and its updated_at. An anime that is not
+// stored yet, e.g. because its create event was missed, is inserted whole
+func (a *AnimeRepository) UpdateColumns(anime *Anime, columns []string) error {
+	result := a.db.DB.Model(anime).Select(append(slices.Clone(columns), "updated_at")).Updates(anime)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	err := a.db.DB.Model(&Anime{}).Where("id = ?", anime.ID).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	return a.db.DB.Save(anime).Error
+}
+
 func (a *AnimeRepository) Delete(anime *Anime) error {
 	err := a.db.DB.Delete(anime).Error
 	if err != nil {
@@ -42,3 +107,24 @@ func (a *AnimeRepository) Delete(anime *Anime) error {
 	}
 	return nil
 }
+
+// FindPage returns up to limit anime matching filter with an id after afterID, ordered by id,
+// so a full table can be paged through without offsets
+func (a *AnimeRepository) FindPage(filter Filter, afterID string, limit int) ([]Anime, error) {
+	var animes []Anime
+	err := filter.apply(a.db.DB.Model(&Anime{})).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&animes).Error
+	if err != nil {
+		return nil, err
+	}
+	return animes, nil
+}
+
+func (a *AnimeRepository) Count(filter Filter) (int64, error) {
+	var count int64
+	err := filter.apply(a.db.DB.Model(&Anime{})).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,29 @@
+package anime_character
+
+import (
+	"time"
+)
+
+type AnimeCharacter struct {
+	ID            string    `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	AnimeID       string    `gorm:"column:anime_id;type:varchar(36);not null" json:"anime_id"`
+	Name          string    `gorm:"column:name;not null" json:"name"`
+	Role          string    `gorm:"column:role;not null" json:"role"`
+	Birthday      *string   `gorm:"column:birthday;null" json:"birthday"`
+	Zodiac        *string   `gorm:"column:zodiac;null" json:"zodiac"`
+	Gender        *string   `gorm:"column:gender;null" json:"gender"`
+	Race          *string   `gorm:"column:race;null" json:"race"`
+	Height        *string   `gorm:"column:height;null" json:"height"`
+	Weight        *string   `gorm:"column:weight;null" json:"weight"`
+	Title         *string   `gorm:"column:title;null" json:"title"`
+	MartialStatus *string   `gorm:"column:martial_status;null" json:"martial_status"`
+	Summary       *string   `gorm:"column:summary;type:text;null" json:"summary"`
+	Image         *string   `gorm:"column:image;type:text;null" json:"image"`
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName sets table name
+func (AnimeCharacter) TableName() string {
+	return "anime_character"
+}
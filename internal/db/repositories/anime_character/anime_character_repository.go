@@ -0,0 +1,79 @@
+package anime_character
+
+import (
+	"time"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+)
+
+type AnimeCharacterRepositoryImpl interface {
+	Upsert(character *AnimeCharacter) error
+	Delete(character *AnimeCharacter) error
+	FindPage(filter Filter, afterID string, limit int) ([]AnimeCharacter, error)
+	WithTx(tx *db.DB) AnimeCharacterRepositoryImpl
+}
+
+// Filter narrows the characters paged through by FindPage, zero fields match every row.
+// AnimeIDs and AnimeStatus select the characters of matching anime
+type Filter struct {
+	UpdatedSince *time.Time
+	AnimeIDs     []string
+	AnimeStatus  string
+}
+
+func (f Filter) apply(query *gorm.DB) *gorm.DB {
+	if f.UpdatedSince != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedSince)
+	}
+	if len(f.AnimeIDs) > 0 {
+		query = query.Where("anime_id IN ?", f.AnimeIDs)
+	}
+	if f.AnimeStatus != "" {
+		query = query.Where("anime_id IN (SELECT id FROM anime WHERE status = ?)", f.AnimeStatus)
+	}
+	return query
+}
+
+type AnimeCharacterRepository struct {
+	db *db.DB
+}
+
+func NewAnimeCharacterRepository(db *db.DB) AnimeCharacterRepositoryImpl {
+	return &AnimeCharacterRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *AnimeCharacterRepository) WithTx(tx *db.DB) AnimeCharacterRepositoryImpl {
+	return &AnimeCharacterRepository{db: tx}
+}
+
+func (r *AnimeCharacterRepository) Upsert(character *AnimeCharacter) error {
+	err := r.db.DB.Save(character).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AnimeCharacterRepository) Delete(character *AnimeCharacter) error {
+	err := r.db.DB.Delete(character).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindPage returns up to limit characters matching filter with an id after afterID, ordered by id
+func (r *AnimeCharacterRepository) FindPage(filter Filter, afterID string, limit int) ([]AnimeCharacter, error) {
+	var characters []AnimeCharacter
+	err := filter.apply(r.db.DB.Model(&AnimeCharacter{})).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&characters).Error
+	if err != nil {
+		return nil, err
+	}
+	return characters, nil
+}
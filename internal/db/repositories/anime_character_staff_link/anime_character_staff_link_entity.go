@@ -0,0 +1,21 @@
+package anime_character_staff_link
+
+import (
+	"time"
+)
+
+type AnimeCharacterStaffLink struct {
+	ID              string    `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	CharacterID     string    `gorm:"column:character_id;type:varchar(36);not null" json:"character_id"`
+	StaffID         string    `gorm:"column:staff_id;type:varchar(36);not null" json:"staff_id"`
+	CharacterName   string    `gorm:"column:character_name;not null" json:"character_name"`
+	StaffGivenName  string    `gorm:"column:staff_given_name;not null" json:"staff_given_name"`
+	StaffFamilyName string    `gorm:"column:staff_family_name;not null" json:"staff_family_name"`
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName sets table name
+func (AnimeCharacterStaffLink) TableName() string {
+	return "anime_character_staff_link"
+}
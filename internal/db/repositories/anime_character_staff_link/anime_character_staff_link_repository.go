@@ -0,0 +1,54 @@
+package anime_character_staff_link
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/weeb-vip/anime-sync/internal/db"
+)
+
+// mysqlErrNoReferencedRow is returned by MySQL when a foreign key points at a missing row
+const mysqlErrNoReferencedRow = 1452
+
+// ErrMissingReference is returned when the linked character or staff row does not exist yet
+var ErrMissingReference = errors.New("referenced character or staff does not exist")
+
+type AnimeCharacterStaffLinkRepositoryImpl interface {
+	Upsert(link *AnimeCharacterStaffLink) error
+	Delete(link *AnimeCharacterStaffLink) error
+}
+
+type AnimeCharacterStaffLinkRepository struct {
+	db *db.DB
+}
+
+func NewAnimeCharacterStaffLinkRepository(db *db.DB) AnimeCharacterStaffLinkRepositoryImpl {
+	return &AnimeCharacterStaffLinkRepository{db: db}
+}
+
+func (r *AnimeCharacterStaffLinkRepository) Upsert(link *AnimeCharacterStaffLink) error {
+	err := r.db.DB.Save(link).Error
+	if err != nil {
+		return upsertError(link, err)
+	}
+	return nil
+}
+
+// upsertError marks a missing character or staff row with ErrMissingReference, the MySQL error stays
+// in the chain so retryable.Classify still sees it
+func upsertError(link *AnimeCharacterStaffLink, err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrNoReferencedRow {
+		return fmt.Errorf("%w: character_id=%s staff_id=%s: %w", ErrMissingReference, link.CharacterID, link.StaffID, err)
+	}
+	return err
+}
+
+func (r *AnimeCharacterStaffLinkRepository) Delete(link *AnimeCharacterStaffLink) error {
+	err := r.db.DB.Delete(link).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package anime_character_staff_link
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+)
+
+func TestUpsertError(t *testing.T) {
+	link := &AnimeCharacterStaffLink{ID: "link-1", CharacterID: "c1", StaffID: "s1"}
+
+	t.Run("MissingReference", func(t *testing.T) {
+		err := upsertError(link, fmt.Errorf("save: %w", &mysql.MySQLError{Number: mysqlErrNoReferencedRow, Message: "foreign key constraint fails"}))
+
+		assert.ErrorIs(t, err, ErrMissingReference)
+		var mysqlErr *mysql.MySQLError
+		assert.ErrorAs(t, err, &mysqlErr)
+		assert.Equal(t, retryable.Retriable, retryable.Classify(err))
+	})
+
+	t.Run("OtherErrors", func(t *testing.T) {
+		cause := &mysql.MySQLError{Number: 1406, Message: "data too long"}
+		err := upsertError(link, cause)
+
+		assert.False(t, errors.Is(err, ErrMissingReference))
+		assert.Equal(t, retryable.Permanent, retryable.Classify(err))
+	})
+}
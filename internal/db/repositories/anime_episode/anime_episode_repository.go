@@ -1,12 +1,23 @@
 package anime
 
-import "github.com/weeb-vip/anime-sync/internal/db"
+import (
+	"slices"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm/clause"
+)
+
+// upsertBatchSize is how many rows go into one INSERT ... ON DUPLICATE KEY UPDATE statement
+const upsertBatchSize = 500
 
 type RECORD_TYPE string
 
 type AnimeEpisodeRepositoryImpl interface {
 	Upsert(anime *AnimeEpisode) error
+	UpsertMany(episodes []*AnimeEpisode) error
+	UpdateColumns(episode *AnimeEpisode, columns []string) error
 	Delete(anime *AnimeEpisode) error
+	WithTx(tx *db.DB) AnimeEpisodeRepositoryImpl
 }
 
 type AnimeEpisodeRepository struct {
@@ -17,6 +28,11 @@ func NewAnimeRepository(db *db.DB) AnimeEpisodeRepositoryImpl {
 	return &AnimeEpisodeRepository{db: db}
 }
 
+// WithTx returns a repository bound to the given transaction
+func (a *AnimeEpisodeRepository) WithTx(tx *db.DB) AnimeEpisodeRepositoryImpl {
+	return &AnimeEpisodeRepository{db: tx}
+}
+
 func (a *AnimeEpisodeRepository) Upsert(episode *AnimeEpisode) error {
 	err := a.db.DB.Save(episode).Error
 	if err != nil {
@@ -25,6 +41,79 @@ func (a *AnimeEpisodeRepository) Upsert(episode *AnimeEpisode) error {
 	return nil
 }
 
+// UpsertMany writes the episodes with multi row INSERT ... ON DUPLICATE KEY UPDATE statements. The
+// episode count triggers are skipped for the statements through @skip_episode_count (migration 000039),
+// the count of every anime the episodes belong to, or belonged to before they moved, is updated once
+// afterwards instead. The variable lives on the connection, so run it in a transaction
+func (a *AnimeEpisodeRepository) UpsertMany(episodes []*AnimeEpisode) error {
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(episodes))
+	for _, episode := range episodes {
+		ids = append(ids, episode.ID)
+	}
+	// an episode moved to another anime lowers the count of its previous anime, read it before the
+	// upsert overwrites it
+	var animeIDs []string
+	for batch := range slices.Chunk(ids, upsertBatchSize) {
+		var previousAnimeIDs []string
+		err := a.db.DB.Model(&AnimeEpisode{}).Distinct().Where("id IN ?", batch).Pluck("anime_id", &previousAnimeIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, animeID := range previousAnimeIDs {
+			if !slices.Contains(animeIDs, animeID) {
+				animeIDs = append(animeIDs, animeID)
+			}
+		}
+	}
+
+	err := a.db.DB.Exec("SET @skip_episode_count = 1").Error
+	if err != nil {
+		return err
+	}
+	err = a.db.DB.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(episodes, upsertBatchSize).Error
+	// reset even when the insert failed, the connection goes back to the pool
+	resetErr := a.db.DB.Exec("SET @skip_episode_count = NULL").Error
+	if err != nil {
+		return err
+	}
+	if resetErr != nil {
+		return resetErr
+	}
+
+	for _, episode := range episodes {
+		if episode.AnimeID != nil && !slices.Contains(animeIDs, *episode.AnimeID) {
+			animeIDs = append(animeIDs, *episode.AnimeID)
+		}
+	}
+	if len(animeIDs) == 0 {
+		return nil
+	}
+	return a.db.DB.Exec("UPDATE anime SET episodes = (SELECT COUNT(*) FROM episodes WHERE episodes.anime_id = anime.id) WHERE id IN ?", animeIDs).Error
+}
+
+// UpdateColumns writes only the given columns of the episode and its updated_at,
+// an episode that is not stored yet is inserted whole
+func (a *AnimeEpisodeRepository) UpdateColumns(episode *AnimeEpisode, columns []string) error {
+	result := a.db.DB.Model(episode).Select(append(slices.Clone(columns), "updated_at")).Updates(episode)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	err := a.db.DB.Model(&AnimeEpisode{}).Where("id = ?", episode.ID).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	return a.db.DB.Save(episode).Error
+}
+
 func (a *AnimeEpisodeRepository) Delete(episode *AnimeEpisode) error {
 	err := a.db.DB.Delete(episode).Error
 	if err != nil {
@@ -0,0 +1,86 @@
+package anime_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	anime_episode "github.com/weeb-vip/anime-sync/internal/db/repositories/anime_episode"
+)
+
+func setupTestDB(t *testing.T) *db.DB {
+	cfg := &config.DBConfig{
+		Host:     "localhost",
+		Port:     3306,
+		User:     "weeb",
+		Password: "mysecretpassword",
+		DataBase: "weeb",
+		SSLMode:  "false",
+	}
+
+	database := db.NewDB(*cfg)
+	require.NotNil(t, database)
+
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	err = sqlDB.Ping()
+	require.NoError(t, err, "Database should be accessible")
+
+	return database
+}
+
+func TestAnimeEpisodeRepository_UpsertManyRecountsPreviousAnime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	database := setupTestDB(t)
+	repo := anime_episode.NewAnimeRepository(database)
+	ctx := context.Background()
+
+	animeA := "00000000-0000-0000-0000-00000000e0a1"
+	animeB := "00000000-0000-0000-0000-00000000e0b1"
+	t.Cleanup(func() {
+		database.DB.Where("anime_id IN ?", []string{animeA, animeB}).Delete(&anime_episode.AnimeEpisode{})
+		database.DB.Where("id IN ?", []string{animeA, animeB}).Delete(&anime.Anime{})
+	})
+	require.NoError(t, database.DB.Create(&anime.Anime{ID: animeA}).Error)
+	require.NoError(t, database.DB.Create(&anime.Anime{ID: animeB}).Error)
+
+	upsertMany := func(animeID string, numbers ...int) {
+		episodes := make([]*anime_episode.AnimeEpisode, 0, len(numbers))
+		for _, number := range numbers {
+			episodes = append(episodes, &anime_episode.AnimeEpisode{
+				ID:      fmt.Sprintf("00000000-0000-0000-0000-0000000e0%03d", number),
+				AnimeID: &animeID,
+				Episode: &number,
+			})
+		}
+		err := database.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			return repo.WithTx(tx).UpsertMany(episodes)
+		})
+		require.NoError(t, err)
+	}
+	episodeCount := func(animeID string) int {
+		var stored anime.Anime
+		require.NoError(t, database.DB.Where("id = ?", animeID).First(&stored).Error)
+		if stored.Episodes == nil {
+			return 0
+		}
+		return *stored.Episodes
+	}
+
+	upsertMany(animeA, 1, 2, 3)
+	assert.Equal(t, 3, episodeCount(animeA))
+
+	// episode 3 moves to anime B
+	upsertMany(animeB, 3)
+	assert.Equal(t, 2, episodeCount(animeA))
+	assert.Equal(t, 1, episodeCount(animeB))
+}
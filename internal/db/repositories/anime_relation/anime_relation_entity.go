@@ -0,0 +1,18 @@
+package anime_relation
+
+import (
+	"time"
+)
+
+type AnimeRelation struct {
+	ID             string    `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	AnimeID        string    `gorm:"column:anime_id;type:varchar(36);not null" json:"anime_id"`
+	RelatedAnimeID string    `gorm:"column:related_anime_id;type:varchar(36);not null" json:"related_anime_id"`
+	RelationType   *string   `gorm:"column:relation_type;type:varchar(30);null" json:"relation_type"`
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName sets table name
+func (AnimeRelation) TableName() string {
+	return "anime_relations"
+}
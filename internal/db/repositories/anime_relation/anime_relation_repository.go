@@ -0,0 +1,55 @@
+package anime_relation
+
+import (
+	"github.com/weeb-vip/anime-sync/internal/db"
+)
+
+type AnimeRelationRepositoryImpl interface {
+	Upsert(relation *AnimeRelation) error
+	Delete(relation *AnimeRelation) error
+	FindByEdge(animeID string, relatedAnimeID string, relationType string) ([]AnimeRelation, error)
+	WithTx(tx *db.DB) AnimeRelationRepositoryImpl
+}
+
+type AnimeRelationRepository struct {
+	db *db.DB
+}
+
+func NewAnimeRelationRepository(db *db.DB) AnimeRelationRepositoryImpl {
+	return &AnimeRelationRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *AnimeRelationRepository) WithTx(tx *db.DB) AnimeRelationRepositoryImpl {
+	return &AnimeRelationRepository{db: tx}
+}
+
+func (r *AnimeRelationRepository) Upsert(relation *AnimeRelation) error {
+	err := r.db.DB.Save(relation).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AnimeRelationRepository) Delete(relation *AnimeRelation) error {
+	err := r.db.DB.Delete(relation).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindByEdge returns the relations from animeID to relatedAnimeID of relationType, there is no unique
+// key on the three columns so an edge can be stored more than once
+func (r *AnimeRelationRepository) FindByEdge(animeID string, relatedAnimeID string, relationType string) ([]AnimeRelation, error) {
+	var relations []AnimeRelation
+	err := r.db.DB.
+		Where("anime_id = ? AND related_anime_id = ? AND relation_type = ?", animeID, relatedAnimeID, relationType).
+		Order("id").
+		Find(&relations).Error
+	if err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
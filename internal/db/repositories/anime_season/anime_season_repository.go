@@ -2,11 +2,39 @@ package anime_season
 
 import (
 	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+	"slices"
+	"time"
 )
 
 type AnimeSeasonRepositoryImpl interface {
 	Upsert(animeSeason *AnimeSeason) error
+	UpdateColumns(animeSeason *AnimeSeason, columns []string) error
 	Delete(animeSeason *AnimeSeason) error
+	FindPage(filter Filter, afterID string, limit int) ([]AnimeSeason, error)
+	Count(filter Filter) (int64, error)
+	WithTx(tx *db.DB) AnimeSeasonRepositoryImpl
+}
+
+// Filter narrows the seasons paged through by FindPage, zero fields match every row.
+// AnimeIDs and AnimeStatus select the seasons of matching anime
+type Filter struct {
+	UpdatedSince *time.Time
+	AnimeIDs     []string
+	AnimeStatus  string
+}
+
+func (f Filter) apply(query *gorm.DB) *gorm.DB {
+	if f.UpdatedSince != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedSince)
+	}
+	if len(f.AnimeIDs) > 0 {
+		query = query.Where("anime_id IN ?", f.AnimeIDs)
+	}
+	if f.AnimeStatus != "" {
+		query = query.Where("anime_id IN (SELECT id FROM anime WHERE status = ?)", f.AnimeStatus)
+	}
+	return query
 }
 
 type AnimeSeasonRepository struct {
@@ -17,6 +45,11 @@ func NewAnimeSeasonRepository(db *db.DB) AnimeSeasonRepositoryImpl {
 	return &AnimeSeasonRepository{db: db}
 }
 
+// WithTx returns a repository bound to the given transaction
+func (r *AnimeSeasonRepository) WithTx(tx *db.DB) AnimeSeasonRepositoryImpl {
+	return &AnimeSeasonRepository{db: tx}
+}
+
 func (r *AnimeSeasonRepository) Upsert(animeSeason *AnimeSeason) error {
 	err := r.db.DB.Save(animeSeason).Error
 	if err != nil {
@@ -25,10 +58,49 @@ func (r *AnimeSeasonRepository) Upsert(animeSeason *AnimeSeason) error {
 	return nil
 }
 
+// UpdateColumns writes only the given columns of the season and its updated_at,
+// a season that is not stored yet is inserted whole
+func (r *AnimeSeasonRepository) UpdateColumns(animeSeason *AnimeSeason, columns []string) error {
+	result := r.db.DB.Model(animeSeason).Select(append(slices.Clone(columns), "updated_at")).Updates(animeSeason)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	err := r.db.DB.Model(&AnimeSeason{}).Where("id = ?", animeSeason.ID).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	return r.db.DB.Save(animeSeason).Error
+}
+
 func (r *AnimeSeasonRepository) Delete(animeSeason *AnimeSeason) error {
 	err := r.db.DB.Delete(animeSeason).Error
 	if err != nil {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// FindPage returns up to limit seasons matching filter with an id after afterID, ordered by id
+func (r *AnimeSeasonRepository) FindPage(filter Filter, afterID string, limit int) ([]AnimeSeason, error) {
+	var seasons []AnimeSeason
+	err := filter.apply(r.db.DB.Model(&AnimeSeason{})).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&seasons).Error
+	if err != nil {
+		return nil, err
+	}
+	return seasons, nil
+}
+
+func (r *AnimeSeasonRepository) Count(filter Filter) (int64, error) {
+	var count int64
+	err := filter.apply(r.db.DB.Model(&AnimeSeason{})).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,25 @@
+package anime_staff
+
+import (
+	"time"
+)
+
+type AnimeStaff struct {
+	ID         string    `gorm:"column:id;type:char(36);primaryKey" json:"id"`
+	GivenName  string    `gorm:"column:given_name;not null" json:"given_name"`
+	FamilyName string    `gorm:"column:family_name;not null" json:"family_name"`
+	Image      *string   `gorm:"column:image;type:text;null" json:"image"`
+	Birthday   *string   `gorm:"column:birthday;null" json:"birthday"`
+	BirthPlace *string   `gorm:"column:birth_place;null" json:"birth_place"`
+	BloodType  *string   `gorm:"column:blood_type;null" json:"blood_type"`
+	Hobbies    *string   `gorm:"column:hobbies;null" json:"hobbies"`
+	Summary    *string   `gorm:"column:summary;type:text;null" json:"summary"`
+	Language   *string   `gorm:"column:language;null" json:"language"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName sets table name
+func (AnimeStaff) TableName() string {
+	return "anime_staff"
+}
@@ -0,0 +1,79 @@
+package anime_staff
+
+import (
+	"time"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+)
+
+type AnimeStaffRepositoryImpl interface {
+	Upsert(staff *AnimeStaff) error
+	Delete(staff *AnimeStaff) error
+	FindPage(filter Filter, afterID string, limit int) ([]AnimeStaff, error)
+	WithTx(tx *db.DB) AnimeStaffRepositoryImpl
+}
+
+// Filter narrows the staff paged through by FindPage, zero fields match every row.
+// AnimeIDs and AnimeStatus select the staff linked to characters of matching anime
+type Filter struct {
+	UpdatedSince *time.Time
+	AnimeIDs     []string
+	AnimeStatus  string
+}
+
+func (f Filter) apply(query *gorm.DB) *gorm.DB {
+	if f.UpdatedSince != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedSince)
+	}
+	if len(f.AnimeIDs) > 0 {
+		query = query.Where("id IN (SELECT staff_id FROM anime_character_staff_link WHERE character_id IN (SELECT id FROM anime_character WHERE anime_id IN ?))", f.AnimeIDs)
+	}
+	if f.AnimeStatus != "" {
+		query = query.Where("id IN (SELECT staff_id FROM anime_character_staff_link WHERE character_id IN (SELECT id FROM anime_character WHERE anime_id IN (SELECT id FROM anime WHERE status = ?)))", f.AnimeStatus)
+	}
+	return query
+}
+
+type AnimeStaffRepository struct {
+	db *db.DB
+}
+
+func NewAnimeStaffRepository(db *db.DB) AnimeStaffRepositoryImpl {
+	return &AnimeStaffRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *AnimeStaffRepository) WithTx(tx *db.DB) AnimeStaffRepositoryImpl {
+	return &AnimeStaffRepository{db: tx}
+}
+
+func (r *AnimeStaffRepository) Upsert(staff *AnimeStaff) error {
+	err := r.db.DB.Save(staff).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AnimeStaffRepository) Delete(staff *AnimeStaff) error {
+	err := r.db.DB.Delete(staff).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindPage returns up to limit staff matching filter with an id after afterID, ordered by id
+func (r *AnimeStaffRepository) FindPage(filter Filter, afterID string, limit int) ([]AnimeStaff, error) {
+	var staff []AnimeStaff
+	err := filter.apply(r.db.DB.Model(&AnimeStaff{})).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&staff).Error
+	if err != nil {
+		return nil, err
+	}
+	return staff, nil
+}
@@ -2,14 +2,21 @@ package anime_tag
 
 import (
 	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
 )
 
+// insertBatchSize is how many rows go into one multi row INSERT
+const insertBatchSize = 1000
+
 type AnimeTagRepositoryImpl interface {
 	SetTagsForAnime(animeID string, tagIDs []int64) error
+	SetTagsForAnimes(tagIDs map[string][]int64) error
 	GetTagIDsForAnime(animeID string) ([]int64, error)
+	GetTagNamesForAnimes(animeIDs []string) (map[string][]string, error)
 	AddTagToAnime(animeID string, tagID int64) error
 	RemoveTagFromAnime(animeID string, tagID int64) error
 	DeleteAllTagsForAnime(animeID string) error
+	WithTx(tx *db.DB) AnimeTagRepositoryImpl
 }
 
 type AnimeTagRepository struct {
@@ -20,30 +27,71 @@ func NewAnimeTagRepository(db *db.DB) AnimeTagRepositoryImpl {
 	return &AnimeTagRepository{db: db}
 }
 
-// SetTagsForAnime replaces all tags for an anime with the given tag IDs
+// WithTx returns a repository bound to the given transaction
+func (r *AnimeTagRepository) WithTx(tx *db.DB) AnimeTagRepositoryImpl {
+	return &AnimeTagRepository{db: tx}
+}
+
+// SetTagsForAnime replaces all tags for an anime with the given tag IDs.
+// The delete and insert run in one transaction (a savepoint when already inside one)
+// so an anime never ends up with half of its tags.
 func (r *AnimeTagRepository) SetTagsForAnime(animeID string, tagIDs []int64) error {
-	// Delete existing tag associations
-	err := r.db.DB.Where("anime_id = ?", animeID).Delete(&AnimeTag{}).Error
-	if err != nil {
-		return err
+	return r.db.DB.Transaction(func(tx *gorm.DB) error {
+		// Delete existing tag associations
+		err := tx.Where("anime_id = ?", animeID).Delete(&AnimeTag{}).Error
+		if err != nil {
+			return err
+		}
+
+		// Insert new tag associations
+		if len(tagIDs) > 0 {
+			animeTags := make([]AnimeTag, len(tagIDs))
+			for i, tagID := range tagIDs {
+				animeTags[i] = AnimeTag{
+					AnimeID: animeID,
+					TagID:   tagID,
+				}
+			}
+			err = tx.Create(&animeTags).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SetTagsForAnimes replaces the tags of several anime at once, tagIDs maps anime ids to their
+// tag ids. Like SetTagsForAnime the delete and the insert run in one transaction
+func (r *AnimeTagRepository) SetTagsForAnimes(tagIDs map[string][]int64) error {
+	if len(tagIDs) == 0 {
+		return nil
 	}
 
-	// Insert new tag associations
-	if len(tagIDs) > 0 {
-		animeTags := make([]AnimeTag, len(tagIDs))
-		for i, tagID := range tagIDs {
-			animeTags[i] = AnimeTag{
+	animeIDs := make([]string, 0, len(tagIDs))
+	var animeTags []AnimeTag
+	for animeID, ids := range tagIDs {
+		animeIDs = append(animeIDs, animeID)
+		for _, tagID := range ids {
+			animeTags = append(animeTags, AnimeTag{
 				AnimeID: animeID,
 				TagID:   tagID,
-			}
+			})
 		}
-		err = r.db.DB.Create(&animeTags).Error
+	}
+
+	return r.db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("anime_id IN ?", animeIDs).Delete(&AnimeTag{}).Error
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
+		if len(animeTags) > 0 {
+			return tx.CreateInBatches(&animeTags, insertBatchSize).Error
+		}
+		return nil
+	})
 }
 
 // GetTagIDsForAnime returns all tag IDs associated with an anime
@@ -61,6 +109,34 @@ func (r *AnimeTagRepository) GetTagIDsForAnime(animeID string) ([]int64, error)
 	return tagIDs, nil
 }
 
+// GetTagNamesForAnimes returns the tag names of each of the anime, sorted by name.
+// Anime without tags are left out of the map
+func (r *AnimeTagRepository) GetTagNamesForAnimes(animeIDs []string) (map[string][]string, error) {
+	tagNames := make(map[string][]string, len(animeIDs))
+	if len(animeIDs) == 0 {
+		return tagNames, nil
+	}
+
+	var rows []struct {
+		AnimeID string
+		Name    string
+	}
+	err := r.db.DB.Table("anime_tags").
+		Select("anime_tags.anime_id, tags.name").
+		Joins("JOIN tags ON tags.id = anime_tags.tag_id").
+		Where("anime_tags.anime_id IN ?", animeIDs).
+		Order("anime_tags.anime_id, tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		tagNames[row.AnimeID] = append(tagNames[row.AnimeID], row.Name)
+	}
+	return tagNames, nil
+}
+
 // AddTagToAnime adds a single tag to an anime
 func (r *AnimeTagRepository) AddTagToAnime(animeID string, tagID int64) error {
 	animeTag := AnimeTag{
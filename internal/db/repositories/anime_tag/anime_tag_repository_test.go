@@ -59,7 +59,7 @@ func TestAnimeTagRepository_SetTagsForAnime(t *testing.T) {
 		ID:      "test-anime-tag-001",
 		TitleEn: &titleEn,
 	}
-	err := animeRepo.Upsert(testAnime, nil)
+	err := animeRepo.Upsert(testAnime)
 	require.NoError(t, err)
 
 	// Create test tags
@@ -135,7 +135,7 @@ func TestAnimeTagRepository_AddAndRemoveTag(t *testing.T) {
 		ID:      "test-anime-tag-002",
 		TitleEn: &titleEn,
 	}
-	err := animeRepo.Upsert(testAnime, nil)
+	err := animeRepo.Upsert(testAnime)
 	require.NoError(t, err)
 
 	// Create test tag
@@ -187,7 +187,7 @@ func TestAnimeTagRepository_DeleteAllTagsForAnime(t *testing.T) {
 		ID:      "test-anime-tag-003",
 		TitleEn: &titleEn,
 	}
-	err := animeRepo.Upsert(testAnime, nil)
+	err := animeRepo.Upsert(testAnime)
 	require.NoError(t, err)
 
 	// Create and add multiple tags
@@ -227,3 +227,45 @@ func TestAnimeTagRepository_GetTagIDsForAnime_EmptyResult(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, tagIDs, 0)
 }
+
+func TestAnimeTagRepository_GetTagNamesForAnimes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	database := setupTestDB(t)
+	animeRepo := anime.NewAnimeRepository(database)
+	tagRepo := tag.NewTagRepository(database)
+	animeTagRepo := anime_tag.NewAnimeTagRepository(database)
+
+	// Clean up test data
+	cleanup := func() {
+		database.DB.Exec("DELETE FROM anime_tags WHERE anime_id LIKE ?", "test-anime-tag-%")
+		database.DB.Exec("DELETE FROM tags WHERE name LIKE ?", "test-tag-%")
+		database.DB.Where("id LIKE ?", "test-anime-tag-%").Delete(&anime.Anime{})
+	}
+	cleanup()
+	defer cleanup()
+
+	for _, id := range []string{"test-anime-tag-004", "test-anime-tag-005", "test-anime-tag-006"} {
+		titleEn := "Test Anime for Tag Names"
+		err := animeRepo.Upsert(&anime.Anime{ID: id, TitleEn: &titleEn})
+		require.NoError(t, err)
+	}
+
+	drama, err := tagRepo.FindOrCreate("test-tag-names-drama")
+	require.NoError(t, err)
+	action, err := tagRepo.FindOrCreate("test-tag-names-action")
+	require.NoError(t, err)
+
+	require.NoError(t, animeTagRepo.SetTagsForAnime("test-anime-tag-004", []int64{drama.ID, action.ID}))
+	require.NoError(t, animeTagRepo.SetTagsForAnime("test-anime-tag-005", []int64{drama.ID}))
+
+	tagNames, err := animeTagRepo.GetTagNamesForAnimes([]string{"test-anime-tag-004", "test-anime-tag-005", "test-anime-tag-006"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"test-anime-tag-004": {"test-tag-names-action", "test-tag-names-drama"},
+		"test-anime-tag-005": {"test-tag-names-drama"},
+	}, tagNames)
+}
@@ -0,0 +1,18 @@
+package anime_title_history
+
+import "time"
+
+// AnimeTitleHistory is one change of one title field of an anime, OldTitle is nil when the title
+// was added and NewTitle is nil when it was removed
+type AnimeTitleHistory struct {
+	ID        int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	AnimeID   string    `gorm:"column:anime_id;type:varchar(36);not null" json:"anime_id"`
+	Field     string    `gorm:"column:field;not null" json:"field"`
+	OldTitle  *string   `gorm:"column:old_title;type:text;null" json:"old_title"`
+	NewTitle  *string   `gorm:"column:new_title;type:text;null" json:"new_title"`
+	ChangedAt time.Time `gorm:"column:changed_at" json:"changed_at"`
+}
+
+func (AnimeTitleHistory) TableName() string {
+	return "anime_title_history"
+}
@@ -0,0 +1,60 @@
+package anime_title_history
+
+import (
+	"github.com/weeb-vip/anime-sync/internal/db"
+)
+
+type AnimeTitleHistoryRepositoryImpl interface {
+	Create(entries []AnimeTitleHistory) error
+	GetOldTitlesForAnimes(animeIDs []string) (map[string][]string, error)
+	WithTx(tx *db.DB) AnimeTitleHistoryRepositoryImpl
+}
+
+type AnimeTitleHistoryRepository struct {
+	db *db.DB
+}
+
+func NewAnimeTitleHistoryRepository(db *db.DB) AnimeTitleHistoryRepositoryImpl {
+	return &AnimeTitleHistoryRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *AnimeTitleHistoryRepository) WithTx(tx *db.DB) AnimeTitleHistoryRepositoryImpl {
+	return &AnimeTitleHistoryRepository{db: tx}
+}
+
+func (r *AnimeTitleHistoryRepository) Create(entries []AnimeTitleHistory) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.DB.Create(&entries).Error
+}
+
+// GetOldTitlesForAnimes returns the distinct titles each anime had before, in the order they were
+// replaced. Anime that were never renamed are left out of the map
+func (r *AnimeTitleHistoryRepository) GetOldTitlesForAnimes(animeIDs []string) (map[string][]string, error) {
+	oldTitles := map[string][]string{}
+	if len(animeIDs) == 0 {
+		return oldTitles, nil
+	}
+
+	var entries []AnimeTitleHistory
+	err := r.db.DB.
+		Where("anime_id IN ? AND old_title IS NOT NULL AND old_title != ''", animeIDs).
+		Order("changed_at, id").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		key := entry.AnimeID + "\x00" + *entry.OldTitle
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		oldTitles[entry.AnimeID] = append(oldTitles[entry.AnimeID], *entry.OldTitle)
+	}
+	return oldTitles, nil
+}
@@ -0,0 +1,78 @@
+package anime_title_history_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+)
+
+func setupTestDB(t *testing.T) *db.DB {
+	cfg := &config.DBConfig{
+		Host:     "localhost",
+		Port:     3306,
+		User:     "weeb",
+		Password: "mysecretpassword",
+		DataBase: "weeb",
+		SSLMode:  "false",
+	}
+
+	database := db.NewDB(*cfg)
+	require.NotNil(t, database)
+
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	err = sqlDB.Ping()
+	require.NoError(t, err, "Database should be accessible")
+
+	return database
+}
+
+func TestAnimeTitleHistoryRepository_GetOldTitlesForAnimes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	database := setupTestDB(t)
+	animeRepo := anime.NewAnimeRepository(database)
+	historyRepo := anime_title_history.NewAnimeTitleHistoryRepository(database)
+
+	// Clean up test data, history rows are removed with their anime
+	cleanup := func() {
+		database.DB.Where("id LIKE ?", "test-anime-history-%").Delete(&anime.Anime{})
+	}
+	cleanup()
+	defer cleanup()
+
+	titleEn := "Attack on Titan"
+	require.NoError(t, animeRepo.Upsert(&anime.Anime{ID: "test-anime-history-001", TitleEn: &titleEn}))
+	require.NoError(t, animeRepo.Upsert(&anime.Anime{ID: "test-anime-history-002", TitleEn: &titleEn}))
+
+	str := func(value string) *string { return &value }
+	changedAt := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	err := historyRepo.Create([]anime_title_history.AnimeTitleHistory{
+		{AnimeID: "test-anime-history-001", Field: "title_en", OldTitle: str("Shingeki no Kyojin"), NewTitle: str("Attack on Titan"), ChangedAt: changedAt},
+		{AnimeID: "test-anime-history-001", Field: "title_romaji", OldTitle: str("Shingeki no Kyojin"), ChangedAt: changedAt.Add(time.Hour)},
+		{AnimeID: "test-anime-history-001", Field: "title_jp", OldTitle: str("Kyojin"), NewTitle: str("進撃の巨人"), ChangedAt: changedAt.Add(-time.Hour)},
+		{AnimeID: "test-anime-history-001", Field: "title_kanji", NewTitle: str("進撃の巨人"), ChangedAt: changedAt},
+	})
+	require.NoError(t, err)
+
+	t.Run("ReturnsDistinctOldTitlesInOrder", func(t *testing.T) {
+		oldTitles, err := historyRepo.GetOldTitlesForAnimes([]string{"test-anime-history-001", "test-anime-history-002"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"Kyojin", "Shingeki no Kyojin"}, oldTitles["test-anime-history-001"])
+		assert.NotContains(t, oldTitles, "test-anime-history-002", "anime that were never renamed are left out")
+	})
+
+	t.Run("CreateWithoutEntries", func(t *testing.T) {
+		assert.NoError(t, historyRepo.Create(nil))
+	})
+}
@@ -0,0 +1,22 @@
+package dlq_resolution
+
+import "time"
+
+type ResolutionAction string
+
+const (
+	ResolutionReplayed  ResolutionAction = "replayed"
+	ResolutionDiscarded ResolutionAction = "discarded"
+)
+
+type DLQResolution struct {
+	Topic      string           `gorm:"column:topic;primaryKey" json:"topic"`
+	Partition  int32            `gorm:"column:kafka_partition;primaryKey" json:"partition"`
+	Offset     int64            `gorm:"column:kafka_offset;primaryKey" json:"offset"`
+	Action     ResolutionAction `gorm:"column:action;not null" json:"action"`
+	ResolvedAt time.Time        `gorm:"column:resolved_at;autoCreateTime" json:"resolved_at"`
+}
+
+func (DLQResolution) TableName() string {
+	return "dlq_resolutions"
+}
@@ -0,0 +1,32 @@
+package dlq_resolution
+
+import (
+	"github.com/weeb-vip/anime-sync/internal/db"
+)
+
+type DLQResolutionRepositoryImpl interface {
+	Resolve(resolution *DLQResolution) error
+	FindByTopic(topic string) ([]DLQResolution, error)
+}
+
+type DLQResolutionRepository struct {
+	db *db.DB
+}
+
+func NewDLQResolutionRepository(db *db.DB) DLQResolutionRepositoryImpl {
+	return &DLQResolutionRepository{db: db}
+}
+
+// Resolve records what was done with a dead lettered message, replaying it again overwrites the record
+func (r *DLQResolutionRepository) Resolve(resolution *DLQResolution) error {
+	return r.db.DB.Save(resolution).Error
+}
+
+func (r *DLQResolutionRepository) FindByTopic(topic string) ([]DLQResolution, error) {
+	var resolutions []DLQResolution
+	err := r.db.DB.Where("topic = ?", topic).Find(&resolutions).Error
+	if err != nil {
+		return nil, err
+	}
+	return resolutions, nil
+}
@@ -0,0 +1,22 @@
+package outbox
+
+import "time"
+
+type Message struct {
+	ID      int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Topic   string `gorm:"column:topic;not null" json:"topic"`
+	Key     []byte `gorm:"column:message_key" json:"key"`
+	Payload []byte `gorm:"column:payload;not null" json:"payload"`
+	// Headers are published with the message, they carry the trace context of the change that wrote it
+	Headers   map[string]string `gorm:"column:headers;serializer:json" json:"headers"`
+	Attempts  int               `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError *string           `gorm:"column:last_error" json:"last_error"`
+	CreatedAt time.Time         `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	SentAt    *time.Time        `gorm:"column:sent_at" json:"sent_at"`
+	// DeadAt is set once kafka rejected the message for good, it is no longer published
+	DeadAt *time.Time `gorm:"column:dead_at" json:"dead_at"`
+}
+
+func (Message) TableName() string {
+	return "outbox"
+}
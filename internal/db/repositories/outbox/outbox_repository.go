@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+)
+
+// relayLockName is the MySQL named lock relays hold while they publish a batch
+const relayLockName = "anime_sync_outbox_relay"
+
+type OutboxRepositoryImpl interface {
+	Enqueue(message *Message) error
+	EnqueueMany(messages []*Message) error
+	TryLock(ctx context.Context) (unlock func(), locked bool, err error)
+	FetchPending(limit int) ([]Message, error)
+	MarkSent(ids []int64) error
+	MarkFailed(id int64, cause error) error
+	MarkDead(id int64, cause error) error
+	DeleteSent(before time.Time, limit int) (int64, error)
+	WithTx(tx *db.DB) OutboxRepositoryImpl
+}
+
+type OutboxRepository struct {
+	db *db.DB
+}
+
+func NewOutboxRepository(db *db.DB) OutboxRepositoryImpl {
+	return &OutboxRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *OutboxRepository) WithTx(tx *db.DB) OutboxRepositoryImpl {
+	return &OutboxRepository{db: tx}
+}
+
+func (r *OutboxRepository) Enqueue(message *Message) error {
+	return r.db.DB.Create(message).Error
+}
+
+// EnqueueMany writes several messages with one multi row INSERT, they are relayed in the given order
+func (r *OutboxRepository) EnqueueMany(messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return r.db.DB.Create(&messages).Error
+}
+
+// TryLock takes the named lock relays take turns with, without waiting for it. The lock belongs to
+// the session, so it is taken on a connection of its own that is kept until unlock. Outbox rows are
+// not locked, processors keep enqueueing while a relay publishes
+func (r *OutboxRepository) TryLock(ctx context.Context) (func(), bool, error) {
+	sqlDB, err := r.db.DB.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", relayLockName).Scan(&locked)
+	if err != nil || locked.Int64 != 1 {
+		conn.Close()
+		return nil, false, err
+	}
+
+	return func() {
+		// closing the connection releases the lock as well, when RELEASE_LOCK fails
+		conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", relayLockName)
+		conn.Close()
+	}, true, nil
+}
+
+// FetchPending returns the oldest unsent messages that are not dead lettered. It takes no locks,
+// callers hold the TryLock lock so relays never publish messages out of order
+func (r *OutboxRepository) FetchPending(limit int) ([]Message, error) {
+	var messages []Message
+	err := r.db.DB.
+		Where("sent_at IS NULL AND dead_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *OutboxRepository) MarkSent(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.DB.Model(&Message{}).Where("id IN ?", ids).Update("sent_at", time.Now()).Error
+}
+
+func (r *OutboxRepository) MarkFailed(id int64, cause error) error {
+	lastError := cause.Error()
+	return r.db.DB.Model(&Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": lastError,
+	}).Error
+}
+
+// MarkDead counts the failed attempt and dead letters the message, FetchPending no longer returns it
+func (r *OutboxRepository) MarkDead(id int64, cause error) error {
+	lastError := cause.Error()
+	return r.db.DB.Model(&Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": lastError,
+		"dead_at":    time.Now(),
+	}).Error
+}
+
+// DeleteSent deletes up to limit messages sent before the given time, oldest first. Dead lettered
+// messages are kept
+func (r *OutboxRepository) DeleteSent(before time.Time, limit int) (int64, error) {
+	result := r.db.DB.
+		Where("sent_at < ?", before).
+		Order("id").
+		Limit(limit).
+		Delete(&Message{})
+	return result.RowsAffected, result.Error
+}
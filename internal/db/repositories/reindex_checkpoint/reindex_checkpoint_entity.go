@@ -0,0 +1,17 @@
+package reindex_checkpoint
+
+import "time"
+
+// ReindexCheckpoint is how far the reindex run called Name got through one table, rows are paged by id
+type ReindexCheckpoint struct {
+	Name        string     `gorm:"column:name;primaryKey" json:"name"`
+	Table       string     `gorm:"column:table_name;primaryKey" json:"table"`
+	LastID      string     `gorm:"column:last_id;not null" json:"last_id"`
+	Published   int64      `gorm:"column:published;not null" json:"published"`
+	CompletedAt *time.Time `gorm:"column:completed_at" json:"completed_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (ReindexCheckpoint) TableName() string {
+	return "reindex_checkpoints"
+}
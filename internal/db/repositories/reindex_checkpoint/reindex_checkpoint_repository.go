@@ -0,0 +1,38 @@
+package reindex_checkpoint
+
+import (
+	"errors"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+)
+
+type ReindexCheckpointRepositoryImpl interface {
+	Find(name string, table string) (*ReindexCheckpoint, error)
+	Save(checkpoint *ReindexCheckpoint) error
+}
+
+type ReindexCheckpointRepository struct {
+	db *db.DB
+}
+
+func NewReindexCheckpointRepository(db *db.DB) ReindexCheckpointRepositoryImpl {
+	return &ReindexCheckpointRepository{db: db}
+}
+
+// Find returns the checkpoint of the run for table, or nil when the run never got to it
+func (r *ReindexCheckpointRepository) Find(name string, table string) (*ReindexCheckpoint, error) {
+	var checkpoint ReindexCheckpoint
+	err := r.db.DB.Where("name = ? AND table_name = ?", name, table).First(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+func (r *ReindexCheckpointRepository) Save(checkpoint *ReindexCheckpoint) error {
+	return r.db.DB.Save(checkpoint).Error
+}
@@ -0,0 +1,30 @@
+package sync_position
+
+import "time"
+
+type SyncPosition struct {
+	Entity    string    `gorm:"column:entity;primaryKey" json:"entity"`
+	EntityID  string    `gorm:"column:entity_id;primaryKey" json:"entity_id"`
+	Lsn       int64     `gorm:"column:lsn;not null;default:0" json:"lsn"`
+	TsMs      int64     `gorm:"column:ts_ms;not null;default:0" json:"ts_ms"`
+	TxID      int64     `gorm:"column:tx_id;not null;default:0" json:"tx_id"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (SyncPosition) TableName() string {
+	return "sync_positions"
+}
+
+// IsZero reports whether the position carries no source information, e.g. for events without a debezium source block
+func (p SyncPosition) IsZero() bool {
+	return p.Lsn == 0 && p.TsMs == 0
+}
+
+// OlderThan reports whether p comes before other in the source log. LSNs are compared when
+// both positions have one, otherwise the source timestamps are used
+func (p SyncPosition) OlderThan(other SyncPosition) bool {
+	if p.Lsn != 0 && other.Lsn != 0 {
+		return p.Lsn < other.Lsn
+	}
+	return p.TsMs < other.TsMs
+}
@@ -0,0 +1,133 @@
+package sync_position
+
+import (
+	"errors"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SyncPositionRepositoryImpl interface {
+	Find(entity string, entityID string) (*SyncPosition, error)
+	Advance(position *SyncPosition) (bool, error)
+	AdvanceMany(positions []*SyncPosition) ([]bool, error)
+	Check(position *SyncPosition, forceReplay bool) (bool, error)
+	WithTx(tx *db.DB) SyncPositionRepositoryImpl
+}
+
+type SyncPositionRepository struct {
+	db *db.DB
+}
+
+func NewSyncPositionRepository(db *db.DB) SyncPositionRepositoryImpl {
+	return &SyncPositionRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *SyncPositionRepository) WithTx(tx *db.DB) SyncPositionRepositoryImpl {
+	return &SyncPositionRepository{db: tx}
+}
+
+// Find returns the stored position of the row, or nil when none was recorded yet.
+// The row is locked until the surrounding transaction ends
+func (r *SyncPositionRepository) Find(entity string, entityID string) (*SyncPosition, error) {
+	var position SyncPosition
+	err := r.db.DB.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("entity = ? AND entity_id = ?", entity, entityID).
+		First(&position).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// Advance stores position when it is newer than the recorded one and reports whether it was older,
+// i.e. the event is stale. The stored position never moves backwards. Run it in the transaction
+// that applies the event so concurrent events for the same row are serialized
+func (r *SyncPositionRepository) Advance(position *SyncPosition) (bool, error) {
+	stored, err := r.Find(position.Entity, position.EntityID)
+	if err != nil {
+		return false, err
+	}
+
+	if stored != nil && position.OlderThan(*stored) {
+		return true, nil
+	}
+
+	err = r.db.DB.Save(position).Error
+	if err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// Check advances the position of the row and reports whether its event has to be skipped because a
+// newer one was already applied. With forceReplay stale events are not skipped, the stored position
+// is not moved back either way. Zero positions are never stale
+func (r *SyncPositionRepository) Check(position *SyncPosition, forceReplay bool) (bool, error) {
+	if position.IsZero() {
+		return false, nil
+	}
+
+	stale, err := r.Advance(position)
+	if err != nil {
+		return false, err
+	}
+	return stale && !forceReplay, nil
+}
+
+// AdvanceMany is Advance for many rows at once: the stored positions are read and locked with one
+// query and the newer ones stored with one multi row upsert. It reports for each position whether
+// it was older than the stored one. Zero positions are never stale and are not stored
+func (r *SyncPositionRepository) AdvanceMany(positions []*SyncPosition) ([]bool, error) {
+	stale := make([]bool, len(positions))
+
+	var keys [][]interface{}
+	for _, position := range positions {
+		if !position.IsZero() {
+			keys = append(keys, []interface{}{position.Entity, position.EntityID})
+		}
+	}
+	if len(keys) == 0 {
+		return stale, nil
+	}
+
+	var stored []SyncPosition
+	err := r.db.DB.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("(entity, entity_id) IN ?", keys).
+		Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+	storedByKey := make(map[[2]string]SyncPosition, len(stored))
+	for _, position := range stored {
+		storedByKey[[2]string{position.Entity, position.EntityID}] = position
+	}
+
+	var newer []*SyncPosition
+	for i, position := range positions {
+		if position.IsZero() {
+			continue
+		}
+		if storedPosition, ok := storedByKey[[2]string{position.Entity, position.EntityID}]; ok && position.OlderThan(storedPosition) {
+			stale[i] = true
+			continue
+		}
+		newer = append(newer, position)
+	}
+	if len(newer) == 0 {
+		return stale, nil
+	}
+
+	err = r.db.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&newer).Error
+	if err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
@@ -0,0 +1,175 @@
+package sync_position_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
+)
+
+func setupTestDB(t *testing.T) *db.DB {
+	cfg := &config.DBConfig{
+		Host:     "localhost",
+		Port:     3306,
+		User:     "weeb",
+		Password: "mysecretpassword",
+		DataBase: "weeb",
+		SSLMode:  "false",
+	}
+
+	database := db.NewDB(*cfg)
+	require.NotNil(t, database)
+
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	err = sqlDB.Ping()
+	require.NoError(t, err, "Database should be accessible")
+
+	return database
+}
+
+func TestSyncPosition_OlderThan(t *testing.T) {
+	tests := []struct {
+		name     string
+		position sync_position.SyncPosition
+		other    sync_position.SyncPosition
+		older    bool
+	}{
+		{"lower lsn", sync_position.SyncPosition{Lsn: 10, TsMs: 200}, sync_position.SyncPosition{Lsn: 20, TsMs: 100}, true},
+		{"higher lsn", sync_position.SyncPosition{Lsn: 30, TsMs: 100}, sync_position.SyncPosition{Lsn: 20, TsMs: 200}, false},
+		{"same lsn", sync_position.SyncPosition{Lsn: 20, TsMs: 100}, sync_position.SyncPosition{Lsn: 20, TsMs: 200}, false},
+		{"missing lsn falls back to ts_ms", sync_position.SyncPosition{TsMs: 100}, sync_position.SyncPosition{Lsn: 20, TsMs: 200}, true},
+		{"newer ts_ms without lsn", sync_position.SyncPosition{TsMs: 300}, sync_position.SyncPosition{TsMs: 200}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.older, tt.position.OlderThan(tt.other))
+		})
+	}
+}
+
+func TestSyncPositionRepository_Advance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	database := setupTestDB(t)
+	repo := sync_position.NewSyncPositionRepository(database)
+	ctx := context.Background()
+
+	entityID := "test-sync-position-advance"
+	t.Cleanup(func() {
+		database.DB.Where("entity_id LIKE ?", "test-sync-position-%").Delete(&sync_position.SyncPosition{})
+	})
+
+	advance := func(lsn int64) bool {
+		var stale bool
+		err := database.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			var err error
+			stale, err = repo.WithTx(tx).Advance(&sync_position.SyncPosition{Entity: "anime", EntityID: entityID, Lsn: lsn, TsMs: lsn})
+			return err
+		})
+		require.NoError(t, err)
+		return stale
+	}
+
+	assert.False(t, advance(100), "first event is applied")
+	assert.False(t, advance(200), "newer event is applied")
+	assert.True(t, advance(150), "older event is stale")
+	assert.False(t, advance(200), "redelivered event is applied again")
+
+	stored, err := repo.Find("anime", entityID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, int64(200), stored.Lsn, "stored position never moves back")
+
+	missing, err := repo.Find("anime", "test-sync-position-missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestSyncPositionRepository_Check(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	database := setupTestDB(t)
+	repo := sync_position.NewSyncPositionRepository(database)
+	ctx := context.Background()
+
+	entityID := "test-sync-position-check"
+	t.Cleanup(func() {
+		database.DB.Where("entity_id LIKE ?", "test-sync-position-%").Delete(&sync_position.SyncPosition{})
+	})
+
+	check := func(lsn int64, forceReplay bool) bool {
+		var stale bool
+		err := database.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			var err error
+			stale, err = repo.WithTx(tx).Check(&sync_position.SyncPosition{Entity: "anime", EntityID: entityID, Lsn: lsn, TsMs: lsn}, forceReplay)
+			return err
+		})
+		require.NoError(t, err)
+		return stale
+	}
+
+	assert.False(t, check(0, false), "event without a position is applied")
+	assert.False(t, check(200, false), "first event is applied")
+	assert.True(t, check(150, false), "older event is skipped")
+	assert.False(t, check(150, true), "older event is replayed")
+
+	stored, err := repo.Find("anime", entityID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, int64(200), stored.Lsn, "replays don't move the stored position back")
+}
+
+func TestSyncPositionRepository_AdvanceMany(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	database := setupTestDB(t)
+	repo := sync_position.NewSyncPositionRepository(database)
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		database.DB.Where("entity_id LIKE ?", "test-sync-position-%").Delete(&sync_position.SyncPosition{})
+	})
+
+	_, err := repo.Advance(&sync_position.SyncPosition{Entity: "anime", EntityID: "test-sync-position-many-a", Lsn: 200, TsMs: 200})
+	require.NoError(t, err)
+
+	var stale []bool
+	err = database.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		var err error
+		stale, err = repo.WithTx(tx).AdvanceMany([]*sync_position.SyncPosition{
+			{Entity: "anime", EntityID: "test-sync-position-many-a", Lsn: 150, TsMs: 150},
+			{Entity: "anime", EntityID: "test-sync-position-many-b", Lsn: 100, TsMs: 100},
+			{Entity: "anime", EntityID: "test-sync-position-many-c"},
+		})
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false}, stale)
+
+	stored, err := repo.Find("anime", "test-sync-position-many-a")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, int64(200), stored.Lsn, "stale position is not stored")
+
+	stored, err = repo.Find("anime", "test-sync-position-many-b")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, int64(100), stored.Lsn)
+
+	missing, err := repo.Find("anime", "test-sync-position-many-c")
+	require.NoError(t, err)
+	assert.Nil(t, missing, "zero positions are not recorded")
+}
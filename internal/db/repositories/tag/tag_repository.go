@@ -9,6 +9,7 @@ type TagRepositoryImpl interface {
 	FindByName(name string) (*Tag, error)
 	FindByNames(names []string) ([]Tag, error)
 	Create(tag *Tag) error
+	WithTx(tx *db.DB) TagRepositoryImpl
 }
 
 type TagRepository struct {
@@ -19,6 +20,11 @@ func NewTagRepository(db *db.DB) TagRepositoryImpl {
 	return &TagRepository{db: db}
 }
 
+// WithTx returns a repository bound to the given transaction
+func (r *TagRepository) WithTx(tx *db.DB) TagRepositoryImpl {
+	return &TagRepository{db: tx}
+}
+
 func (r *TagRepository) FindOrCreate(name string) (*Tag, error) {
 	var tag Tag
 	err := r.db.DB.Where("name = ?", name).FirstOrCreate(&tag, Tag{Name: name}).Error
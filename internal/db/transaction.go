@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// Transactor runs a unit of work inside a database transaction
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context, tx *DB) error) error
+}
+
+// Transaction runs fn in a transaction, the *DB handed to fn is bound to it and is committed
+// when fn returns nil or rolled back when it returns an error. The ctx handed to fn carries
+// the transaction as well so it can be picked up with TxFromCtx
+func (d *DB) Transaction(ctx context.Context, fn func(ctx context.Context, tx *DB) error) error {
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txDB := &DB{DB: tx}
+		return fn(WithTx(ctx, txDB), txDB)
+	})
+}
+
+// WithTx returns a copy of ctx carrying tx
+func WithTx(ctx context.Context, tx *DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromCtx returns the transaction carried by ctx, or nil when there is none
+func TxFromCtx(ctx context.Context) *DB {
+	if tx, ok := ctx.Value(txKey{}).(*DB); ok {
+		return tx
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package eventing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/metrics"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// idlePollTimeout is how long the batch consumer waits for a message while no batch is pending
+const idlePollTimeout = time.Second
+
+// BatchHandler applies messages of one partition together, in their order
+type BatchHandler func(ctx context.Context, messages []*kafka.Message) error
+
+// kafkaConsumer is the part of the Kafka consumer the batch consumer uses
+type kafkaConsumer interface {
+	SubscribeTopics(topics []string, rebalanceCb kafka.RebalanceCb) error
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	Close() error
+}
+
+// BatchOptions decides which messages are batched and when a batch is applied
+type BatchOptions struct {
+	Size int
+	Wait time.Duration
+	// Batchable reports whether a message goes to the batch handler
+	Batchable func(message *kafka.Message) bool
+}
+
+// partitionBatch holds the messages of a partition waiting to be applied
+type partitionBatch struct {
+	messages []*kafka.Message
+	started  time.Time
+}
+
+// batchConsumer consumes topics like the Kafka driver, but collects the messages the batch handler
+// takes per partition. A batch is applied once it holds Size messages or its first message waited
+// Wait. Other messages are handled one by one, after the batch collected before them. Offsets are
+// only committed once every message up to them was applied, so a crash replays the open batches
+type batchConsumer struct {
+	consumer    kafkaConsumer
+	shutdown    *shutdown
+	options     BatchOptions
+	handle      MessageHandler
+	handleBatch BatchHandler
+
+	// batches are keyed by partitionKey
+	batches map[string]*partitionBatch
+	// rebalanceErr is the error of applying the batches of revoked partitions
+	rebalanceErr error
+}
+
+func newBatchConsumer(consumer kafkaConsumer, shutdown *shutdown, options BatchOptions, handle MessageHandler, handleBatch BatchHandler) *batchConsumer {
+	return &batchConsumer{
+		consumer:    consumer,
+		shutdown:    shutdown,
+		options:     options,
+		handle:      handle,
+		handleBatch: handleBatch,
+		batches:     map[string]*partitionBatch{},
+	}
+}
+
+// newKafkaConsumer creates a consumer of the group in kafkaConfig that leaves committing to the caller
+func newKafkaConsumer(kafkaConfig *epKafka.KafkaConfig) (*kafka.Consumer, error) {
+	cfg := epKafka.GetKafkaConsumerConfig(*kafkaConfig)
+	//nolint:errcheck
+	_ = cfg.SetKey("enable.auto.commit", false)
+
+	consumer, err := kafka.NewConsumer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	return consumer, nil
+}
+
+// consumeBatches consumes topics with a batch consumer configured by cfg until the shutdown signal
+func consumeBatches(cfg config.Config, shutdown *shutdown, kafkaConfig *epKafka.KafkaConfig, topics []string, handle MessageHandler, handleBatch BatchHandler, batchable func(message *kafka.Message) bool) error {
+	consumer, err := newKafkaConsumer(kafkaConfig)
+	if err != nil {
+		return err
+	}
+
+	batchConsumer := newBatchConsumer(consumer, shutdown, BatchOptions{
+		Size:      cfg.BatchConfig.Size,
+		Wait:      cfg.BatchConfig.Wait(),
+		Batchable: batchable,
+	}, handle, handleBatch)
+
+	return shutdown.run(func(ctx context.Context) error {
+		return batchConsumer.Run(ctx, topics)
+	})
+}
+
+// Run consumes topics until ctx is cancelled or a message fails. On cancellation the pending
+// batches are applied and committed before it returns
+func (c *batchConsumer) Run(ctx context.Context, topics []string) error {
+	defer c.consumer.Close()
+
+	err := c.consumer.SubscribeTopics(topics, func(_ *kafka.Consumer, event kafka.Event) error {
+		// the partitions are handed to another consumer, which starts at the committed offsets
+		if revoked, ok := event.(kafka.RevokedPartitions); ok && c.rebalanceErr == nil {
+			c.rebalanceErr = c.flushPartitions(ctx, revoked.Partitions)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return c.flushAll(ctx)
+		default:
+		}
+
+		message, err := c.consumer.ReadMessage(c.pollTimeout())
+		if c.rebalanceErr != nil {
+			return c.rebalanceErr
+		}
+		if err != nil {
+			kafkaErr, ok := err.(kafka.Error)
+			if !ok || !(kafkaErr.IsRetriable() || kafkaErr.Code() == kafka.ErrTimedOut) {
+				return fmt.Errorf("read error: %w", err)
+			}
+		} else if message != nil && message.Value != nil {
+			if err := c.add(ctx, message); err != nil {
+				return err
+			}
+		}
+
+		if err := c.flushDue(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// add queues a batchable message, any other message is handled right away after the batch of its partition
+func (c *batchConsumer) add(ctx context.Context, message *kafka.Message) error {
+	key := partitionOf(message.TopicPartition)
+
+	if !c.options.Batchable(message) {
+		if err := c.flush(ctx, key); err != nil {
+			return err
+		}
+
+		handlerCtx, cancel := c.shutdown.handlerContext(ctx)
+		defer cancel()
+		if err := c.handle(handlerCtx, message); err != nil {
+			return err
+		}
+		return c.commit(message)
+	}
+
+	batch, ok := c.batches[key]
+	if !ok {
+		batch = &partitionBatch{started: time.Now()}
+		c.batches[key] = batch
+	}
+	batch.messages = append(batch.messages, message)
+
+	if len(batch.messages) >= c.options.Size {
+		return c.flush(ctx, key)
+	}
+	return nil
+}
+
+// flush applies and commits the pending batch of a partition. When the batch fails as a whole its
+// messages are handled one by one, so a single bad message is retried and dead lettered on its own
+func (c *batchConsumer) flush(ctx context.Context, key string) error {
+	log := logger.FromCtx(ctx)
+
+	batch, ok := c.batches[key]
+	if !ok {
+		return nil
+	}
+	delete(c.batches, key)
+
+	handlerCtx, cancel := c.shutdown.handlerContext(ctx)
+	defer cancel()
+
+	err := c.handleBatch(handlerCtx, batch.messages)
+	if err != nil {
+		log.Warn("Failed to apply batch, handling its messages one by one", zap.String("partition", key), zap.Int("messages", len(batch.messages)), zap.Error(err))
+		for _, message := range batch.messages {
+			if err := c.handle(handlerCtx, message); err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.commit(batch.messages[len(batch.messages)-1])
+}
+
+// flushDue applies the batches whose first message waited long enough
+func (c *batchConsumer) flushDue(ctx context.Context) error {
+	for key, batch := range c.batches {
+		if time.Since(batch.started) < c.options.Wait {
+			continue
+		}
+		if err := c.flush(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *batchConsumer) flushAll(ctx context.Context) error {
+	for key := range c.batches {
+		if err := c.flush(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *batchConsumer) flushPartitions(ctx context.Context, partitions []kafka.TopicPartition) error {
+	for _, partition := range partitions {
+		if err := c.flush(ctx, partitionOf(partition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollTimeout waits at most until the oldest pending batch is due
+func (c *batchConsumer) pollTimeout() time.Duration {
+	timeout := idlePollTimeout
+	for _, batch := range c.batches {
+		if remaining := c.options.Wait - time.Since(batch.started); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return max(timeout, time.Millisecond)
+}
+
+// commit stores the offset after message as the position of its partition
+func (c *batchConsumer) commit(message *kafka.Message) error {
+	_, err := c.consumer.CommitOffsets([]kafka.TopicPartition{{
+		Topic:     message.TopicPartition.Topic,
+		Partition: message.TopicPartition.Partition,
+		Offset:    message.TopicPartition.Offset + 1,
+	}})
+	if err != nil {
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+func partitionOf(partition kafka.TopicPartition) string {
+	return partitionKey(topicOf(partition), partition.Partition)
+}
+
+func topicOf(partition kafka.TopicPartition) string {
+	if partition.Topic == nil {
+		return ""
+	}
+	return *partition.Topic
+}
+
+// NewBatchHandler decodes the Debezium payloads of a batch and applies them with process. Metrics are
+// only recorded for applied batches, messages of a failed batch are recorded when handled one by one
+func NewBatchHandler[M any](processorName string, process func(ctx context.Context, payloads []M) error) BatchHandler {
+	entity := metricsEntity(processorName)
+
+	return func(ctx context.Context, messages []*kafka.Message) (err error) {
+		topic := topicOf(messages[0].TopicPartition)
+		ctx, span := tracing.StartBatchConsumer(ctx, topic, messages)
+		defer func() { tracing.End(span, err) }()
+
+		payloads := make([]M, len(messages))
+		for i, message := range messages {
+			var debeziumMessage struct {
+				Payload M `json:"payload"`
+			}
+			if err := json.Unmarshal(message.Value, &debeziumMessage); err != nil {
+				return err
+			}
+			payloads[i] = debeziumMessage.Payload
+		}
+
+		start := time.Now()
+		if err := process(ctx, payloads); err != nil {
+			return err
+		}
+
+		duration := time.Since(start) / time.Duration(len(messages))
+		for _, message := range messages {
+			metrics.ObserveProcessed(entity, messageAction(message), duration, nil)
+		}
+		return nil
+	}
+}
+
+// IsSnapshot reports whether message is a Debezium event read while taking a snapshot
+func IsSnapshot(message *kafka.Message) bool {
+	var envelope struct {
+		Payload struct {
+			Source struct {
+				Snapshot string `json:"snapshot"`
+			} `json:"source"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(message.Value, &envelope); err != nil {
+		return false
+	}
+
+	snapshot := envelope.Payload.Source.Snapshot
+	return snapshot != "" && snapshot != "false"
+}
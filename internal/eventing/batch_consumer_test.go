@@ -0,0 +1,191 @@
+package eventing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+// fakeConsumer hands out its messages in order and records what the batch consumer did with them
+type fakeConsumer struct {
+	mu       sync.Mutex
+	messages []*kafka.Message
+	events   []string
+}
+
+func (c *fakeConsumer) SubscribeTopics(topics []string, rebalanceCb kafka.RebalanceCb) error {
+	return nil
+}
+
+func (c *fakeConsumer) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	c.mu.Lock()
+	if len(c.messages) > 0 {
+		message := c.messages[0]
+		c.messages = c.messages[1:]
+		c.mu.Unlock()
+		return message, nil
+	}
+	c.mu.Unlock()
+
+	time.Sleep(min(timeout, 5*time.Millisecond))
+	return nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)
+}
+
+func (c *fakeConsumer) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	for _, offset := range offsets {
+		c.record(fmt.Sprintf("commit %d/%d", offset.Partition, offset.Offset))
+	}
+	return offsets, nil
+}
+
+func (c *fakeConsumer) Close() error { return nil }
+
+func (c *fakeConsumer) record(event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+}
+
+// drained reports whether every message was read, the last one is added before the consumer checks for shutdown
+func (c *fakeConsumer) drained() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.messages) == 0
+}
+
+func (c *fakeConsumer) recorded() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.events...)
+}
+
+func TestBatchConsumer(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	topic := "anime-db.public.anime"
+
+	message := func(partition int32, offset int64, snapshot bool) *kafka.Message {
+		source := `{"snapshot":"false"}`
+		if snapshot {
+			source = `{"snapshot":"true"}`
+		}
+		return &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)},
+			Value:          []byte(`{"payload":{"before":null,"after":{"id":"1"},"source":` + source + `}}`),
+		}
+	}
+	ids := func(messages []*kafka.Message) string {
+		var offsets []string
+		for _, message := range messages {
+			offsets = append(offsets, fmt.Sprintf("%d/%d", message.TopicPartition.Partition, message.TopicPartition.Offset))
+		}
+		return strings.Join(offsets, ",")
+	}
+
+	// run consumes messages until wait reports true, then stops the consumer like a shutdown does
+	run := func(t *testing.T, options BatchOptions, batchErr error, messages []*kafka.Message, wait func(events []string) bool) []string {
+		consumer := &fakeConsumer{messages: messages}
+		handle := func(ctx context.Context, message *kafka.Message) error {
+			consumer.record("handle " + ids([]*kafka.Message{message}))
+			return nil
+		}
+		handleBatch := func(ctx context.Context, messages []*kafka.Message) error {
+			if batchErr != nil {
+				return batchErr
+			}
+			consumer.record("batch " + ids(messages))
+			return nil
+		}
+		options.Batchable = IsSnapshot
+
+		shutdown := newShutdown(ctx, time.Minute)
+		defer shutdown.stop()
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- newBatchConsumer(consumer, shutdown, options, handle, handleBatch).Run(runCtx, []string{topic})
+		}()
+
+		require.Eventually(t, func() bool { return consumer.drained() && wait(consumer.recorded()) }, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+		return consumer.recorded()
+	}
+	consumed := func(count int) func(events []string) bool {
+		return func(events []string) bool { return len(events) >= count }
+	}
+
+	t.Run("CommitsFullBatchesAfterApplyingThem", func(t *testing.T) {
+		events := run(t, BatchOptions{Size: 2, Wait: time.Hour}, nil, []*kafka.Message{
+			message(0, 0, true), message(0, 1, true), message(0, 2, true),
+		}, consumed(2))
+
+		assert.Equal(t, []string{
+			"batch 0/0,0/1", "commit 0/2",
+			// applied when the consumer stops
+			"batch 0/2", "commit 0/3",
+		}, events)
+	})
+
+	t.Run("LiveEventAppliesPendingBatchFirst", func(t *testing.T) {
+		events := run(t, BatchOptions{Size: 10, Wait: time.Hour}, nil, []*kafka.Message{
+			message(0, 0, true), message(0, 1, true), message(0, 2, false), message(0, 3, true),
+		}, consumed(4))
+
+		assert.Equal(t, []string{
+			"batch 0/0,0/1", "commit 0/2",
+			"handle 0/2", "commit 0/3",
+			"batch 0/3", "commit 0/4",
+		}, events)
+	})
+
+	t.Run("AppliesIncompleteBatchAfterWait", func(t *testing.T) {
+		events := run(t, BatchOptions{Size: 10, Wait: 20 * time.Millisecond}, nil, []*kafka.Message{
+			message(0, 0, true),
+		}, consumed(2))
+
+		assert.Equal(t, []string{"batch 0/0", "commit 0/1"}, events)
+	})
+
+	t.Run("BatchesPerPartition", func(t *testing.T) {
+		events := run(t, BatchOptions{Size: 2, Wait: time.Hour}, nil, []*kafka.Message{
+			message(0, 0, true), message(1, 0, true), message(0, 1, true),
+		}, consumed(2))
+
+		require.Len(t, events, 4)
+		assert.Equal(t, []string{"batch 0/0,0/1", "commit 0/2"}, events[:2])
+		assert.Equal(t, []string{"batch 1/0", "commit 1/1"}, events[2:])
+	})
+
+	t.Run("FailedBatchIsHandledOneByOne", func(t *testing.T) {
+		events := run(t, BatchOptions{Size: 2, Wait: time.Hour}, errors.New("deadlock found when trying to get lock"), []*kafka.Message{
+			message(0, 0, true), message(0, 1, true),
+		}, consumed(3))
+
+		assert.Equal(t, []string{"handle 0/0", "handle 0/1", "commit 0/2"}, events)
+	})
+}
+
+func TestIsSnapshot(t *testing.T) {
+	for value, expected := range map[string]bool{
+		`{"payload":{"source":{"snapshot":"true"}}}`:  true,
+		`{"payload":{"source":{"snapshot":"last"}}}`:  true,
+		`{"payload":{"source":{"snapshot":"false"}}}`: false,
+		`{"payload":{"source":{}}}`:                   false,
+		`not json`:                                    false,
+	} {
+		assert.Equal(t, expected, IsSnapshot(&kafka.Message{Value: []byte(value)}), value)
+	}
+}
@@ -0,0 +1,98 @@
+package eventing
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/middleware"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/metrics"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"github.com/weeb-vip/anime-sync/internal/services/dlq"
+	"go.uber.org/zap"
+)
+
+// retryHeaderKey is the header the backoff retry middleware counts retries in
+const retryHeaderKey = "retry"
+
+// notBeforeHeaderKey is the header the backoff retry middleware keeps the time a re-queued message is
+// due in, as unix milliseconds
+const notBeforeHeaderKey = "retry-not-before"
+
+type DeadLetterConfig struct {
+	// Topic is the dead letter topic, usually SourceTopic + "-dlq"
+	Topic string
+	// SourceTopic is the topic messages are replayed onto
+	SourceTopic string
+	// Processor names the processor that failed, for inspecting the dlq
+	Processor string
+	// MaxRetries must match the backoff retry middleware the dead letter middleware runs inside of
+	MaxRetries int
+}
+
+// DeadLetterMiddleware publishes messages that failed their last retry to the dead letter topic,
+// messages failing with a permanent error are published right away since retrying can't help.
+// It has to be added after the backoff retry middleware so it sees the error before the retry
+// middleware drops or re-queues the message
+type DeadLetterMiddleware[M any] struct {
+	driver drivers.Driver[*kafka.Message]
+	config DeadLetterConfig
+}
+
+func NewDeadLetterMiddleware[M any](driver drivers.Driver[*kafka.Message], config DeadLetterConfig) *DeadLetterMiddleware[M] {
+	return &DeadLetterMiddleware[M]{
+		driver: driver,
+		config: config,
+	}
+}
+
+func (d *DeadLetterMiddleware[M]) Process(ctx context.Context, data event.Event[*kafka.Message, M], next middleware.Handler[*kafka.Message, M]) (*event.Event[*kafka.Message, M], error) {
+	result, err := next(ctx, data)
+	if err == nil {
+		return result, nil
+	}
+
+	class := retryable.Classify(err)
+	retryCount, _ := strconv.Atoi(data.Headers[retryHeaderKey])
+	if class == retryable.Retriable && retryCount+1 < d.config.MaxRetries {
+		metrics.Retried(metricsEntity(d.config.Processor), messageAction(data.DriverMessage))
+		return result, err
+	}
+
+	log := logger.FromCtx(ctx)
+	if class == retryable.Permanent {
+		log.Warn("Permanent error, sending message to dead letter topic without retrying", zap.String("topic", d.config.Topic), zap.String("processor", d.config.Processor), zap.Error(err))
+	} else {
+		log.Warn("Retries exhausted, sending message to dead letter topic", zap.String("topic", d.config.Topic), zap.String("processor", d.config.Processor), zap.Int("retries", retryCount), zap.Error(err))
+	}
+
+	headers := make([]kafka.Header, 0, len(data.Headers)+6)
+	for k, v := range data.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	headers = append(headers,
+		kafka.Header{Key: dlq.HeaderError, Value: []byte(err.Error())},
+		kafka.Header{Key: dlq.HeaderErrorClass, Value: []byte(class)},
+		kafka.Header{Key: dlq.HeaderRetryCount, Value: []byte(strconv.Itoa(retryCount))},
+		kafka.Header{Key: dlq.HeaderProcessor, Value: []byte(d.config.Processor)},
+		kafka.Header{Key: dlq.HeaderSourceTopic, Value: []byte(d.config.SourceTopic)},
+		kafka.Header{Key: dlq.HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	produceErr := d.driver.Produce(ctx, d.config.Topic, &kafka.Message{
+		Key:     data.DriverMessage.Key,
+		Value:   data.DriverMessage.Value,
+		Headers: headers,
+	})
+	if produceErr != nil {
+		log.Error("Failed to send message to dead letter topic", zap.String("topic", d.config.Topic), zap.Error(produceErr))
+		return result, err
+	}
+	metrics.DeadLettered(metricsEntity(d.config.Processor), messageAction(data.DriverMessage), string(class))
+
+	return &data, nil
+}
@@ -0,0 +1,175 @@
+package eventing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/dlq"
+	"go.uber.org/zap"
+)
+
+// maxErrorWidth truncates errors in dlq list so each message stays on one line
+const maxErrorWidth = 80
+
+// DLQList prints the unresolved messages of the dead letter topic, or all of them with includeResolved
+func DLQList(w io.Writer, topic string, includeResolved bool) error {
+	return withDeadLetterQueue(func(ctx context.Context, queue dlq.DeadLetterQueue, topic string) error {
+		messages, err := queue.List(ctx, topic, includeResolved)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "REF\tPROCESSOR\tRETRIES\tFAILED AT\tSTATUS\tERROR")
+		for _, message := range messages {
+			failedAt := "-"
+			if message.FailedAt != nil {
+				failedAt = message.FailedAt.Format(time.RFC3339)
+			}
+			status := "pending"
+			if message.Resolution != nil {
+				status = string(*message.Resolution)
+			}
+			errorText := strings.ReplaceAll(message.Error, "\n", " ")
+			if len(errorText) > maxErrorWidth {
+				errorText = errorText[:maxErrorWidth-3] + "..."
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", message.Ref, message.Processor, message.RetryCount, failedAt, status, errorText)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%d message(s) in %s\n", len(messages), topic)
+		return nil
+	}, topic)
+}
+
+// DLQInspect prints one dead lettered message with its headers and original payload
+func DLQInspect(w io.Writer, topic string, ref string) error {
+	parsedRef, err := dlq.ParseRef(ref)
+	if err != nil {
+		return err
+	}
+
+	return withDeadLetterQueue(func(ctx context.Context, queue dlq.DeadLetterQueue, topic string) error {
+		message, err := queue.Inspect(ctx, topic, parsedRef)
+		if err != nil {
+			return err
+		}
+
+		status := "pending"
+		if message.Resolution != nil {
+			status = string(*message.Resolution)
+		}
+		fmt.Fprintf(w, "Ref:          %s\n", message.Ref)
+		fmt.Fprintf(w, "Source topic: %s\n", message.SourceTopic)
+		fmt.Fprintf(w, "Processor:    %s\n", message.Processor)
+		fmt.Fprintf(w, "Retries:      %d\n", message.RetryCount)
+		if message.FailedAt != nil {
+			fmt.Fprintf(w, "Failed at:    %s\n", message.FailedAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Status:       %s\n", status)
+		fmt.Fprintf(w, "Error:        %s\n", message.Error)
+		fmt.Fprintf(w, "Key:          %s\n", message.Key)
+		fmt.Fprintln(w, "Headers:")
+		for k, v := range message.Headers {
+			fmt.Fprintf(w, "  %s: %s\n", k, v)
+		}
+		fmt.Fprintln(w, "Value:")
+		fmt.Fprintln(w, string(message.Value))
+		return nil
+	}, topic)
+}
+
+// DLQReplay produces the referenced messages, or all unresolved ones with all, back onto their source topic
+func DLQReplay(w io.Writer, topic string, refs []string, all bool) error {
+	parsedRefs, err := parseRefs(refs, all)
+	if err != nil {
+		return err
+	}
+
+	return withDeadLetterQueue(func(ctx context.Context, queue dlq.DeadLetterQueue, topic string) error {
+		count, err := queue.Replay(ctx, topic, parsedRefs)
+		fmt.Fprintf(w, "%d message(s) replayed from %s\n", count, topic)
+		return err
+	}, topic)
+}
+
+// DLQDiscard marks the referenced messages, or all unresolved ones with all, as discarded
+func DLQDiscard(w io.Writer, topic string, refs []string, all bool) error {
+	parsedRefs, err := parseRefs(refs, all)
+	if err != nil {
+		return err
+	}
+
+	return withDeadLetterQueue(func(ctx context.Context, queue dlq.DeadLetterQueue, topic string) error {
+		count, err := queue.Discard(ctx, topic, parsedRefs)
+		fmt.Fprintf(w, "%d message(s) discarded from %s\n", count, topic)
+		return err
+	}, topic)
+}
+
+func parseRefs(refs []string, all bool) ([]dlq.Ref, error) {
+	if all == (len(refs) > 0) {
+		return nil, fmt.Errorf("pass either message references (partition:offset) or --all")
+	}
+
+	parsedRefs := make([]dlq.Ref, 0, len(refs))
+	for _, ref := range refs {
+		parsedRef, err := dlq.ParseRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		parsedRefs = append(parsedRefs, parsedRef)
+	}
+	return parsedRefs, nil
+}
+
+// withDeadLetterQueue builds the dlq service for the commands, topic defaults to KAFKA_TOPIC + RETRY_DLQ_TOPIC_SUFFIX
+func withDeadLetterQueue(fn func(ctx context.Context, queue dlq.DeadLetterQueue, topic string) error, topic string) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	if topic == "" {
+		topic = cfg.KafkaConfig.Topic + cfg.RetryConfig.DLQTopicSuffix
+	}
+
+	kafkaConfig := &epKafka.KafkaConfig{
+		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+		SaslMechanism:            nil,
+		SecurityProtocol:         nil,
+		Username:                 nil,
+		Password:                 nil,
+		ConsumerSessionTimeoutMs: nil,
+		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+		ClientID:                 nil,
+		Debug:                    nil,
+	}
+
+	driver := epKafka.NewKafkaDriver(kafkaConfig)
+	defer func(driver drivers.Driver[*kafka.Message]) {
+		err := driver.Close()
+		if err != nil {
+			log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+		}
+	}(driver)
+
+	database := db.NewDB(cfg.DBConfig)
+	reader := dlq.NewKafkaReader(cfg.KafkaConfig.BootstrapServers, cfg.KafkaConfig.ConsumerGroupName+"-dlq-reader")
+
+	return fn(ctx, dlq.NewDeadLetterQueue(database, reader, driver.Produce, cfg.RetryConfig.DLQTopicSuffix), topic)
+}
@@ -0,0 +1,175 @@
+package eventing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"github.com/weeb-vip/anime-sync/internal/services/character_staff_link_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/dlq"
+)
+
+func TestDeadLetterMiddleware(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	failing := func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+		return &data, errors.New(`parsing time "not-a-date" as "2006-01-02T15:04:05Z07:00"`)
+	}
+
+	newEvent := func(retry string) event.Event[*kafka.Message, map[string]any] {
+		headers := map[string]string{"trace": "abc"}
+		if retry != "" {
+			headers[retryHeaderKey] = retry
+		}
+		return event.Event[*kafka.Message, map[string]any]{
+			Headers:       headers,
+			DriverMessage: &kafka.Message{Key: []byte("anime-1"), Value: []byte(`{"payload":{}}`)},
+		}
+	}
+
+	config := DeadLetterConfig{
+		Topic:       "anime-db.public.anime-dlq",
+		SourceTopic: "anime-db.public.anime",
+		Processor:   "anime_processor",
+		MaxRetries:  3,
+	}
+
+	t.Run("ReturnsErrorWhileRetriesAreLeft", func(t *testing.T) {
+		driver := &fakeDriver{}
+		deadLetter := NewDeadLetterMiddleware[map[string]any](driver, config)
+
+		for _, retry := range []string{"", "1"} {
+			_, err := deadLetter.Process(ctx, newEvent(retry), failing)
+			require.Error(t, err)
+		}
+		assert.Empty(t, driver.produced)
+	})
+
+	t.Run("PublishesOnLastRetry", func(t *testing.T) {
+		driver := &fakeDriver{}
+		deadLetter := NewDeadLetterMiddleware[map[string]any](driver, config)
+
+		_, err := deadLetter.Process(ctx, newEvent("2"), failing)
+		require.NoError(t, err)
+
+		require.Len(t, driver.produced, 1)
+		produced := driver.produced[0]
+		assert.Equal(t, "anime-db.public.anime-dlq", produced.topic)
+		assert.Equal(t, []byte("anime-1"), produced.message.Key)
+		assert.Equal(t, []byte(`{"payload":{}}`), produced.message.Value)
+
+		headers := map[string]string{}
+		for _, header := range produced.message.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		assert.Equal(t, "abc", headers["trace"])
+		assert.Contains(t, headers[dlq.HeaderError], "not-a-date")
+		assert.Equal(t, string(retryable.Retriable), headers[dlq.HeaderErrorClass])
+		assert.Equal(t, "2", headers[dlq.HeaderRetryCount])
+		assert.Equal(t, "anime_processor", headers[dlq.HeaderProcessor])
+		assert.Equal(t, "anime-db.public.anime", headers[dlq.HeaderSourceTopic])
+		assert.NotEmpty(t, headers[dlq.HeaderFailedAt])
+	})
+
+	t.Run("PublishesPermanentErrorsRightAway", func(t *testing.T) {
+		driver := &fakeDriver{}
+		deadLetter := NewDeadLetterMiddleware[map[string]any](driver, config)
+
+		_, timeErr := time.Parse(time.RFC3339, "not-a-date")
+		_, err := deadLetter.Process(ctx, newEvent(""), func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+			return &data, fmt.Errorf("start date: %w", timeErr)
+		})
+		require.NoError(t, err)
+
+		require.Len(t, driver.produced, 1)
+		headers := map[string]string{}
+		for _, header := range driver.produced[0].message.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		assert.Equal(t, string(retryable.Permanent), headers[dlq.HeaderErrorClass])
+		assert.Equal(t, "0", headers[dlq.HeaderRetryCount])
+	})
+
+	t.Run("PassesThroughSuccess", func(t *testing.T) {
+		driver := &fakeDriver{}
+		deadLetter := NewDeadLetterMiddleware[map[string]any](driver, config)
+
+		_, err := deadLetter.Process(ctx, newEvent("2"), func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+			return &data, nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, driver.produced)
+	})
+}
+
+func TestUndecodablePayload(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	process := func(ctx context.Context, data event.Event[*kafka.Message, character_staff_link_processor.Payload]) (event.Event[*kafka.Message, character_staff_link_processor.Payload], error) {
+		t.Fatal("a payload that does not decode must not be processed")
+		return data, nil
+	}
+
+	for name, value := range map[string]string{
+		"NotJSON":      `not json`,
+		"WrongType":    `{"payload":{"after":{"id":1}}}`,
+		"Truncated":    `{"payload":{"after":`,
+		"PayloadArray": `{"payload":[]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			driver := &fakeDriver{}
+			handle := newTableHandlerFactory[character_staff_link_processor.Payload](driver, testRetryPolicy, "character_staff_link_processor", process)("links")
+
+			message := &kafka.Message{Key: []byte("link-1"), Value: []byte(value)}
+			require.NotPanics(t, func() {
+				require.NoError(t, handle(ctx, message))
+			})
+
+			require.Len(t, driver.produced, 1)
+			produced := driver.produced[0]
+			assert.Equal(t, "links-dlq", produced.topic)
+			assert.Equal(t, []byte(value), produced.message.Value)
+			headers := map[string]string{}
+			for _, header := range produced.message.Headers {
+				headers[header.Key] = string(header.Value)
+			}
+			assert.Equal(t, string(retryable.Permanent), headers[dlq.HeaderErrorClass])
+			assert.Equal(t, "links", headers[dlq.HeaderSourceTopic])
+		})
+	}
+
+	t.Run("LoggerWithoutResult", func(t *testing.T) {
+		logMiddleware := NewLoggerMiddleware[*kafka.Message, map[string]any]()
+		require.NotPanics(t, func() {
+			result, err := logMiddleware.Process(ctx, event.Event[*kafka.Message, map[string]any]{}, func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+				return nil, errors.New("broken")
+			})
+			assert.Nil(t, result)
+			assert.EqualError(t, err, "broken")
+		})
+	})
+}
+
+func TestDeadLetterProduceFailure(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	driver := &fakeDriver{produceErr: errors.New("broker unavailable")}
+	handle := newTableHandlerFactory[character_staff_link_processor.Payload](driver, testRetryPolicy, "character_staff_link_processor", func(ctx context.Context, data event.Event[*kafka.Message, character_staff_link_processor.Payload]) (event.Event[*kafka.Message, character_staff_link_processor.Payload], error) {
+		return data, retryable.NewPermanent(errors.New("invalid link"))
+	})("links")
+
+	err := handle(ctx, &kafka.Message{
+		Key:     []byte("link-1"),
+		Value:   []byte(`{"payload":{"after":{"id":"link-1"}}}`),
+		Headers: []kafka.Header{{Key: retryHeaderKey, Value: []byte("2")}},
+	})
+	require.Error(t, err, "a message that could not be dead lettered must not be committed")
+}
@@ -0,0 +1,80 @@
+package eventing
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type producedMessage struct {
+	topic   string
+	message *kafka.Message
+}
+
+// fakeDriver records produced messages and consumes messages like the Kafka driver does: the
+// context is checked between messages and a message is committed after its handler succeeded
+type fakeDriver struct {
+	mu        sync.Mutex
+	produced  []producedMessage
+	messages  []*kafka.Message
+	committed []*kafka.Message
+	// produceErr fails every produce when set
+	produceErr error
+}
+
+func (d *fakeDriver) Consume(ctx context.Context, topic string, handler func(context.Context, *kafka.Message, []byte) error) error {
+	for _, message := range d.messages {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		if err := handler(ctx, message, message.Value); err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		d.committed = append(d.committed, message)
+		d.mu.Unlock()
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (d *fakeDriver) Produce(ctx context.Context, topic string, message *kafka.Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.produceErr != nil {
+		return d.produceErr
+	}
+	d.produced = append(d.produced, producedMessage{topic: topic, message: message})
+	return nil
+}
+
+func (d *fakeDriver) CreateTopic(ctx context.Context, topic string) error { return nil }
+
+func (d *fakeDriver) Close() error { return nil }
+
+func (d *fakeDriver) ExtractEvent(data *kafka.Message) (*event.SubData[*kafka.Message], error) {
+	eventData := &event.SubData[*kafka.Message]{
+		DriverMessage: data,
+	}
+	headers := map[string]string{}
+	for _, v := range data.Headers {
+		headers[v.Key] = string(v.Value)
+	}
+	eventData.Headers = headers
+
+	msgByte, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(msgByte, &eventData.RawData)
+
+	return eventData, err
+}
@@ -0,0 +1,156 @@
+package eventing
+
+import (
+	"context"
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/ThatCatDev/ep/v2/processor"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/producer"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_relation_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_season_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/character_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/character_staff_link_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/episode_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/staff_processor"
+	"go.uber.org/zap"
+)
+
+// source tables as reported by Debezium in payload.source.table
+const (
+	TableAnime              = "anime"
+	TableEpisodes           = "episodes"
+	TableAnimeSeasons       = "anime_seasons"
+	TableAnimeCharacter     = "anime_character"
+	TableAnimeStaff         = "anime_staff"
+	TableCharacterStaffLink = "anime_character_staff_link"
+	TableAnimeRelations     = "anime_relations"
+)
+
+func EventingAllKafka(options ServeOptions) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
+	kafkaConfig := &epKafka.KafkaConfig{
+		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+		SaslMechanism:            nil,
+		SecurityProtocol:         nil,
+		Username:                 nil,
+		Password:                 nil,
+		ConsumerSessionTimeoutMs: nil,
+		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+		ClientID:                 kafkaClientID(cfg),
+		Debug:                    nil,
+	}
+
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
+	defer func(driver drivers.Driver[*kafka.Message]) {
+		err := driver.Close()
+		if err != nil {
+			log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+		} else {
+			log.Info("Kafka driver closed successfully")
+		}
+	}(driver)
+
+	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
+	stopOutboxRelay := startOutboxRelay(ctx, cfg, driver, database)
+	defer stopOutboxRelay()
+
+	imagePublisher := syncPublisher[anime_processor.ImagePayload](ctx, cfg, driver, database, cfg.KafkaConfig.ProducerTopic)
+
+	animeOptions := anime_processor.Options{NoErrorOnDelete: true, ForceReplay: cfg.SyncConfig.ForceReplay}
+	var animeProcessor anime_processor.AnimeProcessor
+	var animeBatchProcessor anime_processor.AnimeBatchProcessor
+	if cfg.BatchConfig.Enabled {
+		batchProducer := producer.NewKafkaBatchProducer(epKafka.GetKafkaConfig(*kafkaConfig))
+		defer batchProducer.Close()
+
+		animeBatchProcessor = anime_processor.NewAnimeBatchProcessor(animeOptions, database, syncBatchPublisher[anime_processor.ProducerPayload](cfg, batchProducer, database, cfg.KafkaConfig.AlgoliaTopic), syncBatchPublisher[anime_processor.ImagePayload](cfg, batchProducer, database, cfg.KafkaConfig.ProducerTopic))
+		animeProcessor = animeBatchProcessor
+	} else {
+		animeProcessor = anime_processor.NewAnimeProcessor(animeOptions, database, syncPublisher[anime_processor.ProducerPayload](ctx, cfg, driver, database, cfg.KafkaConfig.AlgoliaTopic), imagePublisher)
+	}
+	episodeProcessor := episode_processor.NewAnimeProcessor(episode_processor.Options{NoErrorOnDelete: true, ForceReplay: cfg.SyncConfig.ForceReplay}, database)
+	seasonProcessor := anime_season_processor.NewAnimeSeasonProcessor(anime_season_processor.Options{NoErrorOnDelete: true, ForceReplay: cfg.SyncConfig.ForceReplay}, database, syncPublisher[anime_season_processor.ProducerPayload](ctx, cfg, driver, database, cfg.KafkaConfig.AlgoliaTopic))
+	characterProcessor := character_processor.NewCharacterProcessor(character_processor.Options{NoErrorOnDelete: true}, database, imagePublisher)
+	staffProcessor := staff_processor.NewStaffProcessor(staff_processor.Options{NoErrorOnDelete: true}, database, syncPublisher[staff_processor.ProducerPayload](ctx, cfg, driver, database, cfg.KafkaConfig.AlgoliaTopic), imagePublisher)
+	linkProcessor := character_staff_link_processor.NewCharacterStaffLinkProcessor(character_staff_link_processor.Options{NoErrorOnDelete: true}, database)
+	relationProcessor := anime_relation_processor.NewAnimeRelationProcessor(anime_relation_processor.Options{
+		NoErrorOnDelete: true,
+		MaintainInverse: cfg.SyncConfig.MaintainInverseRelations,
+	}, database)
+
+	router := NewRouter(driver, ParseTopicTables(cfg.KafkaConfig.TopicTables)).
+		Register(TableAnime, newTableHandlerFactory[anime_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Anime), "anime_processor", animeProcessor.Process)).
+		Register(TableEpisodes, newTableHandlerFactory[episode_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Episode), "episode_processor", episodeProcessor.Process)).
+		Register(TableAnimeSeasons, newTableHandlerFactory[anime_season_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Season), "anime_season_processor", seasonProcessor.Process)).
+		Register(TableAnimeCharacter, newTableHandlerFactory[character_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Character), "character_processor", characterProcessor.Process)).
+		Register(TableAnimeStaff, newTableHandlerFactory[staff_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Staff), "staff_processor", staffProcessor.Process)).
+		Register(TableCharacterStaffLink, newTableHandlerFactory[character_staff_link_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.CharacterStaffLink.Or(linkRetryPolicy)), "character_staff_link_processor", linkProcessor.Process)).
+		Register(TableAnimeRelations, newTableHandlerFactory[anime_relation_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Relation), "anime_relation_processor", relationProcessor.Process))
+
+	topics := ParseTopics(cfg.KafkaConfig.Topics)
+	if len(topics) == 0 {
+		topics = []string{cfg.KafkaConfig.Topic}
+	}
+
+	var err error
+	if cfg.BatchConfig.Enabled {
+		router.
+			RegisterBatch(TableAnime, NewBatchHandler[anime_processor.Payload]("anime_processor", animeBatchProcessor.ProcessBatch)).
+			RegisterBatch(TableEpisodes, NewBatchHandler[episode_processor.Payload]("episode_processor", episodeProcessor.ProcessBatch))
+
+		log.Info("Starting Kafka batch router", zap.Strings("topics", topics), zap.Int("batchSize", cfg.BatchConfig.Size))
+		err = consumeBatches(cfg, shutdown, kafkaConfig, topics, router.Handle, router.DispatchBatch, router.Batchable)
+	} else if workers := options.workers(cfg); workers > 1 {
+		log.Info("Starting Kafka router", zap.Strings("topics", topics), zap.Int("workers", workers))
+		err = consumeInParallel(shutdown, kafkaConfig, topics, workers, router.Handle)
+	} else {
+		log.Info("Starting Kafka router", zap.Strings("topics", topics))
+		err = shutdown.run(func(ctx context.Context) error {
+			return router.Run(ctx, topics)
+		})
+	}
+
+	if err != nil {
+		log.Error("Error consuming messages", zap.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// newTableHandlerFactory wires a processor with the same middleware chain the single topic commands use
+func newTableHandlerFactory[M any](driver drivers.Driver[*kafka.Message], policy config.RetryPolicy, name string, process processor.Process[*kafka.Message, M]) HandlerFactory {
+	return func(topic string) MessageHandler {
+		backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[M](driver, policy, topic, name)
+
+		return NewMessageHandler[M](driver, process,
+			NewTracingMiddleware[M](topic, name).Process,
+			NewLoggerMiddleware[*kafka.Message, M]().Process,
+			traceStage[M]("retry", backoffRetryInstance.Process),
+			traceStage[M]("dead_letter", deadLetterInstance.Process),
+			traceStage[M]("transform", NewTransformMiddleware[*kafka.Message, M]().Process),
+			traceStage[M]("process", NewMetricsMiddleware[M](name).Process),
+		)
+	}
+}
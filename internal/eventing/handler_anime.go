@@ -10,19 +10,27 @@ import (
 	"github.com/weeb-vip/anime-sync/config"
 	"github.com/weeb-vip/anime-sync/internal/db"
 	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/metrics"
 	"github.com/weeb-vip/anime-sync/internal/producer"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/server"
 	"github.com/weeb-vip/anime-sync/internal/services/consumer"
 	"github.com/weeb-vip/anime-sync/internal/services/processor"
 	"github.com/weeb-vip/anime-sync/internal/services/pulsar_anime_postgres_processor"
 	"go.uber.org/zap"
+	"time"
 )
 
-func EventingAnime() error {
+func EventingAnime(options ServeOptions) error {
 	cfg := config.LoadConfigOrPanic()
 	ctx := context.Background()
 	log := logger.Get()
 	ctx = logger.WithCtx(ctx, log)
 
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
 	kafkaConfig := &epKafka.KafkaConfig{
 		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
 		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
@@ -47,23 +55,50 @@ func EventingAnime() error {
 	}(driver)
 
 	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startHealthServer(ctx, cfg, shutdown, map[string]server.Check{
+		"database": databaseCheck(database),
+	})
+	defer stopHealthServer()
 
 	posgresProcessorOptions := pulsar_anime_postgres_processor.Options{
 		NoErrorOnDelete: true,
 	}
 
-	algoliaProducer := producer.NewProducer[pulsar_anime_postgres_processor.ProducerPayload](ctx, cfg.PulsarConfig, cfg.PulsarConfig.ProducerAlgoliaTopic)
-	imageProducer := producer.NewProducer[pulsar_anime_postgres_processor.ImagePayload](ctx, cfg.PulsarConfig, cfg.PulsarConfig.ProducerImageTopic)
+	pulsarClient, err := producer.NewPulsarClient(cfg.PulsarConfig)
+	if err != nil {
+		log.Error(fmt.Sprintf("Error creating pulsar client: %v", err))
+		return err
+	}
+	defer pulsarClient.Close()
 
-	postgresProcessor := pulsar_anime_postgres_processor.NewPulsarAnimePostgresProcessor(posgresProcessorOptions, database, algoliaProducer, imageProducer, kafkaProducer(ctx, driver, cfg.KafkaConfig.ProducerTopic))
+	algoliaPublisher := publisher.NewPulsar[pulsar_anime_postgres_processor.ProducerPayload](producer.NewProducer[pulsar_anime_postgres_processor.ProducerPayload](pulsarClient, cfg.PulsarConfig.ProducerAlgoliaTopic))
+	imagePublisher := publisher.NewKafka[pulsar_anime_postgres_processor.ImagePayload](kafkaProducer(ctx, driver, cfg.KafkaConfig.ProducerTopic))
 
-	messageProcessor := processor.NewProcessor[pulsar_anime_postgres_processor.Payload]()
+	postgresProcessor := pulsar_anime_postgres_processor.NewPulsarAnimePostgresProcessor(posgresProcessorOptions, database, algoliaPublisher, imagePublisher)
 
-	animeConsumer := consumer.NewConsumer[pulsar_anime_postgres_processor.Payload](ctx, cfg.PulsarConfig)
+	retryPolicy := cfg.RetryConfig.Resolve(cfg.RetryConfig.Anime)
+	messageProcessor := processor.NewProcessorWithOptions[pulsar_anime_postgres_processor.Payload](pulsarProcessorOptions(retryPolicy))
+
+	animeConsumer, err := consumer.NewConsumer[pulsar_anime_postgres_processor.Payload](ctx, cfg.PulsarConfig, options.workers(cfg), retryPolicy)
+	if err != nil {
+		log.Error(fmt.Sprintf("Error creating pulsar consumer: %v", err))
+		return err
+	}
+	defer animeConsumer.Close()
 
 	log.Info("Starting anime eventing")
-	err := animeConsumer.Receive(ctx, func(ctx context.Context, msg pulsar.Message) error {
-		return messageProcessor.Process(ctx, string(msg.Payload()), postgresProcessor.Process)
+	err = shutdown.run(func(ctx context.Context) error {
+		return animeConsumer.Receive(ctx, func(ctx context.Context, msg pulsar.Message) error {
+			ctx, cancel := shutdown.handlerContext(ctx)
+			defer cancel()
+
+			start := time.Now()
+			err := messageProcessor.Process(ctx, string(msg.Payload()), postgresProcessor.Process)
+			metrics.ObserveProcessed("anime", metrics.Action(msg.Payload()), time.Since(start), err)
+			return err
+		})
 	})
 	if err != nil {
 		log.Error(fmt.Sprintf("Error receiving message: %v", err))
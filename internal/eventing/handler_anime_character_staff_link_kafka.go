@@ -0,0 +1,132 @@
+package eventing
+
+import (
+	"context"
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/ThatCatDev/ep/v2/processor"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/character_staff_link_processor"
+	"go.uber.org/zap"
+)
+
+func EventingAnimeCharacterStaffLinkKafka(options ServeOptions) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
+	kafkaConfig := &epKafka.KafkaConfig{
+		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+		SaslMechanism:            nil,
+		SecurityProtocol:         nil,
+		Username:                 nil,
+		Password:                 nil,
+		ConsumerSessionTimeoutMs: nil,
+		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+		ClientID:                 kafkaClientID(cfg),
+		Debug:                    nil,
+	}
+
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
+	defer func(driver drivers.Driver[*kafka.Message]) {
+		err := driver.Close()
+		if err != nil {
+			log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+		} else {
+			log.Info("Kafka driver closed successfully")
+		}
+	}(driver)
+
+	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
+
+	processorOptions := character_staff_link_processor.Options{
+		NoErrorOnDelete: true,
+	}
+
+	linkProcessor := character_staff_link_processor.NewCharacterStaffLinkProcessor(processorOptions, database)
+
+	retryPolicy := cfg.RetryConfig.Resolve(cfg.RetryConfig.CharacterStaffLink.Or(linkRetryPolicy))
+	retryTopic := retryPolicy.RetryTopic(cfg.KafkaConfig.Topic)
+
+	// deferred links are re-queued on the retry topic, so it is consumed alongside the main topic
+	topics := []string{cfg.KafkaConfig.Topic, retryTopic}
+
+	if workers := options.workers(cfg); workers > 1 {
+		factory := newTableHandlerFactory[character_staff_link_processor.Payload](driver, retryPolicy, "character_staff_link_processor", linkProcessor.Process)
+		handlers := map[string]MessageHandler{}
+		for _, topic := range topics {
+			handlers[topic] = factory(topic)
+		}
+		handle := func(ctx context.Context, message *kafka.Message) error {
+			return handlers[topicOf(message.TopicPartition)](ctx, message)
+		}
+
+		log.Info("Starting Kafka consumer", zap.Strings("topics", topics), zap.Int("workers", workers))
+		err := consumeInParallel(shutdown, kafkaConfig, topics, workers, handle)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	err := shutdown.run(func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		errs := make(chan error, len(topics))
+		for _, topic := range topics {
+			topic := topic
+			processorInstance := processor.NewProcessor[*kafka.Message, character_staff_link_processor.Payload](driver, topic, linkProcessor.Process)
+
+			log.Info("initializing backoff retry middleware", zap.String("topic", topic))
+			backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[character_staff_link_processor.Payload](driver, retryPolicy, topic, "character_staff_link_processor")
+
+			log.Info("Starting Kafka processor", zap.String("topic", topic))
+			go func() {
+				errs <- processorInstance.
+					AddMiddleware(NewTracingMiddleware[character_staff_link_processor.Payload](topic, "character_staff_link_processor").Process).
+					AddMiddleware(NewLoggerMiddleware[*kafka.Message, character_staff_link_processor.Payload]().Process).
+					AddMiddleware(traceStage[character_staff_link_processor.Payload]("retry", backoffRetryInstance.Process)).
+					AddMiddleware(traceStage[character_staff_link_processor.Payload]("dead_letter", deadLetterInstance.Process)).
+					AddMiddleware(traceStage[character_staff_link_processor.Payload]("transform", NewTransformMiddleware[*kafka.Message, character_staff_link_processor.Payload]().Process)).
+					AddMiddleware(traceStage[character_staff_link_processor.Payload]("process", NewMetricsMiddleware[character_staff_link_processor.Payload]("character_staff_link_processor").Process)).
+					Run(ctx)
+			}()
+		}
+
+		// the first consumer to stop stops the other one, both are waited for so their in-flight links finish
+		var err error
+		for range topics {
+			consumeErr := <-errs
+			if consumeErr != nil && err == nil {
+				err = consumeErr
+			}
+			cancel()
+		}
+		return err
+	})
+
+	if err != nil {
+		log.Error("Error consuming messages", zap.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -6,63 +6,64 @@ import (
 	"github.com/weeb-vip/anime-sync/config"
 	"github.com/weeb-vip/anime-sync/internal/db"
 	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/metrics"
+	"github.com/weeb-vip/anime-sync/internal/server"
+	"github.com/weeb-vip/anime-sync/internal/services/consumer"
 	"github.com/weeb-vip/anime-sync/internal/services/processor"
 	pulsar_anime_postgres_processor "github.com/weeb-vip/anime-sync/internal/services/pulsar_anime_episode_postgres_processor"
 	"go.uber.org/zap"
-
 	"time"
 )
 
-func EventingAnimeEpisode() error {
+func EventingAnimeEpisode(options ServeOptions) error {
 	cfg := config.LoadConfigOrPanic()
 	ctx := context.Background()
 	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
 
 	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startHealthServer(ctx, cfg, shutdown, map[string]server.Check{
+		"database": databaseCheck(database),
+	})
+	defer stopHealthServer()
 
 	posgresProcessorOptions := pulsar_anime_postgres_processor.Options{
 		NoErrorOnDelete: true,
 	}
 	postgresProcessor := pulsar_anime_postgres_processor.NewPulsarAnimeEpisodePostgresProcessor(posgresProcessorOptions, database)
 
-	messageProcessor := processor.NewProcessor[pulsar_anime_postgres_processor.Payload]()
-
-	client, err := pulsar.NewClient(pulsar.ClientOptions{
-		URL: cfg.PulsarConfig.URL,
-	})
+	retryPolicy := cfg.RetryConfig.Resolve(cfg.RetryConfig.Episode)
+	messageProcessor := processor.NewProcessorWithOptions[pulsar_anime_postgres_processor.Payload](pulsarProcessorOptions(retryPolicy))
 
+	episodeConsumer, err := consumer.NewConsumer[pulsar_anime_postgres_processor.Payload](ctx, cfg.PulsarConfig, options.workers(cfg), retryPolicy)
 	if err != nil {
-		log.Fatal("Error creating pulsar client: ", zap.String("error", err.Error()))
+		log.Error("Error creating pulsar consumer: ", zap.String("error", err.Error()))
 		return err
 	}
-
-	defer client.Close()
-
-	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
-		Topic:            cfg.PulsarConfig.Topic,
-		SubscriptionName: cfg.PulsarConfig.SubscribtionName,
-		Type:             pulsar.Shared,
+	defer episodeConsumer.Close()
+
+	log.Info("Starting anime episode eventing")
+	err = shutdown.run(func(ctx context.Context) error {
+		return episodeConsumer.Receive(ctx, func(ctx context.Context, msg pulsar.Message) error {
+			ctx, cancel := shutdown.handlerContext(ctx)
+			defer cancel()
+
+			start := time.Now()
+			err := messageProcessor.Process(ctx, string(msg.Payload()), postgresProcessor.Process)
+			metrics.ObserveProcessed("anime_episode", metrics.Action(msg.Payload()), time.Since(start), err)
+			return err
+		})
 	})
-
-	defer consumer.Close()
-
-	// create channel to receive messages
-
-	for {
-		msg, err := consumer.Receive(ctx)
-		if err != nil {
-			log.Fatal("Error receiving message: ", zap.String("error", err.Error()))
-		}
-
-		log.Info("Received message", zap.String("msgId", msg.ID().String()))
-
-		err = messageProcessor.Process(ctx, string(msg.Payload()), postgresProcessor.Process)
-		if err != nil {
-			log.Warn("error processing message: ", zap.String("error", err.Error()))
-			continue
-		}
-		consumer.Ack(msg)
-		time.Sleep(50 * time.Millisecond)
+	if err != nil {
+		log.Error("Error receiving message: ", zap.String("error", err.Error()))
+		return err
 	}
 
+	return nil
 }
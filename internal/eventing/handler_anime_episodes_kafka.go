@@ -4,7 +4,6 @@ import (
 	"context"
 	"github.com/ThatCatDev/ep/v2/drivers"
 	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
-	"github.com/ThatCatDev/ep/v2/middlewares/kafka/backoffretry"
 	"github.com/ThatCatDev/ep/v2/processor"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/weeb-vip/anime-sync/config"
@@ -14,12 +13,19 @@ import (
 	"go.uber.org/zap"
 )
 
-func EventingAnimeEpisodeKafka() error {
+func EventingAnimeEpisodeKafka(options ServeOptions) error {
 	cfg := config.LoadConfigOrPanic()
 	ctx := context.Background()
 	log := logger.Get()
 	ctx = logger.WithCtx(ctx, log)
 
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
 	kafkaConfig := &epKafka.KafkaConfig{
 		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
 		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
@@ -29,11 +35,11 @@ func EventingAnimeEpisodeKafka() error {
 		Password:                 nil,
 		ConsumerSessionTimeoutMs: nil,
 		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
-		ClientID:                 nil,
+		ClientID:                 kafkaClientID(cfg),
 		Debug:                    nil,
 	}
 
-	driver := epKafka.NewKafkaDriver(kafkaConfig)
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
 	defer func(driver drivers.Driver[*kafka.Message]) {
 		err := driver.Close()
 		if err != nil {
@@ -44,30 +50,58 @@ func EventingAnimeEpisodeKafka() error {
 	}(driver)
 
 	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
 
 	processorOptions := episode_processor.Options{
 		NoErrorOnDelete: true,
+		ForceReplay:     cfg.SyncConfig.ForceReplay,
 	}
 
 	episodeProcessorInstance := episode_processor.NewAnimeProcessor(processorOptions, database)
 
+	if cfg.BatchConfig.Enabled {
+		handle := newTableHandlerFactory[episode_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Episode), "episode_processor", episodeProcessorInstance.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka batch consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("batchSize", cfg.BatchConfig.Size))
+		err := consumeBatches(cfg, shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, handle, NewBatchHandler[episode_processor.Payload]("episode_processor", episodeProcessorInstance.ProcessBatch), IsSnapshot)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	if workers := options.workers(cfg); workers > 1 {
+		handle := newTableHandlerFactory[episode_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Episode), "episode_processor", episodeProcessorInstance.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("workers", workers))
+		err := consumeInParallel(shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, workers, handle)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
 	processorInstance := processor.NewProcessor[*kafka.Message, episode_processor.Payload](driver, cfg.KafkaConfig.Topic, episodeProcessorInstance.Process)
 
 	log.Info("initializing backoff retry middleware", zap.String("topic", cfg.KafkaConfig.Topic))
-	backoffRetryInstance := backoffretry.NewBackoffRetry[episode_processor.Payload](driver, backoffretry.Config{
-		MaxRetries: 3,
-		HeaderKey:  "retry",
-		RetryQueue: cfg.KafkaConfig.Topic + "-retry",
-	})
+	backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[episode_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Episode), cfg.KafkaConfig.Topic, "episode_processor")
 
 	log.Info("Starting Kafka processor", zap.String("topic", cfg.KafkaConfig.Topic))
-	err := processorInstance.
+	err := shutdown.run(processorInstance.
+		AddMiddleware(NewTracingMiddleware[episode_processor.Payload](cfg.KafkaConfig.Topic, "episode_processor").Process).
 		AddMiddleware(NewLoggerMiddleware[*kafka.Message, episode_processor.Payload]().Process).
-		AddMiddleware(NewTransformMiddleware[*kafka.Message, episode_processor.Payload]().Process).
-		AddMiddleware(backoffRetryInstance.Process).
-		Run(ctx)
+		AddMiddleware(traceStage[episode_processor.Payload]("retry", backoffRetryInstance.Process)).
+		AddMiddleware(traceStage[episode_processor.Payload]("dead_letter", deadLetterInstance.Process)).
+		AddMiddleware(traceStage[episode_processor.Payload]("transform", NewTransformMiddleware[*kafka.Message, episode_processor.Payload]().Process)).
+		AddMiddleware(traceStage[episode_processor.Payload]("process", NewMetricsMiddleware[episode_processor.Payload]("episode_processor").Process)).
+		Run)
 
-	if err != nil && ctx.Err() == nil { // Ignore error if caused by context cancellation
+	if err != nil {
 		log.Error("Error consuming messages", zap.String("error", err.Error()))
 		return err
 	}
@@ -8,22 +8,31 @@ import (
 	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
 	"github.com/ThatCatDev/ep/v2/event"
 	"github.com/ThatCatDev/ep/v2/middleware"
-	"github.com/ThatCatDev/ep/v2/middlewares/kafka/backoffretry"
 	"github.com/ThatCatDev/ep/v2/processor"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/weeb-vip/anime-sync/config"
 	"github.com/weeb-vip/anime-sync/internal/db"
 	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/producer"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
 	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
 	"go.uber.org/zap"
 )
 
-func EventingAnimeKafka() error {
+func EventingAnimeKafka(options ServeOptions) error {
 	cfg := config.LoadConfigOrPanic()
 	ctx := context.Background()
 	log := logger.Get()
 	ctx = logger.WithCtx(ctx, log)
 
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
 	kafkaConfig := &epKafka.KafkaConfig{
 		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
 		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
@@ -33,11 +42,11 @@ func EventingAnimeKafka() error {
 		Password:                 nil,
 		ConsumerSessionTimeoutMs: nil,
 		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
-		ClientID:                 nil,
+		ClientID:                 kafkaClientID(cfg),
 		Debug:                    nil,
 	}
 
-	driver := epKafka.NewKafkaDriver(kafkaConfig)
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
 	defer func(driver drivers.Driver[*kafka.Message]) {
 		err := driver.Close()
 		if err != nil {
@@ -48,32 +57,66 @@ func EventingAnimeKafka() error {
 	}(driver)
 
 	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
+	stopOutboxRelay := startOutboxRelay(ctx, cfg, driver, database)
+	defer stopOutboxRelay()
 
 	posgresProcessorOptions := anime_processor.Options{
 		NoErrorOnDelete: true,
+		ForceReplay:     cfg.SyncConfig.ForceReplay,
+	}
+
+	if cfg.BatchConfig.Enabled {
+		batchProducer := producer.NewKafkaBatchProducer(epKafka.GetKafkaConfig(*kafkaConfig))
+		defer batchProducer.Close()
+
+		batchProcessor := anime_processor.NewAnimeBatchProcessor(posgresProcessorOptions, database, syncBatchPublisher[anime_processor.ProducerPayload](cfg, batchProducer, database, cfg.KafkaConfig.AlgoliaTopic), syncBatchPublisher[anime_processor.ImagePayload](cfg, batchProducer, database, cfg.KafkaConfig.ProducerTopic))
+		handle := newTableHandlerFactory[anime_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Anime), "anime_processor", batchProcessor.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka batch consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("batchSize", cfg.BatchConfig.Size))
+		err := consumeBatches(cfg, shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, handle, NewBatchHandler[anime_processor.Payload]("anime_processor", batchProcessor.ProcessBatch), IsSnapshot)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
 	}
 
-	postgresProcessor := anime_processor.NewAnimeProcessor(posgresProcessorOptions, database, kafkaProducer(ctx, driver, cfg.KafkaConfig.AlgoliaTopic), kafkaProducer(ctx, driver, cfg.KafkaConfig.ProducerTopic))
+	postgresProcessor := anime_processor.NewAnimeProcessor(posgresProcessorOptions, database, syncPublisher[anime_processor.ProducerPayload](ctx, cfg, driver, database, cfg.KafkaConfig.AlgoliaTopic), syncPublisher[anime_processor.ImagePayload](ctx, cfg, driver, database, cfg.KafkaConfig.ProducerTopic))
+
+	if workers := options.workers(cfg); workers > 1 {
+		handle := newTableHandlerFactory[anime_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Anime), "anime_processor", postgresProcessor.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("workers", workers))
+		err := consumeInParallel(shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, workers, handle)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
 
 	processorInstance := processor.NewProcessor[*kafka.Message, anime_processor.Payload](driver, cfg.KafkaConfig.Topic, postgresProcessor.Process)
 
 	log.Info("initializing backoff retry middleware", zap.String("topic", cfg.KafkaConfig.Topic))
-	backoffRetryInstance := backoffretry.NewBackoffRetry[anime_processor.Payload](driver, backoffretry.Config{
-		MaxRetries: 3,
-		HeaderKey:  "retry",
-		RetryQueue: cfg.KafkaConfig.Topic + "-retry",
-	})
+	backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[anime_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Anime), cfg.KafkaConfig.Topic, "anime_processor")
 
 	log.Info("Starting Kafka processor", zap.String("topic", cfg.KafkaConfig.Topic))
 	// create middleware to log errors and continue processing
 
-	err := processorInstance.
+	err := shutdown.run(processorInstance.
+		AddMiddleware(NewTracingMiddleware[anime_processor.Payload](cfg.KafkaConfig.Topic, "anime_processor").Process).
 		AddMiddleware(NewLoggerMiddleware[*kafka.Message, anime_processor.Payload]().Process).
-		AddMiddleware(NewTransformMiddleware[*kafka.Message, anime_processor.Payload]().Process).
-		AddMiddleware(backoffRetryInstance.Process).
-		Run(ctx)
+		AddMiddleware(traceStage[anime_processor.Payload]("retry", backoffRetryInstance.Process)).
+		AddMiddleware(traceStage[anime_processor.Payload]("dead_letter", deadLetterInstance.Process)).
+		AddMiddleware(traceStage[anime_processor.Payload]("transform", NewTransformMiddleware[*kafka.Message, anime_processor.Payload]().Process)).
+		AddMiddleware(traceStage[anime_processor.Payload]("process", NewMetricsMiddleware[anime_processor.Payload]("anime_processor").Process)).
+		Run)
 
-	if err != nil && ctx.Err() == nil { // Ignore error if caused by context cancellation
+	if err != nil {
 		log.Error("Error consuming messages", zap.String("error", err.Error()))
 		return err
 	}
@@ -82,10 +125,13 @@ func EventingAnimeKafka() error {
 }
 
 func kafkaProducer(ctx context.Context, driver drivers.Driver[*kafka.Message], topic string) func(ctx context.Context, message *kafka.Message) error {
-	return func(ctx context.Context, message *kafka.Message) error {
+	return func(ctx context.Context, message *kafka.Message) (err error) {
+		ctx, span := tracing.StartProducer(ctx, topic, message)
+		defer func() { tracing.End(span, err) }()
+
 		log := logger.FromCtx(ctx)
 		log.Info("Producing message to Kafka", zap.String("topic", topic), zap.String("key", string(message.Key)), zap.String("value", string(message.Value)))
-		if err := driver.Produce(ctx, topic, message); err != nil {
+		if err = driver.Produce(ctx, topic, message); err != nil {
 			log.Error("Failed to produce message", zap.String("topic", topic), zap.Error(err))
 			return err
 		}
@@ -110,16 +156,23 @@ func (f *LoggerMiddleware[DM, M]) Process(ctx context.Context, data event.Event[
 		log.Info("Message processed successfully")
 	}
 
-	jsonPayload, err := json.Marshal(result.Payload)
+	// a middleware that fails before the message was decoded may not return the event
+	if result == nil {
+		return result, err
+	}
+
+	jsonPayload, marshalErr := json.Marshal(result.Payload)
 	log.Info("Processing message", zap.String("value", string(jsonPayload)))
-	if err != nil {
-		log.Error("Error processing message", zap.String("value", string(jsonPayload)), zap.Error(err))
+	if marshalErr != nil {
+		log.Error("Error processing message", zap.String("value", string(jsonPayload)), zap.Error(marshalErr))
 	} else {
 		log.Info("Successfully processed message", zap.String("value", string(jsonPayload)))
 	}
 	return result, err
 }
 
+// TransformMiddleware decodes the Debezium payload of the message. It has to be added after the dead
+// letter middleware so a payload that does not decode is dead-lettered as a permanent error
 type TransformMiddleware[DM any, M any] struct {
 }
 
@@ -137,7 +190,7 @@ func (f *TransformMiddleware[DM, M]) Process(ctx context.Context, data event.Eve
 			decodedBytes, err := base64.StdEncoding.DecodeString(valueStr)
 			if err != nil {
 				log.Error("Failed to decode base64 value", zap.Error(err))
-				return nil, err
+				return &data, retryable.NewPermanent(err)
 			}
 
 			log.Info("Decoding base64 value", zap.String("decodedBytes", string(decodedBytes)))
@@ -148,14 +201,9 @@ func (f *TransformMiddleware[DM, M]) Process(ctx context.Context, data event.Eve
 			}
 			if err := json.Unmarshal(decodedBytes, &debeziumMessage); err != nil {
 				log.Error("Failed to unmarshal decoded payload", zap.Error(err))
-				return nil, err
-			}
-			if payload, ok := interface{}(debeziumMessage.Payload).(M); ok {
-				data.Payload = payload
-			} else {
-				log.Error("Failed to cast payload to expected type")
-				return nil, err
+				return &data, retryable.NewPermanent(err)
 			}
+			data.Payload = debeziumMessage.Payload
 
 			log.Info("Successfully decoded base64 value and updated payload", zap.Any("payload", data.Payload))
 		} else {
@@ -0,0 +1,99 @@
+package eventing
+
+import (
+	"context"
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/ThatCatDev/ep/v2/processor"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_relation_processor"
+	"go.uber.org/zap"
+)
+
+func EventingAnimeRelationKafka(options ServeOptions) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
+	kafkaConfig := &epKafka.KafkaConfig{
+		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+		SaslMechanism:            nil,
+		SecurityProtocol:         nil,
+		Username:                 nil,
+		Password:                 nil,
+		ConsumerSessionTimeoutMs: nil,
+		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+		ClientID:                 kafkaClientID(cfg),
+		Debug:                    nil,
+	}
+
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
+	defer func(driver drivers.Driver[*kafka.Message]) {
+		err := driver.Close()
+		if err != nil {
+			log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+		} else {
+			log.Info("Kafka driver closed successfully")
+		}
+	}(driver)
+
+	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
+
+	processorOptions := anime_relation_processor.Options{
+		NoErrorOnDelete: true,
+		MaintainInverse: cfg.SyncConfig.MaintainInverseRelations,
+	}
+
+	relationProcessor := anime_relation_processor.NewAnimeRelationProcessor(processorOptions, database)
+
+	if workers := options.workers(cfg); workers > 1 {
+		handle := newTableHandlerFactory[anime_relation_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Relation), "anime_relation_processor", relationProcessor.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("workers", workers))
+		err := consumeInParallel(shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, workers, handle)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	processorInstance := processor.NewProcessor[*kafka.Message, anime_relation_processor.Payload](driver, cfg.KafkaConfig.Topic, relationProcessor.Process)
+
+	log.Info("initializing backoff retry middleware", zap.String("topic", cfg.KafkaConfig.Topic))
+	backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[anime_relation_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Relation), cfg.KafkaConfig.Topic, "anime_relation_processor")
+
+	log.Info("Starting Kafka processor", zap.String("topic", cfg.KafkaConfig.Topic))
+
+	err := shutdown.run(processorInstance.
+		AddMiddleware(NewTracingMiddleware[anime_relation_processor.Payload](cfg.KafkaConfig.Topic, "anime_relation_processor").Process).
+		AddMiddleware(NewLoggerMiddleware[*kafka.Message, anime_relation_processor.Payload]().Process).
+		AddMiddleware(traceStage[anime_relation_processor.Payload]("retry", backoffRetryInstance.Process)).
+		AddMiddleware(traceStage[anime_relation_processor.Payload]("dead_letter", deadLetterInstance.Process)).
+		AddMiddleware(traceStage[anime_relation_processor.Payload]("transform", NewTransformMiddleware[*kafka.Message, anime_relation_processor.Payload]().Process)).
+		AddMiddleware(traceStage[anime_relation_processor.Payload]("process", NewMetricsMiddleware[anime_relation_processor.Payload]("anime_relation_processor").Process)).
+		Run)
+
+	if err != nil {
+		log.Error("Error consuming messages", zap.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
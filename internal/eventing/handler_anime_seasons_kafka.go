@@ -4,7 +4,6 @@ import (
 	"context"
 	"github.com/ThatCatDev/ep/v2/drivers"
 	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
-	"github.com/ThatCatDev/ep/v2/middlewares/kafka/backoffretry"
 	"github.com/ThatCatDev/ep/v2/processor"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/weeb-vip/anime-sync/config"
@@ -14,12 +13,19 @@ import (
 	"go.uber.org/zap"
 )
 
-func EventingAnimeSeasonKafka() error {
+func EventingAnimeSeasonKafka(options ServeOptions) error {
 	cfg := config.LoadConfigOrPanic()
 	ctx := context.Background()
 	log := logger.Get()
 	ctx = logger.WithCtx(ctx, log)
 
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
 	kafkaConfig := &epKafka.KafkaConfig{
 		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
 		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
@@ -29,11 +35,11 @@ func EventingAnimeSeasonKafka() error {
 		Password:                 nil,
 		ConsumerSessionTimeoutMs: nil,
 		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
-		ClientID:                 nil,
+		ClientID:                 kafkaClientID(cfg),
 		Debug:                    nil,
 	}
 
-	driver := epKafka.NewKafkaDriver(kafkaConfig)
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
 	defer func(driver drivers.Driver[*kafka.Message]) {
 		err := driver.Close()
 		if err != nil {
@@ -44,31 +50,49 @@ func EventingAnimeSeasonKafka() error {
 	}(driver)
 
 	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
+	stopOutboxRelay := startOutboxRelay(ctx, cfg, driver, database)
+	defer stopOutboxRelay()
 
 	postgresProcessorOptions := anime_season_processor.Options{
 		NoErrorOnDelete: true,
+		ForceReplay:     cfg.SyncConfig.ForceReplay,
 	}
 
-	postgresProcessor := anime_season_processor.NewAnimeSeasonProcessor(postgresProcessorOptions, database, kafkaProducer(ctx, driver, cfg.KafkaConfig.AlgoliaTopic))
+	postgresProcessor := anime_season_processor.NewAnimeSeasonProcessor(postgresProcessorOptions, database, syncPublisher[anime_season_processor.ProducerPayload](ctx, cfg, driver, database, cfg.KafkaConfig.AlgoliaTopic))
+
+	if workers := options.workers(cfg); workers > 1 {
+		handle := newTableHandlerFactory[anime_season_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Season), "anime_season_processor", postgresProcessor.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("workers", workers))
+		err := consumeInParallel(shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, workers, handle)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
 
 	processorInstance := processor.NewProcessor[*kafka.Message, anime_season_processor.Payload](driver, cfg.KafkaConfig.Topic, postgresProcessor.Process)
 
 	log.Info("initializing backoff retry middleware", zap.String("topic", cfg.KafkaConfig.Topic))
-	backoffRetryInstance := backoffretry.NewBackoffRetry[anime_season_processor.Payload](driver, backoffretry.Config{
-		MaxRetries: 3,
-		HeaderKey:  "retry",
-		RetryQueue: cfg.KafkaConfig.Topic + "-retry",
-	})
+	backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[anime_season_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Season), cfg.KafkaConfig.Topic, "anime_season_processor")
 
 	log.Info("Starting Kafka processor", zap.String("topic", cfg.KafkaConfig.Topic))
 
-	err := processorInstance.
+	err := shutdown.run(processorInstance.
+		AddMiddleware(NewTracingMiddleware[anime_season_processor.Payload](cfg.KafkaConfig.Topic, "anime_season_processor").Process).
 		AddMiddleware(NewLoggerMiddleware[*kafka.Message, anime_season_processor.Payload]().Process).
-		AddMiddleware(NewTransformMiddleware[*kafka.Message, anime_season_processor.Payload]().Process).
-		AddMiddleware(backoffRetryInstance.Process).
-		Run(ctx)
+		AddMiddleware(traceStage[anime_season_processor.Payload]("retry", backoffRetryInstance.Process)).
+		AddMiddleware(traceStage[anime_season_processor.Payload]("dead_letter", deadLetterInstance.Process)).
+		AddMiddleware(traceStage[anime_season_processor.Payload]("transform", NewTransformMiddleware[*kafka.Message, anime_season_processor.Payload]().Process)).
+		AddMiddleware(traceStage[anime_season_processor.Payload]("process", NewMetricsMiddleware[anime_season_processor.Payload]("anime_season_processor").Process)).
+		Run)
 
-	if err != nil && ctx.Err() == nil {
+	if err != nil {
 		log.Error("Error consuming messages", zap.String("error", err.Error()))
 		return err
 	}
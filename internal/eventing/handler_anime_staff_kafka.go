@@ -0,0 +1,101 @@
+package eventing
+
+import (
+	"context"
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/ThatCatDev/ep/v2/processor"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/staff_processor"
+	"go.uber.org/zap"
+)
+
+func EventingAnimeStaffKafka(options ServeOptions) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	stopTracing := startTracing(ctx, cfg)
+	defer stopTracing()
+
+	kafkaConfig := &epKafka.KafkaConfig{
+		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+		SaslMechanism:            nil,
+		SecurityProtocol:         nil,
+		Username:                 nil,
+		Password:                 nil,
+		ConsumerSessionTimeoutMs: nil,
+		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+		ClientID:                 kafkaClientID(cfg),
+		Debug:                    nil,
+	}
+
+	driver := shutdown.driver(epKafka.NewKafkaDriver(kafkaConfig))
+	defer func(driver drivers.Driver[*kafka.Message]) {
+		err := driver.Close()
+		if err != nil {
+			log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+		} else {
+			log.Info("Kafka driver closed successfully")
+		}
+	}(driver)
+
+	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	stopHealthServer := startConsumerHealthServer(ctx, cfg, shutdown, kafkaConfig, database)
+	defer stopHealthServer()
+	stopOutboxRelay := startOutboxRelay(ctx, cfg, driver, database)
+	defer stopOutboxRelay()
+
+	processorOptions := staff_processor.Options{
+		NoErrorOnDelete: true,
+	}
+
+	staffProcessor := staff_processor.NewStaffProcessor(processorOptions, database, syncPublisher[staff_processor.ProducerPayload](ctx, cfg, driver, database, cfg.KafkaConfig.AlgoliaTopic), syncPublisher[anime_processor.ImagePayload](ctx, cfg, driver, database, cfg.KafkaConfig.ProducerTopic))
+
+	if workers := options.workers(cfg); workers > 1 {
+		handle := newTableHandlerFactory[staff_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Staff), "staff_processor", staffProcessor.Process)(cfg.KafkaConfig.Topic)
+
+		log.Info("Starting Kafka consumer", zap.String("topic", cfg.KafkaConfig.Topic), zap.Int("workers", workers))
+		err := consumeInParallel(shutdown, kafkaConfig, []string{cfg.KafkaConfig.Topic}, workers, handle)
+		if err != nil {
+			log.Error("Error consuming messages", zap.String("error", err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	processorInstance := processor.NewProcessor[*kafka.Message, staff_processor.Payload](driver, cfg.KafkaConfig.Topic, staffProcessor.Process)
+
+	log.Info("initializing backoff retry middleware", zap.String("topic", cfg.KafkaConfig.Topic))
+	backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[staff_processor.Payload](driver, cfg.RetryConfig.Resolve(cfg.RetryConfig.Staff), cfg.KafkaConfig.Topic, "staff_processor")
+
+	log.Info("Starting Kafka processor", zap.String("topic", cfg.KafkaConfig.Topic))
+
+	err := shutdown.run(processorInstance.
+		AddMiddleware(NewTracingMiddleware[staff_processor.Payload](cfg.KafkaConfig.Topic, "staff_processor").Process).
+		AddMiddleware(NewLoggerMiddleware[*kafka.Message, staff_processor.Payload]().Process).
+		AddMiddleware(traceStage[staff_processor.Payload]("retry", backoffRetryInstance.Process)).
+		AddMiddleware(traceStage[staff_processor.Payload]("dead_letter", deadLetterInstance.Process)).
+		AddMiddleware(traceStage[staff_processor.Payload]("transform", NewTransformMiddleware[*kafka.Message, staff_processor.Payload]().Process)).
+		AddMiddleware(traceStage[staff_processor.Payload]("process", NewMetricsMiddleware[staff_processor.Payload]("staff_processor").Process)).
+		Run)
+
+	if err != nil {
+		log.Error("Error consuming messages", zap.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package eventing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/metrics"
+	"github.com/weeb-vip/anime-sync/internal/server"
+	"go.uber.org/zap"
+)
+
+// lagInterval is how often the consumer lag metric is refreshed
+const lagInterval = 15 * time.Second
+
+// kafkaClientID identifies the consumers of this instance in the consumer group, so readiness and lag
+// can be limited to the partitions assigned to them. It defaults to the hostname, i.e. the pod name
+func kafkaClientID(cfg config.Config) *string {
+	clientID := cfg.KafkaConfig.ClientID
+	if clientID == "" {
+		clientID, _ = os.Hostname()
+	}
+	return &clientID
+}
+
+// startHealthServer serves /healthz, /readyz and /metrics on AppConfig.Port until the returned func
+// is called. Readiness fails as soon as the shutdown started, besides the given checks
+func startHealthServer(ctx context.Context, cfg config.Config, shutdown *shutdown, checks map[string]server.Check) func() {
+	checks["shutdown"] = func(context.Context) error {
+		if shutdown.ctx.Err() != nil {
+			return fmt.Errorf("shutting down")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.NewServer(cfg.AppConfig.Port, checks).Run(ctx); err != nil {
+			logger.FromCtx(ctx).Error("Health server stopped", zap.Error(err))
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startConsumerHealthServer starts the health server of a Kafka consumer, it is ready once the
+// database and brokers are reachable and the group assigned it partitions. It also keeps the
+// consumer lag metric up to date
+func startConsumerHealthServer(ctx context.Context, cfg config.Config, shutdown *shutdown, kafkaConfig *epKafka.KafkaConfig, database *db.DB) func() {
+	return startKafkaHealthServer(ctx, cfg, shutdown, kafkaConfig, database, true)
+}
+
+// startProducerHealthServer starts the health server of a process that only produces to Kafka,
+// it is ready once the database and brokers are reachable
+func startProducerHealthServer(ctx context.Context, cfg config.Config, shutdown *shutdown, kafkaConfig *epKafka.KafkaConfig, database *db.DB) func() {
+	return startKafkaHealthServer(ctx, cfg, shutdown, kafkaConfig, database, false)
+}
+
+func startKafkaHealthServer(ctx context.Context, cfg config.Config, shutdown *shutdown, kafkaConfig *epKafka.KafkaConfig, database *db.DB, consumer bool) func() {
+	log := logger.FromCtx(ctx)
+
+	checks := map[string]server.Check{
+		"database": databaseCheck(database),
+	}
+
+	monitor, err := newKafkaMonitor(kafkaConfig)
+	if err != nil {
+		log.Error("Error creating kafka monitor, serving without kafka checks", zap.Error(err))
+		return startHealthServer(ctx, cfg, shutdown, checks)
+	}
+	checks["kafka"] = monitor.checkBrokers
+
+	lagCtx, stopLag := context.WithCancel(ctx)
+	lagDone := make(chan struct{})
+	if consumer {
+		checks["partitions"] = monitor.checkPartitions
+		go func() {
+			defer close(lagDone)
+			monitor.runLag(lagCtx, lagInterval)
+		}()
+	} else {
+		close(lagDone)
+	}
+
+	stopServer := startHealthServer(ctx, cfg, shutdown, checks)
+
+	return func() {
+		stopServer()
+		stopLag()
+		<-lagDone
+		monitor.close()
+	}
+}
+
+func databaseCheck(database *db.DB) server.Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := database.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// kafkaMonitor looks at the consumer group from the outside through the admin API, the ep driver
+// does not expose its consumers
+type kafkaMonitor struct {
+	admin    *kafka.AdminClient
+	group    string
+	clientID string
+}
+
+func newKafkaMonitor(kafkaConfig *epKafka.KafkaConfig) (*kafkaMonitor, error) {
+	admin, err := kafka.NewAdminClient(epKafka.GetKafkaConfig(*kafkaConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	monitor := &kafkaMonitor{
+		admin: admin,
+		group: kafkaConfig.ConsumerGroupName,
+	}
+	if kafkaConfig.ClientID != nil {
+		monitor.clientID = *kafkaConfig.ClientID
+	}
+	return monitor, nil
+}
+
+func (m *kafkaMonitor) close() {
+	m.admin.Close()
+}
+
+func (m *kafkaMonitor) checkBrokers(ctx context.Context) error {
+	_, err := m.admin.ClusterID(ctx)
+	return err
+}
+
+func (m *kafkaMonitor) checkPartitions(ctx context.Context) error {
+	partitions, err := m.assignedPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("no partitions assigned to %s in group %s", m.clientID, m.group)
+	}
+	return nil
+}
+
+// assignedPartitions returns the partitions the group assigned to the consumers of this instance
+func (m *kafkaMonitor) assignedPartitions(ctx context.Context) ([]kafka.TopicPartition, error) {
+	result, err := m.admin.DescribeConsumerGroups(ctx, []string{m.group})
+	if err != nil {
+		return nil, err
+	}
+
+	var partitions []kafka.TopicPartition
+	for _, group := range result.ConsumerGroupDescriptions {
+		if group.Error.Code() != kafka.ErrNoError {
+			return nil, group.Error
+		}
+		for _, member := range group.Members {
+			if member.ClientID == m.clientID {
+				partitions = append(partitions, member.Assignment.TopicPartitions...)
+			}
+		}
+	}
+	return partitions, nil
+}
+
+func (m *kafkaMonitor) runLag(ctx context.Context, interval time.Duration) {
+	log := logger.FromCtx(ctx)
+	for {
+		lag, err := m.lag(ctx)
+		if err != nil {
+			log.Warn("Error refreshing consumer lag", zap.Error(err))
+		} else {
+			metrics.SetConsumerLag(lag)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// lag returns the consumer lag of every assigned partition by topic and partition
+func (m *kafkaMonitor) lag(ctx context.Context) (map[string]map[string]int64, error) {
+	partitions, err := m.assignedPartitions(ctx)
+	if err != nil || len(partitions) == 0 {
+		return map[string]map[string]int64{}, err
+	}
+
+	committed, err := m.admin.ListConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{{
+		Group:      m.group,
+		Partitions: partitions,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make(map[kafka.TopicPartition]kafka.OffsetSpec, len(partitions))
+	for _, partition := range partitions {
+		specs[kafka.TopicPartition{Topic: partition.Topic, Partition: partition.Partition}] = kafka.LatestOffsetSpec
+	}
+	ends, err := m.admin.ListOffsets(ctx, specs)
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets []kafka.TopicPartition
+	for _, group := range committed.ConsumerGroupsTopicPartitions {
+		offsets = append(offsets, group.Partitions...)
+	}
+	return partitionLag(offsets, ends.ResultInfos), nil
+}
+
+// partitionLag subtracts the committed offsets from the end offsets. Partitions without a
+// committed offset count from 0, the admin API results are keyed by topic pointers so they
+// are matched by name
+func partitionLag(committed []kafka.TopicPartition, ends map[kafka.TopicPartition]kafka.ListOffsetsResultInfo) map[string]map[string]int64 {
+	endOffsets := map[string]int64{}
+	for partition, info := range ends {
+		if partition.Topic == nil || info.Error.Code() != kafka.ErrNoError {
+			continue
+		}
+		endOffsets[partitionKey(*partition.Topic, partition.Partition)] = int64(info.Offset)
+	}
+
+	lag := map[string]map[string]int64{}
+	for _, partition := range committed {
+		if partition.Topic == nil {
+			continue
+		}
+		end, ok := endOffsets[partitionKey(*partition.Topic, partition.Partition)]
+		if !ok {
+			continue
+		}
+
+		offset := int64(partition.Offset)
+		if offset < 0 {
+			offset = 0
+		}
+
+		if lag[*partition.Topic] == nil {
+			lag[*partition.Topic] = map[string]int64{}
+		}
+		lag[*partition.Topic][strconv.Itoa(int(partition.Partition))] = max(end-offset, 0)
+	}
+	return lag
+}
+
+func partitionKey(topic string, partition int32) string {
+	return topic + "/" + strconv.Itoa(int(partition))
+}
@@ -0,0 +1,29 @@
+package eventing
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionLag(t *testing.T) {
+	anime, episodes := "anime", "episodes"
+	// the admin API hands out new topic pointers in every result
+	animeEnd, episodesEnd := "anime", "episodes"
+
+	committed := []kafka.TopicPartition{
+		{Topic: &anime, Partition: 0, Offset: 90},
+		{Topic: &anime, Partition: 1, Offset: kafka.OffsetInvalid},
+		{Topic: &episodes, Partition: 0, Offset: 12},
+	}
+	ends := map[kafka.TopicPartition]kafka.ListOffsetsResultInfo{
+		{Topic: &animeEnd, Partition: 0}:    {Offset: 100},
+		{Topic: &animeEnd, Partition: 1}:    {Offset: 7},
+		{Topic: &episodesEnd, Partition: 0}: {Error: kafka.NewError(kafka.ErrUnknownPartition, "unknown partition", false)},
+	}
+
+	assert.Equal(t, map[string]map[string]int64{
+		"anime": {"0": 10, "1": 7},
+	}, partitionLag(committed, ends))
+}
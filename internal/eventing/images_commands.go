@@ -0,0 +1,81 @@
+package eventing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/image_backfill"
+	"go.uber.org/zap"
+)
+
+// ImagesBackfill produces an image sync request for every selected anime, character and staff member
+// to the image topic and prints each request to w. A dry run prints the requests without connecting to kafka
+func ImagesBackfill(w io.Writer, opt image_backfill.Options) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	var images publisher.Publisher[anime_processor.ImagePayload]
+	if !opt.DryRun {
+		kafkaConfig := &epKafka.KafkaConfig{
+			ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+			BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+			SaslMechanism:            nil,
+			SecurityProtocol:         nil,
+			Username:                 nil,
+			Password:                 nil,
+			ConsumerSessionTimeoutMs: nil,
+			ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+			ClientID:                 nil,
+			Debug:                    nil,
+		}
+
+		driver := epKafka.NewKafkaDriver(kafkaConfig)
+		defer func(driver drivers.Driver[*kafka.Message]) {
+			err := driver.Close()
+			if err != nil {
+				log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+			}
+		}(driver)
+		images = publisher.NewKafka[anime_processor.ImagePayload](kafkaProducer(ctx, driver, cfg.KafkaConfig.ProducerTopic))
+	}
+
+	verb := "sent"
+	if opt.DryRun {
+		verb = "would send"
+	}
+	report := func(id string, image *anime_processor.ImagePayload) {
+		fmt.Fprintf(w, "%s %s %s %s %s", verb, image.Data.Type, id, image.Data.Name, image.Data.URL)
+		if image.Data.LegacyName != "" {
+			fmt.Fprintf(w, " (was %s)", image.Data.LegacyName)
+		}
+		fmt.Fprintln(w)
+	}
+
+	var result image_backfill.Result
+	err := shutdown.run(func(ctx context.Context) error {
+		var err error
+		result, err = image_backfill.NewImageBackfill(opt, database, images, report).Run(ctx)
+		return err
+	})
+
+	fmt.Fprintf(w, "%d row(s) scanned, %d image request(s) %s, %d skipped without image\n", result.Scanned, result.Sent, verb, result.Skipped)
+	return err
+}
@@ -0,0 +1,44 @@
+package eventing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/middleware"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/metrics"
+)
+
+// MetricsMiddleware records the outcome and duration of every processing attempt. It has to be
+// added last so it times the processor alone, retries and dead lettered messages are recorded
+// by the dead letter middleware which decides about them
+type MetricsMiddleware[M any] struct {
+	entity string
+}
+
+func NewMetricsMiddleware[M any](processorName string) *MetricsMiddleware[M] {
+	return &MetricsMiddleware[M]{
+		entity: metricsEntity(processorName),
+	}
+}
+
+func (m *MetricsMiddleware[M]) Process(ctx context.Context, data event.Event[*kafka.Message, M], next middleware.Handler[*kafka.Message, M]) (*event.Event[*kafka.Message, M], error) {
+	start := time.Now()
+	result, err := next(ctx, data)
+	metrics.ObserveProcessed(m.entity, messageAction(data.DriverMessage), time.Since(start), err)
+	return result, err
+}
+
+// metricsEntity labels metrics with the entity a processor syncs, e.g. anime_season for anime_season_processor
+func metricsEntity(processorName string) string {
+	return strings.TrimSuffix(processorName, "_processor")
+}
+
+func messageAction(message *kafka.Message) string {
+	if message == nil {
+		return metrics.ActionUnknown
+	}
+	return metrics.Action(message.Value)
+}
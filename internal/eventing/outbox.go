@@ -0,0 +1,77 @@
+package eventing
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/producer"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/outbox_relay"
+	"go.uber.org/zap"
+)
+
+// syncProducer returns the producer processors use for topic, writing to the outbox
+// when it is enabled and producing to kafka directly otherwise
+func syncProducer(ctx context.Context, cfg config.Config, driver drivers.Driver[*kafka.Message], database *db.DB, topic string) func(ctx context.Context, message *kafka.Message) error {
+	if cfg.OutboxConfig.Enabled {
+		return producer.NewOutboxProducer(database, topic)
+	}
+	return kafkaProducer(ctx, driver, topic)
+}
+
+// syncBatchProducer is syncProducer for processors that publish batches
+func syncBatchProducer(cfg config.Config, kafkaProducer *producer.KafkaBatchProducer, database *db.DB, topic string) producer.BatchProducer {
+	if cfg.OutboxConfig.Enabled {
+		return producer.NewOutboxBatchProducer(database, topic)
+	}
+	return kafkaProducer.Producer(topic)
+}
+
+// syncPublisher publishes messages of type T to topic through syncProducer
+func syncPublisher[T any](ctx context.Context, cfg config.Config, driver drivers.Driver[*kafka.Message], database *db.DB, topic string) publisher.Publisher[T] {
+	return publisher.NewKafka[T](syncProducer(ctx, cfg, driver, database, topic))
+}
+
+// syncBatchPublisher publishes messages of type T to topic through syncBatchProducer, the messages of
+// a call as one batch
+func syncBatchPublisher[T any](cfg config.Config, kafkaProducer *producer.KafkaBatchProducer, database *db.DB, topic string) publisher.Publisher[T] {
+	return publisher.NewKafkaBatch[T](syncBatchProducer(cfg, kafkaProducer, database, topic))
+}
+
+// startOutboxRelay runs the outbox relay in the background unless the outbox is disabled
+// or the relay is run on its own with serve-outbox-relay. The returned func stops the relay
+// and waits for its current batch, call it before closing the database
+func startOutboxRelay(ctx context.Context, cfg config.Config, driver drivers.Driver[*kafka.Message], database *db.DB) func() {
+	if !cfg.OutboxConfig.Enabled || cfg.OutboxConfig.ExternalRelay {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	relay := newOutboxRelay(cfg, driver, database)
+	go func() {
+		defer close(done)
+		if err := relay.Run(ctx); err != nil {
+			logger.FromCtx(ctx).Error("Outbox relay stopped", zap.Error(err))
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func newOutboxRelay(cfg config.Config, driver drivers.Driver[*kafka.Message], database *db.DB) outbox_relay.OutboxRelay {
+	return outbox_relay.NewOutboxRelay(outbox_relay.Options{
+		BatchSize:    cfg.OutboxConfig.BatchSize,
+		PollInterval: time.Duration(cfg.OutboxConfig.PollIntervalMs) * time.Millisecond,
+		Retention:    time.Duration(cfg.OutboxConfig.RetentionHours) * time.Hour,
+	}, database, driver.Produce)
+}
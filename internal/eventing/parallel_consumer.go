@@ -0,0 +1,267 @@
+package eventing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/workerpool"
+)
+
+// commitInterval is how often the parallel consumer commits the offsets its workers got past
+const commitInterval = 100 * time.Millisecond
+
+// ServeOptions are the flags shared by the serve commands
+type ServeOptions struct {
+	// Workers overrides CONSUMER_WORKERS when set
+	Workers int
+}
+
+func (o ServeOptions) workers(cfg config.Config) int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return max(cfg.ConsumerConfig.Workers, 1)
+}
+
+// parallelConsumer consumes topics like the Kafka driver, but hands the messages to a pool of
+// workers keyed by the message key, so rows with different ids are processed concurrently while the
+// events of one row stay in order. A partition is only committed up to its lowest message that is
+// still in flight, so a crash replays every message that was not processed yet
+type parallelConsumer struct {
+	consumer kafkaConsumer
+	shutdown *shutdown
+	pool     *workerpool.Pool
+	handle   MessageHandler
+	offsets  *offsetTracker
+
+	mu sync.Mutex
+	// err is the first handler error, workers skip their queued messages once it is set
+	err error
+	// rebalanceErr is the error of committing the revoked partitions
+	rebalanceErr error
+}
+
+func newParallelConsumer(consumer kafkaConsumer, shutdown *shutdown, workers int, handle MessageHandler) *parallelConsumer {
+	return &parallelConsumer{
+		consumer: consumer,
+		shutdown: shutdown,
+		pool:     workerpool.New(workers),
+		handle:   handle,
+		offsets:  newOffsetTracker(),
+	}
+}
+
+// consumeInParallel consumes topics with workers until the shutdown signal
+func consumeInParallel(shutdown *shutdown, kafkaConfig *epKafka.KafkaConfig, topics []string, workers int, handle MessageHandler) error {
+	consumer, err := newKafkaConsumer(kafkaConfig)
+	if err != nil {
+		return err
+	}
+
+	parallelConsumer := newParallelConsumer(consumer, shutdown, workers, handle)
+	return shutdown.run(func(ctx context.Context) error {
+		return parallelConsumer.Run(ctx, topics)
+	})
+}
+
+// Run consumes topics until ctx is cancelled or a message fails. On cancellation the messages being
+// processed are finished and committed before it returns, queued messages are left for redelivery
+func (c *parallelConsumer) Run(ctx context.Context, topics []string) error {
+	defer c.consumer.Close()
+	defer c.pool.Close()
+
+	err := c.consumer.SubscribeTopics(topics, func(_ *kafka.Consumer, event kafka.Event) error {
+		// the partitions are handed to another consumer, which starts at the committed offsets
+		if revoked, ok := event.(kafka.RevokedPartitions); ok && c.rebalanceErr == nil {
+			c.pool.Wait()
+			c.rebalanceErr = c.commit()
+			c.offsets.forget(revoked.Partitions)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	lastCommit := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return c.stop(nil)
+		default:
+		}
+		if err := c.failure(); err != nil {
+			return c.stop(err)
+		}
+
+		message, err := c.consumer.ReadMessage(commitInterval)
+		if c.rebalanceErr != nil {
+			return c.stop(c.rebalanceErr)
+		}
+		if err != nil {
+			kafkaErr, ok := err.(kafka.Error)
+			if !ok || !(kafkaErr.IsRetriable() || kafkaErr.Code() == kafka.ErrTimedOut) {
+				return c.stop(fmt.Errorf("read error: %w", err))
+			}
+		} else if message != nil && message.Value != nil {
+			c.offsets.start(message.TopicPartition)
+			// a message that is not queued stays in flight, so its offset is never committed
+			if err := c.pool.Submit(ctx, workerKey(message), func() { c.process(ctx, message) }); err != nil {
+				return c.stop(nil)
+			}
+		}
+
+		if time.Since(lastCommit) >= commitInterval {
+			if err := c.commit(); err != nil {
+				return c.stop(err)
+			}
+			lastCommit = time.Now()
+		}
+	}
+}
+
+// process handles message on a worker, unless consuming stopped in the meantime
+func (c *parallelConsumer) process(ctx context.Context, message *kafka.Message) {
+	if ctx.Err() != nil || c.failure() != nil {
+		return
+	}
+
+	handlerCtx, cancel := c.shutdown.handlerContext(ctx)
+	defer cancel()
+
+	if err := c.handle(handlerCtx, message); err != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.err == nil {
+			c.err = err
+		}
+		return
+	}
+	c.offsets.done(message.TopicPartition)
+}
+
+func (c *parallelConsumer) failure() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// stop waits for the workers and commits what they processed, err is returned unless committing fails
+func (c *parallelConsumer) stop(err error) error {
+	c.pool.Wait()
+	if err == nil {
+		err = c.failure()
+	}
+	if commitErr := c.commit(); commitErr != nil && err == nil {
+		err = commitErr
+	}
+	return err
+}
+
+func (c *parallelConsumer) commit() error {
+	offsets := c.offsets.committable()
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	if _, err := c.consumer.CommitOffsets(offsets); err != nil {
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// workerKey orders messages by their key, Debezium keys them by the primary key of the row.
+// Messages without a key keep the order of their partition
+func workerKey(message *kafka.Message) string {
+	if len(message.Key) > 0 {
+		return string(message.Key)
+	}
+	return partitionOf(message.TopicPartition)
+}
+
+// offsetTracker follows the messages handed to workers per partition
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[string]*partitionOffsets
+}
+
+// partitionOffsets are the offsets of a partition that are in flight. next is the offset after the
+// last started message and committed the offset last returned by committable
+type partitionOffsets struct {
+	topic     string
+	partition int32
+	inFlight  map[kafka.Offset]struct{}
+	next      kafka.Offset
+	committed kafka.Offset
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: map[string]*partitionOffsets{}}
+}
+
+// start marks the message at partition as in flight
+func (t *offsetTracker) start(partition kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionOf(partition)
+	offsets, ok := t.partitions[key]
+	if !ok {
+		offsets = &partitionOffsets{
+			topic:     topicOf(partition),
+			partition: partition.Partition,
+			inFlight:  map[kafka.Offset]struct{}{},
+			committed: partition.Offset,
+		}
+		t.partitions[key] = offsets
+	}
+	offsets.inFlight[partition.Offset] = struct{}{}
+	offsets.next = partition.Offset + 1
+}
+
+// done marks the message at partition as processed
+func (t *offsetTracker) done(partition kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if offsets, ok := t.partitions[partitionOf(partition)]; ok {
+		delete(offsets.inFlight, partition.Offset)
+	}
+}
+
+// committable returns the partitions whose commit offset moved since the last call. The commit
+// offset is the lowest offset still in flight, or the one after the last message when none is
+func (t *offsetTracker) committable() []kafka.TopicPartition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var commits []kafka.TopicPartition
+	for _, offsets := range t.partitions {
+		commit := offsets.next
+		for offset := range offsets.inFlight {
+			commit = min(commit, offset)
+		}
+		if commit <= offsets.committed {
+			continue
+		}
+
+		offsets.committed = commit
+		commits = append(commits, kafka.TopicPartition{Topic: &offsets.topic, Partition: offsets.partition, Offset: commit})
+	}
+	return commits
+}
+
+// forget drops revoked partitions, they start over from the committed offset when assigned again
+func (t *offsetTracker) forget(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, partition := range partitions {
+		delete(t.partitions, partitionOf(partition))
+	}
+}
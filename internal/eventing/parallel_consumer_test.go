@@ -0,0 +1,200 @@
+package eventing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	topic := "anime-db.public.anime"
+	at := func(offset int64) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(offset)}
+	}
+
+	tracker := newOffsetTracker()
+	tracker.start(at(10))
+	tracker.start(at(11))
+	tracker.start(at(12))
+
+	tracker.done(at(11))
+	tracker.done(at(12))
+	assert.Empty(t, tracker.committable(), "nothing is committed while the first message is in flight")
+
+	tracker.done(at(10))
+	assert.Equal(t, []kafka.TopicPartition{at(13)}, tracker.committable())
+	assert.Empty(t, tracker.committable(), "an offset is only returned once")
+
+	tracker.start(at(13))
+	tracker.start(at(14))
+	tracker.done(at(14))
+	assert.Empty(t, tracker.committable())
+
+	tracker.forget([]kafka.TopicPartition{at(0)})
+	tracker.done(at(13))
+	assert.Empty(t, tracker.committable(), "revoked partitions are not committed")
+}
+
+func TestParallelConsumer(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	topic := "anime-db.public.anime"
+
+	message := func(key string, offset int64) *kafka.Message {
+		return &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(offset)},
+			Key:            []byte(key),
+			Value:          []byte(`{"payload":{"after":{"id":"` + key + `"}}}`),
+		}
+	}
+	handled := func(message *kafka.Message) string {
+		return fmt.Sprintf("handle %s@%d", message.Key, message.TopicPartition.Offset)
+	}
+	commits := func(events []string) []string {
+		var commits []string
+		for _, event := range events {
+			if strings.HasPrefix(event, "commit") {
+				commits = append(commits, event)
+			}
+		}
+		return commits
+	}
+
+	start := func(consumer *fakeConsumer, handle MessageHandler) (context.CancelFunc, chan error) {
+		shutdown := newShutdown(ctx, time.Minute)
+		runCtx, cancel := context.WithCancel(ctx)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- newParallelConsumer(consumer, shutdown, 4, handle).Run(runCtx, []string{topic})
+			shutdown.stop()
+		}()
+		return cancel, done
+	}
+
+	t.Run("SlowMessageHoldsBackCommit", func(t *testing.T) {
+		consumer := &fakeConsumer{messages: []*kafka.Message{message("a", 0), message("b", 1), message("b", 2)}}
+		release := make(chan struct{})
+		cancel, done := start(consumer, func(ctx context.Context, message *kafka.Message) error {
+			if string(message.Key) == "a" {
+				<-release
+			}
+			consumer.record(handled(message))
+			return nil
+		})
+		defer cancel()
+
+		require.Eventually(t, func() bool {
+			return slices.Contains(consumer.recorded(), "handle b@2")
+		}, time.Second, time.Millisecond, "other keys are processed while a is blocked")
+		// give the consumer a few commit intervals to commit anything it should not
+		time.Sleep(3 * commitInterval)
+
+		events := consumer.recorded()
+		assert.Equal(t, []string{"handle b@1", "handle b@2"}, events, "b should be handled in order and nothing committed")
+
+		close(release)
+		require.Eventually(t, func() bool {
+			return slices.Contains(consumer.recorded(), "commit 0/3")
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-done)
+		assert.Equal(t, []string{"commit 0/3"}, commits(consumer.recorded()))
+	})
+
+	t.Run("FailedMessageStopsBeforeItsOffset", func(t *testing.T) {
+		consumer := &fakeConsumer{messages: []*kafka.Message{message("a", 0), message("b", 1), message("c", 2)}}
+		cancel, done := start(consumer, func(ctx context.Context, message *kafka.Message) error {
+			if string(message.Key) == "b" {
+				return errors.New("table anime does not exist")
+			}
+			consumer.record(handled(message))
+			return nil
+		})
+		defer cancel()
+
+		select {
+		case err := <-done:
+			require.Error(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("consumer did not stop after the failed message")
+		}
+		// a may be skipped when b failed first, either way nothing past b is committed
+		assert.Subset(t, []string{"commit 0/1"}, commits(consumer.recorded()))
+	})
+}
+
+// TestParallelConsumerMiddlewares runs the serve command middleware chain on the workers of a
+// parallel consumer, run it with -race to catch state the middlewares share between messages
+func TestParallelConsumerMiddlewares(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	topic := "anime"
+
+	var messages []*kafka.Message
+	for offset := int64(0); offset < 40; offset++ {
+		key := fmt.Sprintf("%d", offset%4)
+		messages = append(messages, &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(offset)},
+			Key:            []byte(key),
+			Value:          []byte(`{"payload":{"after":{"id":"` + key + `"}}}`),
+		})
+	}
+	consumer := &fakeConsumer{messages: messages}
+	driver := &fakeDriver{}
+
+	process := func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (event.Event[*kafka.Message, map[string]any], error) {
+		// keeps the workers busy at the same time
+		time.Sleep(time.Millisecond)
+		if string(data.DriverMessage.Key) == "1" {
+			return data, errors.New("character c1 does not exist yet")
+		}
+		consumer.record(fmt.Sprintf("handle %s@%d", data.DriverMessage.Key, data.DriverMessage.TopicPartition.Offset))
+		return data, nil
+	}
+	handle := newTableHandlerFactory[map[string]any](driver, testRetryPolicy, "anime_processor", process)(topic)
+
+	shutdown := newShutdown(ctx, time.Minute)
+	defer shutdown.stop()
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- newParallelConsumer(consumer, shutdown, 4, handle).Run(runCtx, []string{topic})
+	}()
+
+	require.Eventually(t, func() bool {
+		return slices.Contains(consumer.recorded(), "commit 0/40")
+	}, 5*time.Second, time.Millisecond, "failed messages are re-queued and every offset is committed")
+	cancel()
+	require.NoError(t, <-done)
+
+	handled := 0
+	for _, event := range consumer.recorded() {
+		if strings.HasPrefix(event, "handle") {
+			handled++
+		}
+	}
+	assert.Equal(t, 30, handled)
+
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	require.Len(t, driver.produced, 10)
+	for _, produced := range driver.produced {
+		assert.Equal(t, "anime-retry", produced.topic)
+		assert.Equal(t, []byte("1"), produced.message.Key)
+		assert.Contains(t, produced.message.Headers, kafka.Header{Key: retryHeaderKey, Value: []byte("1")})
+	}
+}
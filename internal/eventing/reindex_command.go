@@ -0,0 +1,75 @@
+package eventing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	epKafka "github.com/ThatCatDev/ep/v2/drivers/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/reindex"
+	"go.uber.org/zap"
+)
+
+// Reindex publishes a search document for every selected anime and season to the algolia topic,
+// printing progress to w. SIGINT and SIGTERM stop it after the current document, run it again
+// with Resume to continue from there
+func Reindex(w io.Writer, opt reindex.Options) error {
+	cfg := config.LoadConfigOrPanic()
+	ctx := context.Background()
+	log := logger.Get()
+	ctx = logger.WithCtx(ctx, log)
+
+	shutdown := newShutdown(ctx, cfg.AppConfig.ShutdownTimeout())
+	defer shutdown.stop()
+	ctx = shutdown.ctx
+
+	kafkaConfig := &epKafka.KafkaConfig{
+		ConsumerGroupName:        cfg.KafkaConfig.ConsumerGroupName,
+		BootstrapServers:         cfg.KafkaConfig.BootstrapServers,
+		SaslMechanism:            nil,
+		SecurityProtocol:         nil,
+		Username:                 nil,
+		Password:                 nil,
+		ConsumerSessionTimeoutMs: nil,
+		ConsumerAutoOffsetReset:  &cfg.KafkaConfig.Offset,
+		ClientID:                 nil,
+		Debug:                    nil,
+	}
+
+	driver := epKafka.NewKafkaDriver(kafkaConfig)
+	defer func(driver drivers.Driver[*kafka.Message]) {
+		err := driver.Close()
+		if err != nil {
+			log.Error("Error closing Kafka driver", zap.String("error", err.Error()))
+		}
+	}(driver)
+
+	database := db.NewDB(cfg.DBConfig)
+	defer closeDatabase(ctx, database)
+
+	report := func(progress reindex.Progress) {
+		if progress.Done {
+			fmt.Fprintf(w, "%s: done, %d document(s) published\n", progress.Table, progress.Published)
+			return
+		}
+		percent := 100.0
+		if progress.Total > 0 {
+			percent = float64(progress.Published) * 100 / float64(progress.Total)
+		}
+		fmt.Fprintf(w, "%s: %d/%d (%.1f%%) up to %s\n", progress.Table, progress.Published, progress.Total, percent, progress.LastID)
+	}
+
+	reindexer := reindex.NewReindexer(opt, database, publisher.NewKafka[json.RawMessage](kafkaProducer(ctx, driver, cfg.KafkaConfig.AlgoliaTopic)), report)
+	err := shutdown.run(reindexer.Run)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("reindex interrupted, continue it with --resume --checkpoint %s: %w", opt.Checkpoint, err)
+	}
+	return err
+}
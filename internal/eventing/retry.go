@@ -0,0 +1,162 @@
+package eventing
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/middleware"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/processor"
+	"go.uber.org/zap"
+)
+
+// links routinely arrive before the character or staff row they point at, so unless configured
+// otherwise they get more attempts than the other entities before being dropped. The interval is short
+// and fixed, so a retried link waits about a second on the retry topic and the links re-queued after
+// it are due by the time it went through
+var linkRetryPolicy = config.RetryPolicy{
+	MaxRetries:        30,
+	InitialIntervalMs: 1000,
+	MaxIntervalMs:     1000,
+}
+
+// newRetryMiddlewares builds the backoff retry and dead letter middlewares for a processor consuming
+// topic. Both are created from the same policy so the dead letter middleware knows the last retry
+func newRetryMiddlewares[M any](driver drivers.Driver[*kafka.Message], policy config.RetryPolicy, topic string, processorName string) (*BackoffRetryMiddleware[M], *DeadLetterMiddleware[M]) {
+	backoffRetryInstance := NewBackoffRetryMiddleware[M](driver, BackoffRetryConfig{
+		MaxRetries:      policy.MaxRetries,
+		RetryTopic:      policy.RetryTopic(topic),
+		InitialInterval: policy.InitialInterval(),
+		MaxInterval:     policy.MaxInterval(),
+		Multiplier:      policy.Multiplier,
+	})
+
+	deadLetterInstance := NewDeadLetterMiddleware[M](driver, DeadLetterConfig{
+		Topic:       policy.DeadLetterTopic(topic),
+		SourceTopic: policy.SourceTopic(topic),
+		Processor:   processorName,
+		MaxRetries:  policy.MaxRetries,
+	})
+
+	return backoffRetryInstance, deadLetterInstance
+}
+
+// pulsarProcessorOptions maps a retry policy onto the in process retries of the Pulsar processor
+func pulsarProcessorOptions(policy config.RetryPolicy) processor.Options {
+	return processor.Options{
+		MaxRetries:      policy.MaxRetries,
+		InitialInterval: policy.InitialInterval(),
+		MaxInterval:     policy.MaxInterval(),
+		Multiplier:      policy.Multiplier,
+	}
+}
+
+type BackoffRetryConfig struct {
+	MaxRetries int
+	// RetryTopic is the topic failed messages are re-queued on
+	RetryTopic      string
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+}
+
+// BackoffRetryMiddleware re-queues failed messages on the retry topic right away, with the retry count
+// in the retry header and the time they are due in the not before header, until MaxRetries is reached.
+// The error of the last retry is returned, the dead letter middleware inside of it handles it first.
+// The interval grows with the retry count. A message consumed before it is due waits until then, a
+// shutdown signal ends the wait and re-queues it as is. Unlike the ep backoff retry it never sleeps
+// after a failure and keeps no state between messages, so it can be shared by the workers of a
+// parallel consumer and a failing message doesn't slow down the others
+type BackoffRetryMiddleware[M any] struct {
+	driver drivers.Driver[*kafka.Message]
+	config BackoffRetryConfig
+}
+
+func NewBackoffRetryMiddleware[M any](driver drivers.Driver[*kafka.Message], config BackoffRetryConfig) *BackoffRetryMiddleware[M] {
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = backoff.DefaultInitialInterval
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = backoff.DefaultMaxInterval
+	}
+	if config.Multiplier <= 0 {
+		config.Multiplier = backoff.DefaultMultiplier
+	}
+
+	return &BackoffRetryMiddleware[M]{
+		driver: driver,
+		config: config,
+	}
+}
+
+func (b *BackoffRetryMiddleware[M]) Process(ctx context.Context, data event.Event[*kafka.Message, M], next middleware.Handler[*kafka.Message, M]) (*event.Event[*kafka.Message, M], error) {
+	retryCount, _ := strconv.Atoi(data.Headers[retryHeaderKey])
+
+	if notBefore, err := strconv.ParseInt(data.Headers[notBeforeHeaderKey], 10, 64); err == nil {
+		if wait := time.Until(time.UnixMilli(notBefore)); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-stopping(ctx):
+				return &data, b.requeue(ctx, data, data.Headers)
+			case <-ctx.Done():
+				return &data, b.requeue(ctx, data, data.Headers)
+			}
+		}
+	}
+
+	result, err := next(ctx, data)
+	if err == nil {
+		return result, nil
+	}
+
+	if retryCount+1 >= b.config.MaxRetries {
+		// the dead letter middleware returns nil once it published the message, an error here means
+		// it could not, so the offset must not be committed
+		logger.FromCtx(ctx).Error("Retries exhausted and message not dead lettered", zap.Int("retries", retryCount), zap.Error(err))
+		return &data, err
+	}
+
+	headers := make(map[string]string, len(data.Headers)+2)
+	for k, v := range data.Headers {
+		headers[k] = v
+	}
+	headers[retryHeaderKey] = strconv.Itoa(retryCount + 1)
+	headers[notBeforeHeaderKey] = strconv.FormatInt(time.Now().Add(b.interval(retryCount)).UnixMilli(), 10)
+	return &data, b.requeue(ctx, data, headers)
+}
+
+func (b *BackoffRetryMiddleware[M]) requeue(ctx context.Context, data event.Event[*kafka.Message, M], headers map[string]string) error {
+	kafkaHeaders := make([]kafka.Header, 0, len(headers))
+	for k, v := range headers {
+		kafkaHeaders = append(kafkaHeaders, kafka.Header{Key: k, Value: []byte(v)})
+	}
+
+	err := b.driver.Produce(context.WithoutCancel(ctx), b.config.RetryTopic, &kafka.Message{
+		Key:     data.DriverMessage.Key,
+		Value:   data.DriverMessage.Value,
+		Headers: kafkaHeaders,
+	})
+	if err != nil {
+		logger.FromCtx(ctx).Error("Failed to re-queue message", zap.String("topic", b.config.RetryTopic), zap.Error(err))
+	}
+	return err
+}
+
+// interval is the wait before the retry after retryCount earlier retries
+func (b *BackoffRetryMiddleware[M]) interval(retryCount int) time.Duration {
+	interval := float64(b.config.InitialInterval) * math.Pow(b.config.Multiplier, float64(retryCount))
+	if interval > float64(b.config.MaxInterval) {
+		return b.config.MaxInterval
+	}
+	return time.Duration(interval)
+}
@@ -0,0 +1,158 @@
+package eventing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character_staff_link"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/character_staff_link_processor"
+)
+
+func TestBackoffRetryMiddleware(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	newEvent := func(headers map[string]string) event.Event[*kafka.Message, map[string]any] {
+		return event.Event[*kafka.Message, map[string]any]{
+			Headers:       headers,
+			DriverMessage: &kafka.Message{Key: []byte("link-1"), Value: []byte(`{}`)},
+		}
+	}
+	headersOf := func(message *kafka.Message) map[string]string {
+		headers := map[string]string{}
+		for _, header := range message.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		return headers
+	}
+
+	newRetry := func(driver *fakeDriver) *BackoffRetryMiddleware[map[string]any] {
+		return NewBackoffRetryMiddleware[map[string]any](driver, BackoffRetryConfig{
+			MaxRetries:      3,
+			RetryTopic:      "links-retry",
+			InitialInterval: time.Hour,
+			MaxInterval:     time.Hour,
+		})
+	}
+	orphan := func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+		return &data, errors.New("character c1 does not exist yet")
+	}
+
+	t.Run("RequeuesWithoutWaiting", func(t *testing.T) {
+		driver := &fakeDriver{}
+		start := time.Now()
+
+		_, err := newRetry(driver).Process(ctx, newEvent(map[string]string{}), orphan)
+		require.NoError(t, err)
+
+		assert.Less(t, time.Since(start), time.Second)
+		require.Len(t, driver.produced, 1)
+		assert.Equal(t, "links-retry", driver.produced[0].topic)
+		headers := headersOf(driver.produced[0].message)
+		assert.Equal(t, "1", headers[retryHeaderKey])
+		notBefore, err := strconv.ParseInt(headers[notBeforeHeaderKey], 10, 64)
+		require.NoError(t, err)
+		assert.WithinDuration(t, start.Add(time.Hour), time.UnixMilli(notBefore), time.Second)
+	})
+
+	t.Run("FailsAfterMaxRetries", func(t *testing.T) {
+		driver := &fakeDriver{}
+
+		_, err := newRetry(driver).Process(ctx, newEvent(map[string]string{retryHeaderKey: "2"}), orphan)
+		require.Error(t, err, "the message was not dead lettered, its offset must not be committed")
+		assert.Empty(t, driver.produced)
+	})
+
+	t.Run("WaitsUntilDue", func(t *testing.T) {
+		driver := &fakeDriver{}
+		notBefore := time.Now().Add(20 * time.Millisecond)
+
+		var processedAt time.Time
+		_, err := newRetry(driver).Process(ctx, newEvent(map[string]string{
+			retryHeaderKey:     "1",
+			notBeforeHeaderKey: strconv.FormatInt(notBefore.UnixMilli(), 10),
+		}), func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+			processedAt = time.Now()
+			return &data, nil
+		})
+		require.NoError(t, err)
+
+		assert.False(t, processedAt.Before(notBefore.Truncate(time.Millisecond)))
+		assert.Empty(t, driver.produced)
+	})
+
+	t.Run("ShutdownRequeuesWaitingMessage", func(t *testing.T) {
+		driver := &fakeDriver{}
+		shutdown := newShutdown(ctx, time.Minute)
+		defer shutdown.stop()
+		signalCtx, signal := context.WithCancel(ctx)
+		shutdown.ctx = signalCtx
+
+		handlerCtx, cancel := shutdown.handlerContext(ctx)
+		defer cancel()
+		signal()
+
+		headers := map[string]string{
+			retryHeaderKey:     "1",
+			notBeforeHeaderKey: strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10),
+		}
+		_, err := newRetry(driver).Process(handlerCtx, newEvent(headers), func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+			t.Fatal("a message that is not due must not be processed")
+			return &data, nil
+		})
+		require.NoError(t, err)
+
+		require.Len(t, driver.produced, 1)
+		assert.Equal(t, headers, headersOf(driver.produced[0].message))
+	})
+}
+
+type orphanLinkRepository struct {
+	anime_character_staff_link.AnimeCharacterStaffLinkRepositoryImpl
+	characterSynced bool
+	upserted        []string
+}
+
+func (r *orphanLinkRepository) Upsert(link *anime_character_staff_link.AnimeCharacterStaffLink) error {
+	if !r.characterSynced {
+		return fmt.Errorf("%w: %w", anime_character_staff_link.ErrMissingReference, &mysql.MySQLError{Number: 1452, Message: "foreign key constraint fails"})
+	}
+	r.upserted = append(r.upserted, link.ID)
+	return nil
+}
+
+func TestOrphanLinkRetry(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	driver := &fakeDriver{}
+	repository := &orphanLinkRepository{}
+	linkProcessor := &character_staff_link_processor.CharacterStaffLinkProcessorImpl{Repository: repository}
+
+	factory := newTableHandlerFactory[character_staff_link_processor.Payload](driver, testRetryPolicy, "character_staff_link_processor", linkProcessor.Process)
+	topic := "links"
+	retryTopic := testRetryPolicy.RetryTopic(topic)
+
+	message := &kafka.Message{Key: []byte("link-1"), Value: []byte(`{"payload":{"after":{"id":"link-1","character_id":"c1","staff_id":"s1"}}}`)}
+	require.NoError(t, factory(topic)(ctx, message))
+
+	require.Len(t, driver.produced, 1, "the orphan link is re-queued")
+	requeued := driver.produced[0]
+	assert.Equal(t, retryTopic, requeued.topic)
+	assert.Empty(t, repository.upserted)
+
+	repository.characterSynced = true
+	require.NoError(t, factory(retryTopic)(ctx, requeued.message))
+
+	assert.Equal(t, []string{"link-1"}, repository.upserted)
+	assert.Len(t, driver.produced, 1, "the retried link went through")
+}
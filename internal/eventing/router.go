@@ -0,0 +1,253 @@
+package eventing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/middleware"
+	"github.com/ThatCatDev/ep/v2/processor"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"go.uber.org/zap"
+)
+
+// MessageHandler processes a single raw Kafka message
+type MessageHandler func(ctx context.Context, message *kafka.Message) error
+
+// HandlerFactory builds the handler for one table consuming topic, it derives the retry and
+// dead letter topics from it through the retry policy of the table
+type HandlerFactory func(topic string) MessageHandler
+
+// NewMessageHandler runs a message through the middlewares and process function the same way
+// the ep processor does, so one consumer can feed processors with different payload types.
+// Unlike the ep processor it leaves decoding the payload to the middlewares
+func NewMessageHandler[M any](driver drivers.Driver[*kafka.Message], process processor.Process[*kafka.Message, M], middlewares ...middleware.Middleware[*kafka.Message, M]) MessageHandler {
+	return func(ctx context.Context, message *kafka.Message) error {
+		extractedData, err := driver.ExtractEvent(message)
+		if err != nil {
+			return err
+		}
+
+		// the payload is decoded by the transform middleware, inside the dead letter middleware, so a
+		// message that does not decode is dead-lettered instead of failing the consumer
+		data := &event.Event[*kafka.Message, M]{
+			Headers:       extractedData.Headers,
+			DriverMessage: message,
+			RawData:       extractedData.RawData,
+		}
+
+		chain, err := middleware.Chain[*kafka.Message, M](append(middlewares, func(ctx context.Context, data event.Event[*kafka.Message, M], next middleware.Handler[*kafka.Message, M]) (*event.Event[*kafka.Message, M], error) {
+			_, err := process(ctx, data)
+			if err != nil {
+				return &data, err
+			}
+			return next(ctx, data)
+		})...)
+		if err != nil {
+			return err
+		}
+
+		_, err = chain(ctx, *data)
+		return err
+	}
+}
+
+// Router consumes several topics and dispatches every message to the handler registered
+// for its Debezium source table, falling back to a configured topic→table map
+type Router struct {
+	driver      drivers.Driver[*kafka.Message]
+	factories   map[string]HandlerFactory
+	topicTables map[string]string
+
+	mu       sync.Mutex
+	handlers map[string]MessageHandler
+
+	batchHandlers map[string]BatchHandler
+}
+
+func NewRouter(driver drivers.Driver[*kafka.Message], topicTables map[string]string) *Router {
+	return &Router{
+		driver:      driver,
+		factories:   map[string]HandlerFactory{},
+		topicTables: topicTables,
+		handlers:    map[string]MessageHandler{},
+
+		batchHandlers: map[string]BatchHandler{},
+	}
+}
+
+// Register adds the handler factory for a source table
+func (r *Router) Register(table string, factory HandlerFactory) *Router {
+	r.factories[table] = factory
+	return r
+}
+
+// RegisterBatch adds the handler applying snapshot messages of a source table in batches
+func (r *Router) RegisterBatch(table string, handler BatchHandler) *Router {
+	r.batchHandlers[table] = handler
+	return r
+}
+
+// Run consumes all topics until the context is cancelled or one of the consumers fails
+func (r *Router) Run(ctx context.Context, topics []string) error {
+	log := logger.FromCtx(ctx)
+	if len(topics) == 0 {
+		return fmt.Errorf("no topics configured")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(topics))
+	for _, topic := range topics {
+		topic := topic
+		log.Info("Starting Kafka router", zap.String("topic", topic))
+		go func() {
+			errs <- r.driver.Consume(ctx, topic, func(ctx context.Context, message *kafka.Message, _ []byte) error {
+				return r.Dispatch(ctx, topic, message)
+			})
+		}()
+	}
+
+	var err error
+	for range topics {
+		consumeErr := <-errs
+		if consumeErr != nil && err == nil {
+			err = consumeErr
+			cancel()
+		}
+	}
+	return err
+}
+
+// Dispatch routes one message to its table handler, messages for unknown tables are skipped
+func (r *Router) Dispatch(ctx context.Context, topic string, message *kafka.Message) error {
+	log := logger.FromCtx(ctx)
+
+	table := r.resolveTable(topic, message)
+	if table == "" {
+		log.Warn("Could not resolve source table, skipping message", zap.String("topic", topic))
+		return nil
+	}
+
+	handler, ok := r.handler(topic, table)
+	if !ok {
+		log.Warn("No processor registered for table, skipping message", zap.String("topic", topic), zap.String("table", table))
+		return nil
+	}
+
+	log.Debug("Routing message", zap.String("topic", topic), zap.String("table", table))
+	return handler(logger.WithCtx(ctx, log.With(zap.String("table", table))), message)
+}
+
+// Handle dispatches message by the topic it was consumed from
+func (r *Router) Handle(ctx context.Context, message *kafka.Message) error {
+	return r.Dispatch(ctx, topicOf(message.TopicPartition), message)
+}
+
+// Batchable reports whether message is a snapshot event of a table with a batch handler
+func (r *Router) Batchable(message *kafka.Message) bool {
+	if !IsSnapshot(message) {
+		return false
+	}
+	_, ok := r.batchHandlers[r.resolveTable(topicOf(message.TopicPartition), message)]
+	return ok
+}
+
+// DispatchBatch routes a batch of one partition, consecutive messages of the same table are handed
+// to the batch handler of the table together. It only takes messages Batchable accepted
+func (r *Router) DispatchBatch(ctx context.Context, messages []*kafka.Message) error {
+	log := logger.FromCtx(ctx)
+	topic := topicOf(messages[0].TopicPartition)
+
+	tables := make([]string, len(messages))
+	for i, message := range messages {
+		tables[i] = r.resolveTable(topic, message)
+	}
+
+	for start := 0; start < len(messages); {
+		table := tables[start]
+		end := start + 1
+		for end < len(messages) && tables[end] == table {
+			end++
+		}
+
+		handler, ok := r.batchHandlers[table]
+		if !ok {
+			return fmt.Errorf("no batch handler registered for table %q", table)
+		}
+
+		log.Debug("Routing batch", zap.String("topic", topic), zap.String("table", table), zap.Int("messages", end-start))
+		if err := handler(logger.WithCtx(ctx, log.With(zap.String("table", table))), messages[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (r *Router) resolveTable(topic string, message *kafka.Message) string {
+	var envelope struct {
+		Payload struct {
+			Source struct {
+				Table string `json:"table"`
+			} `json:"source"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(message.Value, &envelope); err == nil && envelope.Payload.Source.Table != "" {
+		return envelope.Payload.Source.Table
+	}
+
+	return r.topicTables[topic]
+}
+
+// handler lazily builds the handler of a topic and table and caches it, the retry and dead letter
+// topics of a handler are derived from its topic
+func (r *Router) handler(topic string, table string) (MessageHandler, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := topic + "|" + table
+	if handler, ok := r.handlers[key]; ok {
+		return handler, true
+	}
+
+	factory, ok := r.factories[table]
+	if !ok {
+		return nil, false
+	}
+
+	handler := factory(topic)
+	r.handlers[key] = handler
+	return handler, true
+}
+
+// ParseTopicTables parses "topic=table,topic=table" into a map
+func ParseTopicTables(value string) map[string]string {
+	topicTables := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		topic, table, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || topic == "" || table == "" {
+			continue
+		}
+		topicTables[strings.TrimSpace(topic)] = strings.TrimSpace(table)
+	}
+	return topicTables
+}
+
+// ParseTopics splits a comma separated topic list
+func ParseTopics(value string) []string {
+	var topics []string
+	for _, topic := range strings.Split(value, ",") {
+		topic = strings.TrimSpace(topic)
+		if topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
@@ -0,0 +1,118 @@
+package eventing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+func TestRouterDispatch(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	var routed []string
+	var handlerTopics []string
+	factory := func(table string) HandlerFactory {
+		return func(topic string) MessageHandler {
+			handlerTopics = append(handlerTopics, topic)
+			return func(ctx context.Context, message *kafka.Message) error {
+				routed = append(routed, table)
+				return nil
+			}
+		}
+	}
+
+	router := NewRouter(nil, ParseTopicTables("legacy-topic=episodes")).
+		Register(TableAnime, factory(TableAnime)).
+		Register(TableEpisodes, factory(TableEpisodes))
+
+	t.Run("RoutesBySourceTable", func(t *testing.T) {
+		routed = nil
+		err := router.Dispatch(ctx, "anime-db.public.anime", &kafka.Message{
+			Value: []byte(`{"schema":{},"payload":{"before":null,"after":{"id":"1"},"source":{"table":"anime"}}}`),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{TableAnime}, routed)
+	})
+
+	t.Run("FallsBackToTopicMap", func(t *testing.T) {
+		routed = nil
+		err := router.Dispatch(ctx, "legacy-topic", &kafka.Message{Value: []byte(`{"payload":{"after":{"id":"1"}}}`)})
+		require.NoError(t, err)
+		assert.Equal(t, []string{TableEpisodes}, routed)
+	})
+
+	t.Run("SkipsUnknownTable", func(t *testing.T) {
+		routed = nil
+		err := router.Dispatch(ctx, "other-topic", &kafka.Message{Value: []byte(`{"payload":{"source":{"table":"users"}}}`)})
+		require.NoError(t, err)
+		assert.Empty(t, routed)
+	})
+
+	t.Run("BuildsOneHandlerPerTopicAndTable", func(t *testing.T) {
+		handlerTopics = nil
+		value := []byte(`{"payload":{"source":{"table":"anime"}}}`)
+		require.NoError(t, router.Dispatch(ctx, "topic-a", &kafka.Message{Value: value}))
+		require.NoError(t, router.Dispatch(ctx, "topic-a", &kafka.Message{Value: value}))
+		require.NoError(t, router.Dispatch(ctx, "topic-b", &kafka.Message{Value: value}))
+		assert.Equal(t, []string{"topic-a", "topic-b"}, handlerTopics)
+	})
+}
+
+func TestParseTopics(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, ParseTopics(" a, ,b "))
+	assert.Nil(t, ParseTopics(""))
+	assert.Equal(t, map[string]string{"a": "anime", "b": "episodes"}, ParseTopicTables("a=anime, b = episodes,broken"))
+}
+
+func TestRouterDispatchBatch(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	var batches [][]string
+	batchHandler := func(table string) BatchHandler {
+		return func(ctx context.Context, messages []*kafka.Message) error {
+			var batch []string
+			for _, message := range messages {
+				batch = append(batch, table+":"+string(message.Key))
+			}
+			batches = append(batches, batch)
+			return nil
+		}
+	}
+
+	router := NewRouter(nil, nil).
+		RegisterBatch(TableAnime, batchHandler(TableAnime)).
+		RegisterBatch(TableEpisodes, batchHandler(TableEpisodes))
+
+	topic := "anime-db.public.all"
+	message := func(key string, table string, snapshot string) *kafka.Message {
+		return &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic},
+			Key:            []byte(key),
+			Value:          []byte(`{"payload":{"after":{"id":"` + key + `"},"source":{"table":"` + table + `","snapshot":"` + snapshot + `"}}}`),
+		}
+	}
+
+	t.Run("BatchableNeedsSnapshotOfRegisteredTable", func(t *testing.T) {
+		assert.True(t, router.Batchable(message("1", TableAnime, "true")))
+		assert.False(t, router.Batchable(message("1", TableAnime, "false")))
+		assert.False(t, router.Batchable(message("1", "users", "true")))
+	})
+
+	t.Run("GroupsConsecutiveMessagesOfATable", func(t *testing.T) {
+		batches = nil
+		err := router.DispatchBatch(ctx, []*kafka.Message{
+			message("1", TableAnime, "true"),
+			message("2", TableAnime, "true"),
+			message("3", TableEpisodes, "true"),
+			message("4", TableAnime, "last"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"anime:1", "anime:2"}, {"episodes:3"}, {"anime:4"}}, batches)
+	})
+}
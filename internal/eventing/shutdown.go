@@ -0,0 +1,116 @@
+package eventing
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/drivers"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"go.uber.org/zap"
+)
+
+// shutdown ties a serve command to SIGINT and SIGTERM. ctx is cancelled on the signal so consumers
+// stop taking new messages, while messages already being handled keep running on a context that is
+// only cancelled once the shutdown timeout runs out. That way their transactions commit and their
+// offsets are committed by the consume loop before it returns
+type shutdown struct {
+	ctx     context.Context
+	drain   context.Context
+	timeout time.Duration
+	stop    func()
+}
+
+func newShutdown(parent context.Context, timeout time.Duration) *shutdown {
+	ctx, stopSignals := signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	drain, cancelDrain := context.WithCancel(context.WithoutCancel(parent))
+
+	stopTimer := context.AfterFunc(ctx, func() {
+		logger.FromCtx(ctx).Info("Shutting down, waiting for in-flight messages", zap.Duration("timeout", timeout))
+		time.AfterFunc(timeout, cancelDrain)
+	})
+
+	return &shutdown{
+		ctx:     ctx,
+		drain:   drain,
+		timeout: timeout,
+		stop: func() {
+			stopTimer()
+			stopSignals()
+			cancelDrain()
+		},
+	}
+}
+
+// run calls fn with the signal aware context and waits for it to return, at most until the
+// shutdown timeout ran out after the signal
+func (s *shutdown) run(fn func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(s.ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-s.drain.Done():
+		return fmt.Errorf("shutdown timed out after %s with messages still in flight", s.timeout)
+	}
+}
+
+// handlerContext detaches ctx from the shutdown signal, it is cancelled when the shutdown times out instead.
+// Handlers that wait can still see the signal through stopping
+func (s *shutdown) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	handlerCtx, cancel := context.WithCancel(context.WithValue(context.WithoutCancel(ctx), stoppingKey{}, s.ctx.Done()))
+	stop := context.AfterFunc(s.drain, cancel)
+	return handlerCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+type stoppingKey struct{}
+
+// stopping returns a channel that is closed on the shutdown signal, for handler contexts. Other
+// contexts get a nil channel, which never fires
+func stopping(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(stoppingKey{}).(<-chan struct{})
+	return done
+}
+
+// driver wraps driver so its consumer handlers run on handler contexts
+func (s *shutdown) driver(driver drivers.Driver[*kafka.Message]) drivers.Driver[*kafka.Message] {
+	return &drainingDriver{
+		Driver:   driver,
+		shutdown: s,
+	}
+}
+
+// drainingDriver lets the message being handled finish when the consume context is cancelled,
+// the wrapped driver checks the context between messages and stops after committing the offset
+type drainingDriver struct {
+	drivers.Driver[*kafka.Message]
+	shutdown *shutdown
+}
+
+func (d *drainingDriver) Consume(ctx context.Context, topic string, handler func(context.Context, *kafka.Message, []byte) error) error {
+	return d.Driver.Consume(ctx, topic, func(ctx context.Context, message *kafka.Message, data []byte) error {
+		handlerCtx, cancel := d.shutdown.handlerContext(ctx)
+		defer cancel()
+		return handler(handlerCtx, message, data)
+	})
+}
+
+// closeDatabase closes the connection pool once the consumers are done with it
+func closeDatabase(ctx context.Context, database *db.DB) {
+	log := logger.FromCtx(ctx)
+	if err := database.Close(); err != nil {
+		log.Error("Error closing database", zap.Error(err))
+	} else {
+		log.Info("Database closed successfully")
+	}
+}
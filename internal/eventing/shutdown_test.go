@@ -0,0 +1,115 @@
+package eventing
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/processor"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+func TestShutdown(t *testing.T) {
+	newMessages := func() []*kafka.Message {
+		var messages []*kafka.Message
+		for _, id := range []string{"1", "2", "3"} {
+			messages = append(messages, &kafka.Message{Key: []byte(id), Value: []byte(`{"payload":{"after":{"id":"` + id + `"}}}`)})
+		}
+		return messages
+	}
+
+	// run consumes with the serve command middleware chain, the first message blocks in process until
+	// the test calls release, processed collects the ids that made it through the processor
+	run := func(shutdown *shutdown, driver *fakeDriver, started chan<- context.Context, release <-chan struct{}) (chan error, *[]string) {
+		var processed []string
+		process := func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (event.Event[*kafka.Message, map[string]any], error) {
+			if len(processed) == 0 {
+				started <- ctx
+				<-release
+				if ctx.Err() != nil {
+					return data, ctx.Err()
+				}
+			}
+			processed = append(processed, string(data.DriverMessage.Key))
+			return data, nil
+		}
+
+		wrapped := shutdown.driver(driver)
+		backoffRetryInstance, deadLetterInstance := newRetryMiddlewares[map[string]any](wrapped, testRetryPolicy, "anime", "anime_processor")
+		processorInstance := processor.NewProcessor[*kafka.Message, map[string]any](wrapped, "anime", process).
+			AddMiddleware(NewLoggerMiddleware[*kafka.Message, map[string]any]().Process).
+			AddMiddleware(backoffRetryInstance.Process).
+			AddMiddleware(deadLetterInstance.Process).
+			AddMiddleware(NewTransformMiddleware[*kafka.Message, map[string]any]().Process)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- shutdown.run(processorInstance.Run)
+		}()
+		return done, &processed
+	}
+
+	t.Run("FinishesInFlightMessageOnSIGTERM", func(t *testing.T) {
+		ctx := logger.WithCtx(context.Background(), zap.NewNop())
+		shutdown := newShutdown(ctx, 5*time.Second)
+		defer shutdown.stop()
+
+		driver := &fakeDriver{messages: newMessages()}
+		started := make(chan context.Context, 1)
+		release := make(chan struct{})
+		done, processed := run(shutdown, driver, started, release)
+
+		handlerCtx := <-started
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+		<-shutdown.ctx.Done()
+		assert.NoError(t, handlerCtx.Err(), "the in-flight message must not see the shutdown")
+		close(release)
+
+		require.NoError(t, <-done)
+		assert.Equal(t, []string{"1"}, *processed)
+		// the in-flight message is committed, the rest stays uncommitted for the next consumer
+		require.Len(t, driver.committed, 1)
+		assert.Equal(t, []byte("1"), driver.committed[0].Key)
+		assert.Empty(t, driver.produced, "nothing may be re-queued or dead lettered on shutdown")
+	})
+
+	t.Run("GivesUpAfterTimeoutWithoutCommitting", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(logger.WithCtx(context.Background(), zap.NewNop()))
+		shutdown := newShutdown(parent, 50*time.Millisecond)
+		defer shutdown.stop()
+
+		driver := &fakeDriver{messages: newMessages()}
+		started := make(chan context.Context, 1)
+		release := make(chan struct{})
+		done, _ := run(shutdown, driver, started, release)
+
+		handlerCtx := <-started
+		cancelParent()
+
+		select {
+		case <-handlerCtx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler context was not cancelled after the shutdown timeout")
+		}
+		assert.ErrorContains(t, <-done, "shutdown timed out")
+		assert.Empty(t, driver.committed, "a message cut off by the timeout must be redelivered")
+		close(release)
+	})
+}
+
+var testRetryPolicy = config.RetryPolicy{
+	MaxRetries:        3,
+	InitialIntervalMs: 1,
+	MaxIntervalMs:     1,
+	TopicSuffix:       "-retry",
+	DLQTopicSuffix:    "-dlq",
+}
@@ -0,0 +1,82 @@
+package eventing
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/middleware"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// tracingShutdownTimeout bounds flushing the spans still buffered when a serve command stops
+const tracingShutdownTimeout = 5 * time.Second
+
+// TracingMiddleware starts the span of a consumed message, continuing the trace found in its headers.
+// It has to be added first so every other stage runs inside it. The span context is written back into
+// the event headers, so messages re-queued on the retry topic or dead lettered continue the same trace
+type TracingMiddleware[M any] struct {
+	topic     string
+	processor string
+}
+
+func NewTracingMiddleware[M any](topic string, processorName string) *TracingMiddleware[M] {
+	return &TracingMiddleware[M]{
+		topic:     topic,
+		processor: processorName,
+	}
+}
+
+func (t *TracingMiddleware[M]) Process(ctx context.Context, data event.Event[*kafka.Message, M], next middleware.Handler[*kafka.Message, M]) (*event.Event[*kafka.Message, M], error) {
+	if data.Headers == nil {
+		data.Headers = map[string]string{}
+	}
+
+	ctx, span := tracing.StartConsumer(ctx, t.topic, data.Headers, data.DriverMessage)
+	span.SetAttributes(attribute.String("anime_sync.processor", t.processor))
+	tracing.Inject(ctx, data.Headers)
+
+	if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+		ctx = logger.WithCtx(ctx, logger.FromCtx(ctx).With(zap.String("trace_id", spanContext.TraceID().String())))
+	}
+
+	result, err := next(ctx, data)
+	tracing.End(span, err)
+	return result, err
+}
+
+// traceStage runs a stage of the middleware chain in a span of its own, the span covers the stages after it
+func traceStage[M any](stage string, m middleware.Middleware[*kafka.Message, M]) middleware.Middleware[*kafka.Message, M] {
+	return func(ctx context.Context, data event.Event[*kafka.Message, M], next middleware.Handler[*kafka.Message, M]) (*event.Event[*kafka.Message, M], error) {
+		ctx, span := tracing.Tracer().Start(ctx, stage, trace.WithAttributes(attribute.String("anime_sync.stage", stage)))
+		result, err := m(ctx, data, next)
+		tracing.End(span, err)
+		return result, err
+	}
+}
+
+// startTracing installs the tracer provider configured for the serve command. Tracing failing to start
+// is logged and the command runs without it. The returned func flushes the remaining spans
+func startTracing(ctx context.Context, cfg config.Config) func() {
+	log := logger.FromCtx(ctx)
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.TracingConfig)
+	if err != nil {
+		log.Error("Failed to start tracing, continuing without it", zap.Error(err))
+		return func() {}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package eventing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/ThatCatDev/ep/v2/middleware"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
+)
+
+// upstream is the trace context the message was produced with, e.g. by Debezium or another service
+const (
+	upstreamTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	upstreamSpanID      = "00f067aa0ba902b7"
+	upstreamTraceparent = "00-" + upstreamTraceID + "-" + upstreamSpanID + "-01"
+)
+
+func setupTestTracing(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return recorder
+}
+
+func spanByName(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %q", name)
+	return nil
+}
+
+func headerValue(message *kafka.Message, key string) string {
+	return tracing.HeaderCarrier{Message: message}.Get(key)
+}
+
+func TestTracing(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	const topic = "anime-db.public.anime"
+
+	newEvent := func() event.Event[*kafka.Message, map[string]any] {
+		return event.Event[*kafka.Message, map[string]any]{
+			Headers: map[string]string{"traceparent": upstreamTraceparent},
+			DriverMessage: &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &[]string{topic}[0], Partition: 2, Offset: 42},
+				Key:            []byte("anime-1"),
+				Value:          []byte(`{"payload":{}}`),
+			},
+		}
+	}
+
+	t.Run("ContinuesTraceIntoProducedMessages", func(t *testing.T) {
+		recorder := setupTestTracing(t)
+		driver := &fakeDriver{}
+		algoliaProducer := kafkaProducer(ctx, driver, "algolia-sync")
+
+		process := func(ctx context.Context, data event.Event[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+			return &data, algoliaProducer(ctx, &kafka.Message{Value: []byte(`{"action":"update"}`)})
+		}
+		chain, err := middleware.Chain[*kafka.Message, map[string]any](
+			NewTracingMiddleware[map[string]any](topic, "anime_processor").Process,
+			traceStage[map[string]any]("process", NewMetricsMiddleware[map[string]any]("anime_processor").Process),
+			func(ctx context.Context, data event.Event[*kafka.Message, map[string]any], _ middleware.Handler[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+				return process(ctx, data)
+			},
+		)
+		require.NoError(t, err)
+
+		_, err = chain(ctx, newEvent())
+		require.NoError(t, err)
+
+		consumer := spanByName(t, recorder, topic+" process")
+		assert.Equal(t, upstreamTraceID, consumer.SpanContext().TraceID().String())
+		assert.Equal(t, upstreamSpanID, consumer.Parent().SpanID().String())
+		assert.Equal(t, trace.SpanKindConsumer, consumer.SpanKind())
+
+		stage := spanByName(t, recorder, "process")
+		assert.Equal(t, consumer.SpanContext().SpanID(), stage.Parent().SpanID())
+
+		producer := spanByName(t, recorder, "algolia-sync publish")
+		assert.Equal(t, stage.SpanContext().SpanID(), producer.Parent().SpanID())
+		assert.Equal(t, trace.SpanKindProducer, producer.SpanKind())
+
+		require.Len(t, driver.produced, 1)
+		traceparent := headerValue(driver.produced[0].message, "traceparent")
+		assert.Equal(t, "00-"+upstreamTraceID+"-"+producer.SpanContext().SpanID().String()+"-01", traceparent)
+	})
+
+	t.Run("DeadLetteredMessagesKeepTheTrace", func(t *testing.T) {
+		recorder := setupTestTracing(t)
+		driver := &fakeDriver{}
+		deadLetter := NewDeadLetterMiddleware[map[string]any](driver, DeadLetterConfig{
+			Topic:       topic + "-dlq",
+			SourceTopic: topic,
+			Processor:   "anime_processor",
+			MaxRetries:  3,
+		})
+
+		chain, err := middleware.Chain[*kafka.Message, map[string]any](
+			NewTracingMiddleware[map[string]any](topic, "anime_processor").Process,
+			traceStage[map[string]any]("dead_letter", deadLetter.Process),
+			func(ctx context.Context, data event.Event[*kafka.Message, map[string]any], _ middleware.Handler[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+				return &data, retryable.NewPermanent(assert.AnError)
+			},
+		)
+		require.NoError(t, err)
+
+		_, err = chain(ctx, newEvent())
+		require.NoError(t, err)
+
+		require.Len(t, driver.produced, 1)
+		consumer := spanByName(t, recorder, topic+" process")
+		traceparent := headerValue(driver.produced[0].message, "traceparent")
+		assert.Equal(t, "00-"+upstreamTraceID+"-"+consumer.SpanContext().SpanID().String()+"-01", traceparent)
+	})
+
+	t.Run("FailedStagesAreMarked", func(t *testing.T) {
+		recorder := setupTestTracing(t)
+
+		chain, err := middleware.Chain[*kafka.Message, map[string]any](
+			NewTracingMiddleware[map[string]any](topic, "anime_processor").Process,
+			func(ctx context.Context, data event.Event[*kafka.Message, map[string]any], _ middleware.Handler[*kafka.Message, map[string]any]) (*event.Event[*kafka.Message, map[string]any], error) {
+				return &data, assert.AnError
+			},
+		)
+		require.NoError(t, err)
+
+		_, err = chain(ctx, newEvent())
+		require.ErrorIs(t, err, assert.AnError)
+
+		consumer := spanByName(t, recorder, topic+" process")
+		assert.Equal(t, "Error", consumer.Status().Code.String())
+	})
+}
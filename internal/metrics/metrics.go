@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "anime_sync"
+
+// actions a message is labelled with, derived from the Debezium op or the before and after images
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionSnapshot = "snapshot"
+	ActionUnknown  = "unknown"
+)
+
+var (
+	messagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_processed_total",
+		Help:      "Messages processed successfully",
+	}, []string{"entity", "action"})
+
+	messagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_failed_total",
+		Help:      "Processing attempts that returned an error",
+	}, []string{"entity", "action"})
+
+	messagesRetried = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_retried_total",
+		Help:      "Failed messages re-queued on the retry topic",
+	}, []string{"entity", "action"})
+
+	messagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_dead_lettered_total",
+		Help:      "Messages published to the dead letter topic",
+	}, []string{"entity", "action", "class"})
+
+	processingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "message_processing_seconds",
+		Help:      "Time spent processing a message, failed attempts included",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"entity", "action"})
+
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_lag",
+		Help:      "Messages between the committed offset and the end of each assigned partition",
+	}, []string{"topic", "partition"})
+
+	assignedPartitions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "assigned_partitions",
+		Help:      "Partitions currently assigned to this instance",
+	})
+)
+
+// ObserveProcessed records the outcome and duration of one processing attempt
+func ObserveProcessed(entity string, action string, duration time.Duration, err error) {
+	processingDuration.WithLabelValues(entity, action).Observe(duration.Seconds())
+	if err != nil {
+		messagesFailed.WithLabelValues(entity, action).Inc()
+		return
+	}
+	messagesProcessed.WithLabelValues(entity, action).Inc()
+}
+
+func Retried(entity string, action string) {
+	messagesRetried.WithLabelValues(entity, action).Inc()
+}
+
+// DeadLettered records a dead lettered message, class is the retryable class of its error
+func DeadLettered(entity string, action string, class string) {
+	messagesDeadLettered.WithLabelValues(entity, action, class).Inc()
+}
+
+// SetConsumerLag replaces the lag of all partitions, partitions no longer assigned are dropped
+func SetConsumerLag(lag map[string]map[string]int64) {
+	consumerLag.Reset()
+	partitions := 0
+	for topic, byPartition := range lag {
+		for partition, value := range byPartition {
+			consumerLag.WithLabelValues(topic, partition).Set(float64(value))
+			partitions++
+		}
+	}
+	assignedPartitions.Set(float64(partitions))
+}
+
+// Action derives the action label from a Debezium message, with or without the schema envelope
+func Action(value []byte) string {
+	type payload struct {
+		Op     string          `json:"op"`
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+	}
+	var envelope struct {
+		payload
+		Payload *payload `json:"payload"`
+	}
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		return ActionUnknown
+	}
+
+	p := envelope.payload
+	if envelope.Payload != nil {
+		p = *envelope.Payload
+	}
+
+	switch p.Op {
+	case "c":
+		return ActionCreate
+	case "u":
+		return ActionUpdate
+	case "d":
+		return ActionDelete
+	case "r":
+		return ActionSnapshot
+	}
+
+	hasBefore := len(p.Before) > 0 && string(p.Before) != "null"
+	hasAfter := len(p.After) > 0 && string(p.After) != "null"
+	switch {
+	case hasBefore && hasAfter:
+		return ActionUpdate
+	case hasAfter:
+		return ActionCreate
+	case hasBefore:
+		return ActionDelete
+	}
+	return ActionUnknown
+}
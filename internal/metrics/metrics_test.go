@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAction(t *testing.T) {
+	tests := map[string]string{
+		`{"schema":{},"payload":{"op":"u","before":{"id":"1"},"after":{"id":"1"}}}`: ActionUpdate,
+		`{"payload":{"op":"r","before":null,"after":{"id":"1"}}}`:                   ActionSnapshot,
+		`{"payload":{"before":null,"after":{"id":"1"}}}`:                            ActionCreate,
+		`{"payload":{"before":{"id":"1"},"after":null}}`:                            ActionDelete,
+		`{"before":{"id":"1"},"after":{"id":"1"}}`:                                  ActionUpdate,
+		`{"payload":{}}`: ActionUnknown,
+		`not json`:       ActionUnknown,
+	}
+
+	for value, want := range tests {
+		assert.Equal(t, want, Action([]byte(value)), value)
+	}
+}
@@ -0,0 +1,127 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// BatchProducer sends several messages to one topic and returns once all of them were delivered
+type BatchProducer func(ctx context.Context, messages []*kafka.Message) error
+
+// Single adapts a batch producer to the single message producers the processors take
+func Single(produce BatchProducer) func(ctx context.Context, message *kafka.Message) error {
+	return func(ctx context.Context, message *kafka.Message) error {
+		return produce(ctx, []*kafka.Message{message})
+	}
+}
+
+// KafkaBatchProducer hands all messages of a batch to the Kafka client before waiting for their
+// deliveries, so a batch costs one round trip instead of one per message
+type KafkaBatchProducer struct {
+	config *kafka.ConfigMap
+
+	mu       sync.Mutex
+	producer *kafka.Producer
+}
+
+func NewKafkaBatchProducer(config *kafka.ConfigMap) *KafkaBatchProducer {
+	return &KafkaBatchProducer{config: config}
+}
+
+// Producer returns the batch producer for topic
+func (p *KafkaBatchProducer) Producer(topic string) BatchProducer {
+	return func(ctx context.Context, messages []*kafka.Message) error {
+		return p.Produce(ctx, topic, messages)
+	}
+}
+
+// Produce sends messages to topic and waits for all of them, the first delivery error is returned
+func (p *KafkaBatchProducer) Produce(ctx context.Context, topic string, messages []*kafka.Message) error {
+	log := logger.FromCtx(ctx)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	producer, err := p.client()
+	if err != nil {
+		return err
+	}
+
+	// deliveries is never closed, the client may still write to it after a failed Produce call
+	deliveries := make(chan kafka.Event, len(messages))
+	spans := make([]trace.Span, len(messages))
+	pending := 0
+	var produceErr error
+	for i, message := range messages {
+		_, spans[i] = tracing.StartProducer(ctx, topic, message)
+		produceErr = producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          message.Value,
+			Headers:        message.Headers,
+			Key:            message.Key,
+			Opaque:         i,
+		}, deliveries)
+		if produceErr != nil {
+			tracing.End(spans[i], produceErr)
+			break
+		}
+		pending++
+	}
+
+	var deliveryErr error
+	for ; pending > 0; pending-- {
+		delivered, ok := (<-deliveries).(*kafka.Message)
+		if !ok {
+			continue
+		}
+		i := delivered.Opaque.(int)
+		tracing.End(spans[i], delivered.TopicPartition.Error)
+		if delivered.TopicPartition.Error != nil && deliveryErr == nil {
+			deliveryErr = delivered.TopicPartition.Error
+		}
+	}
+
+	if produceErr != nil {
+		log.Error("Failed to produce batch", zap.String("topic", topic), zap.Int("messages", len(messages)), zap.Error(produceErr))
+		return produceErr
+	}
+	if deliveryErr != nil {
+		log.Error("Failed to deliver batch", zap.String("topic", topic), zap.Int("messages", len(messages)), zap.Error(deliveryErr))
+		return deliveryErr
+	}
+
+	log.Info("Produced batch to Kafka", zap.String("topic", topic), zap.Int("messages", len(messages)))
+	return nil
+}
+
+func (p *KafkaBatchProducer) client() (*kafka.Producer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.producer == nil {
+		producer, err := kafka.NewProducer(p.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create producer: %w", err)
+		}
+		p.producer = producer
+	}
+	return p.producer, nil
+}
+
+// Close flushes and closes the Kafka client
+func (p *KafkaBatchProducer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.producer != nil {
+		p.producer.Close()
+		p.producer = nil
+	}
+}
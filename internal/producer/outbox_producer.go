@@ -0,0 +1,83 @@
+package producer
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/outbox"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// NewOutboxProducer returns a producer that writes messages for topic to the outbox table instead of kafka.
+// When ctx carries a transaction (see db.TxFromCtx) the row is written in it, so it is only
+// published by the outbox relay once the entity change it belongs to is committed
+func NewOutboxProducer(database *db.DB, topic string) func(ctx context.Context, message *kafka.Message) error {
+	repository := outbox.NewOutboxRepository(database)
+
+	return func(ctx context.Context, message *kafka.Message) (err error) {
+		ctx, span := tracing.StartProducer(ctx, topic, message)
+		defer func() { tracing.End(span, err) }()
+
+		log := logger.FromCtx(ctx)
+
+		err = outboxRepository(ctx, repository, topic).Enqueue(outboxMessage(topic, message))
+		if err != nil {
+			log.Error("Failed to write message to outbox", zap.String("topic", topic), zap.Error(err))
+			return err
+		}
+
+		log.Debug("Message written to outbox", zap.String("topic", topic), zap.String("key", string(message.Key)))
+		return nil
+	}
+}
+
+// NewOutboxBatchProducer is NewOutboxProducer for batches, the messages are written with one insert
+func NewOutboxBatchProducer(database *db.DB, topic string) BatchProducer {
+	repository := outbox.NewOutboxRepository(database)
+
+	return func(ctx context.Context, messages []*kafka.Message) (err error) {
+		log := logger.FromCtx(ctx)
+
+		rows := make([]*outbox.Message, len(messages))
+		for i, message := range messages {
+			_, span := tracing.StartProducer(ctx, topic, message)
+			defer func() { tracing.End(span, err) }()
+			rows[i] = outboxMessage(topic, message)
+		}
+
+		err = outboxRepository(ctx, repository, topic).EnqueueMany(rows)
+		if err != nil {
+			log.Error("Failed to write messages to outbox", zap.String("topic", topic), zap.Int("messages", len(messages)), zap.Error(err))
+			return err
+		}
+
+		log.Debug("Messages written to outbox", zap.String("topic", topic), zap.Int("messages", len(messages)))
+		return nil
+	}
+}
+
+// outboxRepository binds repository to the transaction carried by ctx
+func outboxRepository(ctx context.Context, repository outbox.OutboxRepositoryImpl, topic string) outbox.OutboxRepositoryImpl {
+	if tx := db.TxFromCtx(ctx); tx != nil {
+		return repository.WithTx(tx)
+	}
+	logger.FromCtx(ctx).Warn("Writing to outbox outside of a transaction", zap.String("topic", topic))
+	return repository
+}
+
+func outboxMessage(topic string, message *kafka.Message) *outbox.Message {
+	headers := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+
+	return &outbox.Message{
+		Topic:   topic,
+		Key:     message.Key,
+		Payload: message.Value,
+		Headers: headers,
+	}
+}
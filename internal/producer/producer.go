@@ -2,62 +2,162 @@ package producer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/weeb-vip/anime-sync/config"
 	"github.com/weeb-vip/anime-sync/internal/logger"
 	"go.uber.org/zap"
 )
 
+// ErrClientClosed is returned when sending through a client that was closed
+var ErrClientClosed = errors.New("pulsar client closed")
+
 type Producer[T any] interface {
-	Send(ctx context.Context, data []byte) error
+	Send(ctx context.Context, message *pulsar.ProducerMessage) error
+	// SendAsync queues message and returns, callback is called once the message was delivered or failed
+	SendAsync(ctx context.Context, message *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error))
 }
 
-type ProducerImpl[T any] struct {
-	client pulsar.Client
-	config config.PulsarConfig
-	topic  string
+// PulsarClient keeps one Pulsar producer per topic, created on first use and reused for every
+// message sent to the topic until Close
+type PulsarClient struct {
+	client  pulsar.Client
+	options pulsar.ProducerOptions
+
+	mu        sync.Mutex
+	producers map[string]pulsar.Producer
+	closed    bool
 }
 
-func NewProducer[T any](ctx context.Context, cfg config.PulsarConfig, topic string) Producer[T] {
-	log := logger.FromCtx(ctx)
+func NewPulsarClient(cfg config.PulsarConfig) (*PulsarClient, error) {
+	compression, err := compressionType(cfg.ProducerCompression)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := pulsar.NewClient(pulsar.ClientOptions{
 		URL: cfg.URL,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pulsar client: %w", err)
+	}
+
+	return newPulsarClient(client, pulsar.ProducerOptions{
+		DisableBatching:         cfg.ProducerDisableBatching,
+		BatchingMaxMessages:     cfg.ProducerBatchMaxMessages,
+		BatchingMaxPublishDelay: cfg.ProducerBatchDelay(),
+		CompressionType:         compression,
+		SendTimeout:             cfg.ProducerSendTimeout(),
+	}), nil
+}
+
+func newPulsarClient(client pulsar.Client, options pulsar.ProducerOptions) *PulsarClient {
+	return &PulsarClient{
+		client:    client,
+		options:   options,
+		producers: map[string]pulsar.Producer{},
+	}
+}
+
+func compressionType(name string) (pulsar.CompressionType, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return pulsar.NoCompression, nil
+	case "lz4":
+		return pulsar.LZ4, nil
+	case "zlib":
+		return pulsar.ZLib, nil
+	case "zstd":
+		return pulsar.ZSTD, nil
+	default:
+		return pulsar.NoCompression, fmt.Errorf("unknown pulsar compression %q", name)
+	}
+}
+
+// producer returns the producer of topic, creating it on first use
+func (c *PulsarClient) producer(topic string) (pulsar.Producer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if producer, ok := c.producers[topic]; ok {
+		return producer, nil
+	}
 
+	options := c.options
+	options.Topic = topic
+	producer, err := c.client.CreateProducer(options)
 	if err != nil {
-		log.Fatal("Error creating pulsar client: ", zap.String("error", err.Error()))
-		return nil
+		return nil, fmt.Errorf("failed to create pulsar producer for %s: %w", topic, err)
+	}
+	c.producers[topic] = producer
+	return producer, nil
+}
+
+// Close flushes and closes the producers and then the client, later sends fail with ErrClientClosed
+func (c *PulsarClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
 	}
+	c.closed = true
+
+	log := logger.Get()
+	for topic, producer := range c.producers {
+		if err := producer.Flush(); err != nil {
+			log.Error("Error flushing pulsar producer", zap.String("topic", topic), zap.Error(err))
+		}
+		producer.Close()
+	}
+	c.producers = nil
+	c.client.Close()
+}
+
+type ProducerImpl[T any] struct {
+	client *PulsarClient
+	topic  string
+}
 
+// NewProducer returns a producer for topic that sends through the long lived producer of client
+func NewProducer[T any](client *PulsarClient, topic string) Producer[T] {
 	return &ProducerImpl[T]{
-		config: cfg,
 		client: client,
 		topic:  topic,
 	}
 }
 
-func (p *ProducerImpl[T]) Send(ctx context.Context, data []byte) error {
+func (p *ProducerImpl[T]) Send(ctx context.Context, message *pulsar.ProducerMessage) error {
 	log := logger.FromCtx(ctx)
-	producer, err := p.client.CreateProducer(pulsar.ProducerOptions{
-		Topic: p.topic,
-	})
-
+	producer, err := p.client.producer(p.topic)
 	if err != nil {
-		log.Fatal("Error creating pulsar producer: ", zap.String("error", err.Error()))
+		log.Error("Error creating pulsar producer", zap.String("topic", p.topic), zap.Error(err))
 		return err
 	}
 
-	defer producer.Close()
-
-	msg := pulsar.ProducerMessage{
-		Payload: data,
-	}
-
-	_, err = producer.Send(ctx, &msg)
+	_, err = producer.Send(ctx, message)
 	if err != nil {
-		log.Fatal("Error sending message: ", zap.String("error", err.Error()))
+		log.Error("Error sending message", zap.String("topic", p.topic), zap.String("key", message.Key), zap.Error(err))
 		return err
 	}
 
 	return nil
 }
+
+func (p *ProducerImpl[T]) SendAsync(ctx context.Context, message *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	producer, err := p.client.producer(p.topic)
+	if err != nil {
+		logger.FromCtx(ctx).Error("Error creating pulsar producer", zap.String("topic", p.topic), zap.Error(err))
+		callback(nil, message, err)
+		return
+	}
+
+	producer.SendAsync(ctx, message, callback)
+}
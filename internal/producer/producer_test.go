@@ -0,0 +1,149 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+type fakePulsarProducer struct {
+	pulsar.Producer
+	topic    string
+	sent     []*pulsar.ProducerMessage
+	sendErr  error
+	flushed  bool
+	isClosed bool
+}
+
+func (p *fakePulsarProducer) Send(ctx context.Context, message *pulsar.ProducerMessage) (pulsar.MessageID, error) {
+	if p.sendErr != nil {
+		return nil, p.sendErr
+	}
+	p.sent = append(p.sent, message)
+	return nil, nil
+}
+
+func (p *fakePulsarProducer) SendAsync(ctx context.Context, message *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	id, err := p.Send(ctx, message)
+	callback(id, message, err)
+}
+
+func (p *fakePulsarProducer) Flush() error {
+	p.flushed = true
+	return nil
+}
+
+func (p *fakePulsarProducer) Close() {
+	p.isClosed = true
+}
+
+type fakePulsarClient struct {
+	pulsar.Client
+	options   []pulsar.ProducerOptions
+	producers []*fakePulsarProducer
+	createErr error
+	isClosed  bool
+}
+
+func (c *fakePulsarClient) CreateProducer(options pulsar.ProducerOptions) (pulsar.Producer, error) {
+	if c.createErr != nil {
+		return nil, c.createErr
+	}
+	c.options = append(c.options, options)
+	producer := &fakePulsarProducer{topic: options.Topic}
+	c.producers = append(c.producers, producer)
+	return producer, nil
+}
+
+func (c *fakePulsarClient) Close() {
+	c.isClosed = true
+}
+
+func TestPulsarProducer(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	options := pulsar.ProducerOptions{BatchingMaxMessages: 100, CompressionType: pulsar.LZ4}
+
+	t.Run("ReusesOneProducerPerTopic", func(t *testing.T) {
+		client := &fakePulsarClient{}
+		pulsarClient := newPulsarClient(client, options)
+		algolia := NewProducer[string](pulsarClient, "algolia")
+		images := NewProducer[string](pulsarClient, "images")
+
+		require.NoError(t, algolia.Send(ctx, &pulsar.ProducerMessage{Key: "a1"}))
+		require.NoError(t, algolia.Send(ctx, &pulsar.ProducerMessage{Key: "a2"}))
+		require.NoError(t, NewProducer[string](pulsarClient, "algolia").Send(ctx, &pulsar.ProducerMessage{Key: "a3"}))
+		require.NoError(t, images.Send(ctx, &pulsar.ProducerMessage{Key: "a1"}))
+
+		require.Len(t, client.producers, 2)
+		assert.Len(t, client.producers[0].sent, 3)
+		assert.Len(t, client.producers[1].sent, 1)
+		assert.Equal(t, "algolia", client.options[0].Topic)
+		assert.Equal(t, uint(100), client.options[0].BatchingMaxMessages)
+		assert.Equal(t, pulsar.LZ4, client.options[0].CompressionType)
+	})
+
+	t.Run("SendAsyncCallsBack", func(t *testing.T) {
+		client := &fakePulsarClient{}
+		producer := NewProducer[string](newPulsarClient(client, options), "algolia")
+
+		var delivered []string
+		producer.SendAsync(ctx, &pulsar.ProducerMessage{Key: "a1"}, func(id pulsar.MessageID, message *pulsar.ProducerMessage, err error) {
+			require.NoError(t, err)
+			delivered = append(delivered, message.Key)
+		})
+		assert.Equal(t, []string{"a1"}, delivered)
+	})
+
+	t.Run("ReturnsErrors", func(t *testing.T) {
+		client := &fakePulsarClient{createErr: errors.New("broker unavailable")}
+		producer := NewProducer[string](newPulsarClient(client, options), "algolia")
+
+		require.Error(t, producer.Send(ctx, &pulsar.ProducerMessage{}))
+
+		var asyncErr error
+		producer.SendAsync(ctx, &pulsar.ProducerMessage{}, func(id pulsar.MessageID, message *pulsar.ProducerMessage, err error) {
+			asyncErr = err
+		})
+		require.Error(t, asyncErr)
+
+		// a failed producer is created again on the next send
+		client.createErr = nil
+		require.NoError(t, producer.Send(ctx, &pulsar.ProducerMessage{}))
+
+		client.producers[0].sendErr = errors.New("timeout")
+		require.Error(t, producer.Send(ctx, &pulsar.ProducerMessage{}))
+	})
+
+	t.Run("CloseFlushesAndClosesProducers", func(t *testing.T) {
+		client := &fakePulsarClient{}
+		pulsarClient := newPulsarClient(client, options)
+		producer := NewProducer[string](pulsarClient, "algolia")
+		require.NoError(t, producer.Send(ctx, &pulsar.ProducerMessage{}))
+
+		pulsarClient.Close()
+		pulsarClient.Close()
+
+		assert.True(t, client.producers[0].flushed)
+		assert.True(t, client.producers[0].isClosed)
+		assert.True(t, client.isClosed)
+		assert.ErrorIs(t, producer.Send(ctx, &pulsar.ProducerMessage{}), ErrClientClosed)
+	})
+}
+
+func TestCompressionType(t *testing.T) {
+	for name, expected := range map[string]pulsar.CompressionType{"": pulsar.NoCompression, "none": pulsar.NoCompression, "LZ4": pulsar.LZ4, "zlib": pulsar.ZLib, "zstd": pulsar.ZSTD} {
+		compression, err := compressionType(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, compression, name)
+	}
+
+	_, err := compressionType("snappy")
+	require.Error(t, err)
+}
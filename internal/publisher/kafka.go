@@ -0,0 +1,74 @@
+package publisher
+
+import (
+	"context"
+	"sort"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/producer"
+	"go.uber.org/zap"
+)
+
+// Kafka publishes messages as JSON through a Kafka producer of a topic, like the driver or outbox
+// producers. The key and headers of a message become the Kafka key and headers
+type Kafka[T any] struct {
+	produce func(ctx context.Context, message *kafka.Message) error
+	batch   producer.BatchProducer
+}
+
+// NewKafka returns a publisher that sends messages one by one through produce
+func NewKafka[T any](produce func(ctx context.Context, message *kafka.Message) error) *Kafka[T] {
+	return &Kafka[T]{produce: produce}
+}
+
+// NewKafkaBatch returns a publisher that sends the messages of a call as one batch
+func NewKafkaBatch[T any](batch producer.BatchProducer) *Kafka[T] {
+	return &Kafka[T]{batch: batch}
+}
+
+func (p *Kafka[T]) Publish(ctx context.Context, messages ...Message[T]) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	kafkaMessages := make([]*kafka.Message, len(messages))
+	for i, message := range messages {
+		kafkaMessage, err := kafkaMessageOf(message)
+		if err != nil {
+			logger.FromCtx(ctx).Error("Error marshalling message", zap.String("key", message.Key), zap.Error(err))
+			return err
+		}
+		kafkaMessages[i] = kafkaMessage
+	}
+
+	if p.batch != nil {
+		return p.batch(ctx, kafkaMessages)
+	}
+	for _, kafkaMessage := range kafkaMessages {
+		if err := p.produce(ctx, kafkaMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func kafkaMessageOf[T any](message Message[T]) (*kafka.Message, error) {
+	value, err := encode(message)
+	if err != nil {
+		return nil, err
+	}
+
+	kafkaMessage := &kafka.Message{Value: value}
+	if message.Key != "" {
+		kafkaMessage.Key = []byte(message.Key)
+	}
+	for key, value := range message.Headers() {
+		kafkaMessage.Headers = append(kafkaMessage.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	// map order is random, sorted headers keep messages comparable
+	sort.Slice(kafkaMessage.Headers, func(i, j int) bool {
+		return kafkaMessage.Headers[i].Key < kafkaMessage.Headers[j].Key
+	})
+	return kafkaMessage, nil
+}
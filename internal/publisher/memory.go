@@ -0,0 +1,53 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps published messages in memory, for tests
+type Memory[T any] struct {
+	mu       sync.Mutex
+	messages []Message[T]
+	calls    int
+	// Err is returned by Publish, the messages are not kept then
+	Err error
+}
+
+func (p *Memory[T]) Publish(ctx context.Context, messages ...Message[T]) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	if p.Err != nil {
+		return p.Err
+	}
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+// Messages returns the published messages in order
+func (p *Memory[T]) Messages() []Message[T] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message[T]{}, p.messages...)
+}
+
+// Values returns the values of the published messages in order
+func (p *Memory[T]) Values() []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	values := make([]T, len(p.messages))
+	for i, message := range p.messages {
+		values[i] = message.Value
+	}
+	return values
+}
+
+// Calls counts the Publish calls, the messages of a batch are published in one call
+func (p *Memory[T]) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
@@ -0,0 +1,50 @@
+// Package publisher publishes typed messages to Kafka, Pulsar, memory or a writer, so processors
+// don't depend on the transport their documents are sent over
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Headers set on published messages, consumers route on them without decoding the value
+const (
+	HeaderAction        = "action"
+	HeaderEntity        = "entity"
+	HeaderSchemaVersion = "schema-version"
+)
+
+// Message is a value to publish. Key is the id of the entity the value belongs to, messages are
+// partitioned by it so the messages of one entity stay in order. Empty fields are left out
+type Message[T any] struct {
+	Key           string
+	Action        string
+	Entity        string
+	SchemaVersion string
+	Value         T
+}
+
+// Headers returns the action, entity and schema version headers that are set
+func (m Message[T]) Headers() map[string]string {
+	headers := map[string]string{}
+	for key, value := range map[string]string{
+		HeaderAction:        m.Action,
+		HeaderEntity:        m.Entity,
+		HeaderSchemaVersion: m.SchemaVersion,
+	} {
+		if value != "" {
+			headers[key] = value
+		}
+	}
+	return headers
+}
+
+// Publisher publishes messages carrying T. The messages of a call may be sent as one batch
+type Publisher[T any] interface {
+	Publish(ctx context.Context, messages ...Message[T]) error
+}
+
+// encode returns the JSON value of message
+func encode[T any](message Message[T]) ([]byte, error) {
+	return json.Marshal(message.Value)
+}
@@ -0,0 +1,172 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+type document struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+func testMessages() []Message[document] {
+	return []Message[document]{
+		{Key: "a1", Action: "create", Entity: "anime", SchemaVersion: "1", Value: document{ID: "a1", Title: "Cowboy Bebop"}},
+		{Key: "a2", Action: "update", Value: document{ID: "a2", Title: "Mushi Shi"}},
+	}
+}
+
+func TestMessageHeaders(t *testing.T) {
+	messages := testMessages()
+
+	assert.Equal(t, map[string]string{HeaderAction: "create", HeaderEntity: "anime", HeaderSchemaVersion: "1"}, messages[0].Headers())
+	assert.Equal(t, map[string]string{HeaderAction: "update"}, messages[1].Headers())
+	assert.Empty(t, Message[document]{}.Headers())
+}
+
+func TestKafka(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	t.Run("OneByOne", func(t *testing.T) {
+		var produced []*kafka.Message
+		publisher := NewKafka[document](func(ctx context.Context, message *kafka.Message) error {
+			produced = append(produced, message)
+			return nil
+		})
+		require.NoError(t, publisher.Publish(ctx, testMessages()...))
+
+		require.Len(t, produced, 2)
+		assert.Equal(t, []byte("a1"), produced[0].Key)
+		assert.Equal(t, []kafka.Header{
+			{Key: HeaderAction, Value: []byte("create")},
+			{Key: HeaderEntity, Value: []byte("anime")},
+			{Key: HeaderSchemaVersion, Value: []byte("1")},
+		}, produced[0].Headers)
+
+		var value document
+		require.NoError(t, json.Unmarshal(produced[1].Value, &value))
+		assert.Equal(t, document{ID: "a2", Title: "Mushi Shi"}, value)
+	})
+
+	t.Run("StopsAtTheFirstError", func(t *testing.T) {
+		calls := 0
+		publisher := NewKafka[document](func(ctx context.Context, message *kafka.Message) error {
+			calls++
+			return errors.New("broker down")
+		})
+		require.Error(t, publisher.Publish(ctx, testMessages()...))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		var batches [][]*kafka.Message
+		publisher := NewKafkaBatch[document](func(ctx context.Context, messages []*kafka.Message) error {
+			batches = append(batches, messages)
+			return nil
+		})
+		require.NoError(t, publisher.Publish(ctx, testMessages()...))
+		require.NoError(t, publisher.Publish(ctx))
+
+		require.Len(t, batches, 1)
+		require.Len(t, batches[0], 2)
+		assert.Equal(t, []byte("a2"), batches[0][1].Key)
+	})
+}
+
+type fakePulsarProducer struct {
+	messages []*pulsar.ProducerMessage
+}
+
+func (p *fakePulsarProducer) Send(ctx context.Context, message *pulsar.ProducerMessage) error {
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func (p *fakePulsarProducer) SendAsync(ctx context.Context, message *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	callback(nil, message, p.Send(ctx, message))
+}
+
+func TestPulsar(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	producer := &fakePulsarProducer{}
+
+	require.NoError(t, NewPulsar[document](producer).Publish(ctx, testMessages()...))
+
+	require.Len(t, producer.messages, 2)
+	assert.Equal(t, "a1", producer.messages[0].Key)
+	assert.Equal(t, map[string]string{HeaderAction: "create", HeaderEntity: "anime", HeaderSchemaVersion: "1"}, producer.messages[0].Properties)
+
+	var value document
+	require.NoError(t, json.Unmarshal(producer.messages[0].Payload, &value))
+	assert.Equal(t, document{ID: "a1", Title: "Cowboy Bebop"}, value)
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	publisher := &Memory[document]{}
+
+	require.NoError(t, publisher.Publish(ctx, testMessages()...))
+	require.NoError(t, publisher.Publish(ctx, testMessages()[0]))
+
+	assert.Equal(t, 2, publisher.Calls())
+	assert.Len(t, publisher.Messages(), 3)
+	assert.Equal(t, "Cowboy Bebop", publisher.Values()[2].Title)
+
+	publisher.Err = errors.New("unavailable")
+	require.Error(t, publisher.Publish(ctx, testMessages()...))
+	assert.Equal(t, 3, publisher.Calls())
+	assert.Len(t, publisher.Messages(), 3)
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("JSONLines", func(t *testing.T) {
+		var out strings.Builder
+		require.NoError(t, NewWriter[document](&out).Publish(ctx, testMessages()...))
+
+		assert.Equal(t,
+			`{"key":"a1","headers":{"action":"create","entity":"anime","schema-version":"1"},"value":{"id":"a1","title":"Cowboy Bebop"}}`+"\n"+
+				`{"key":"a2","headers":{"action":"update"},"value":{"id":"a2","title":"Mushi Shi"}}`+"\n",
+			out.String())
+	})
+
+	t.Run("AppendsToFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "messages.jsonl")
+		for _, message := range testMessages() {
+			writer, err := NewFile[document](path)
+			require.NoError(t, err)
+			require.NoError(t, writer.Publish(ctx, message))
+			require.NoError(t, writer.Close())
+		}
+
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+
+		var keys []string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var written line[document]
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &written))
+			keys = append(keys, written.Key)
+		}
+		require.NoError(t, scanner.Err())
+		assert.Equal(t, []string{"a1", "a2"}, keys)
+	})
+}
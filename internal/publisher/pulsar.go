@@ -0,0 +1,40 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/producer"
+	"go.uber.org/zap"
+)
+
+// Pulsar publishes messages as JSON through a Pulsar producer of a topic. The key of a message becomes
+// the Pulsar key and its headers the message properties
+type Pulsar[T any] struct {
+	producer producer.Producer[T]
+}
+
+func NewPulsar[T any](producer producer.Producer[T]) *Pulsar[T] {
+	return &Pulsar[T]{producer: producer}
+}
+
+func (p *Pulsar[T]) Publish(ctx context.Context, messages ...Message[T]) error {
+	for _, message := range messages {
+		value, err := encode(message)
+		if err != nil {
+			logger.FromCtx(ctx).Error("Error marshalling message", zap.String("key", message.Key), zap.Error(err))
+			return err
+		}
+
+		err = p.producer.Send(ctx, &pulsar.ProducerMessage{
+			Payload:    value,
+			Key:        message.Key,
+			Properties: message.Headers(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
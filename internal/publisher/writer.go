@@ -0,0 +1,60 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Writer writes messages as JSON lines with their key and headers, e.g. to stdout for dry runs or
+// to a file to replay later
+type Writer[T any] struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// line is a message written by Writer
+type line[T any] struct {
+	Key     string            `json:"key,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Value   T                 `json:"value"`
+}
+
+func NewWriter[T any](w io.Writer) *Writer[T] {
+	return &Writer[T]{encoder: json.NewEncoder(w)}
+}
+
+// NewFile returns a writer that appends to the file at path, creating it when needed. Close closes the file
+func NewFile[T any](path string) (*Writer[T], error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	writer := NewWriter[T](file)
+	writer.closer = file
+	return writer, nil
+}
+
+func (p *Writer[T]) Publish(ctx context.Context, messages ...Message[T]) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, message := range messages {
+		err := p.encoder.Encode(line[T]{Key: message.Key, Headers: message.Headers(), Value: message.Value})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the file of a writer returned by NewFile, other writers are left open
+func (p *Writer[T]) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
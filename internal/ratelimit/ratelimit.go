@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limiter spaces calls to Wait evenly so at most ratePerSecond of them return per second.
+// It is not safe for concurrent use
+type Limiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// New returns a limiter for ratePerSecond calls, 0 or less does not limit
+func New(ratePerSecond float64) *Limiter {
+	if ratePerSecond <= 0 {
+		return &Limiter{}
+	}
+	return &Limiter{interval: time.Duration(float64(time.Second) / ratePerSecond)}
+}
+
+// Wait blocks until the next call is allowed or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.interval == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if l.next.After(now) {
+		timer := time.NewTimer(l.next.Sub(now))
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		now = l.next
+	}
+	l.next = now.Add(l.interval)
+	return nil
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SpacesCalls", func(t *testing.T) {
+		limiter := New(100)
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			require.NoError(t, limiter.Wait(ctx))
+		}
+		// the first call returns right away, the other four wait 10ms each
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		limiter := New(0)
+		start := time.Now()
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, limiter.Wait(ctx))
+		}
+		assert.Less(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("StopsWhenCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		limiter := New(0.001)
+		require.NoError(t, limiter.Wait(ctx))
+
+		cancel()
+		require.ErrorIs(t, limiter.Wait(ctx), context.Canceled)
+	})
+}
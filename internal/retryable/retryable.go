@@ -0,0 +1,137 @@
+package retryable
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers the classifier knows about
+const (
+	mysqlErrLockWaitTimeout  = 1205
+	mysqlErrDeadlock         = 1213
+	mysqlErrDataTooLong      = 1406
+	mysqlErrTruncatedValue   = 1292
+	mysqlErrIncorrectValue   = 1366
+	mysqlErrOutOfRangeValue  = 1264
+	mysqlErrNoReferencedRow  = 1452
+	mysqlErrServerGoneAway   = 2006
+	mysqlErrServerLostDuring = 2013
+)
+
+// Class tells the retry middlewares whether retrying an error can succeed
+type Class string
+
+const (
+	// Retriable errors are transient, e.g. a deadlock or a lost connection
+	Retriable Class = "retriable"
+	// Permanent errors fail the same way every time, e.g. a payload that does not parse
+	Permanent Class = "permanent"
+)
+
+// PermanentError marks an error that must not be retried
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// NewPermanent wraps err so it is classified as permanent, nil stays nil
+func NewPermanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Classify reports whether err is worth retrying. Errors it does not recognise are retriable,
+// so an unknown failure is never dropped without going through the retry topic first
+func Classify(err error) Class {
+	if isPermanent(err) {
+		return Permanent
+	}
+	return Retriable
+}
+
+// IsPermanent reports whether err can't succeed on a retry
+func IsPermanent(err error) bool {
+	return err != nil && Classify(err) == Permanent
+}
+
+func isPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// transient failures win over parse errors they may have been wrapped together with
+	if isTransient(err) {
+		return false
+	}
+
+	var permanentErr *PermanentError
+	if errors.As(err, &permanentErr) {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	var unmarshalTypeErr *json.UnmarshalTypeError
+	var timeParseErr *time.ParseError
+	var numErr *strconv.NumError
+	var base64Err base64.CorruptInputError
+	if errors.As(err, &syntaxErr) || errors.As(err, &unmarshalTypeErr) || errors.As(err, &timeParseErr) ||
+		errors.As(err, &numErr) || errors.As(err, &base64Err) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlErrDataTooLong, mysqlErrTruncatedValue, mysqlErrIncorrectValue, mysqlErrOutOfRangeValue:
+			return true
+		}
+	}
+
+	// kafka rejects the message itself, producing it again fails the same way
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		switch kafkaErr.Code() {
+		case kafka.ErrMsgSizeTooLarge, kafka.ErrInvalidMsg, kafka.ErrInvalidMsgSize, kafka.ErrRecordListTooLarge,
+			kafka.ErrInvalidRecord, kafka.ErrTopicException:
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		// a missing foreign key row usually shows up a little later, see the character staff link processor
+		case mysqlErrLockWaitTimeout, mysqlErrDeadlock, mysqlErrNoReferencedRow, mysqlErrServerGoneAway, mysqlErrServerLostDuring:
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,52 @@
+package retryable
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	_, timeErr := time.Parse(time.RFC3339, "not a date")
+	jsonErr := json.Unmarshal([]byte("{"), &struct{}{})
+
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"Deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, Retriable},
+		{"LockWaitTimeout", fmt.Errorf("upsert: %w", &mysql.MySQLError{Number: 1205}), Retriable},
+		{"BadConnection", driver.ErrBadConn, Retriable},
+		{"InvalidConnection", mysql.ErrInvalidConn, Retriable},
+		{"Timeout", context.DeadlineExceeded, Retriable},
+		{"MissingReference", &mysql.MySQLError{Number: 1452}, Retriable},
+		{"Unknown", errors.New("algolia producer unavailable"), Retriable},
+		{"TimeParse", fmt.Errorf("start date: %w", timeErr), Permanent},
+		{"JSONSyntax", jsonErr, Permanent},
+		{"DataTooLong", &mysql.MySQLError{Number: 1406}, Permanent},
+		{"MessageTooLarge", fmt.Errorf("produce: %w", kafka.NewError(kafka.ErrMsgSizeTooLarge, "Broker: Message size too large", false)), Permanent},
+		{"BrokerDown", kafka.NewError(kafka.ErrAllBrokersDown, "Local: All broker connections are down", false), Retriable},
+		{"DeliveryTimeout", kafka.NewError(kafka.ErrMsgTimedOut, "Local: Message timed out", false), Retriable},
+		{"Marked", NewPermanent(errors.New("invalid season")), Permanent},
+		{"MarkedButDisconnected", NewPermanent(fmt.Errorf("lost: %w", driver.ErrBadConn)), Retriable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+			assert.Equal(t, tt.want == Permanent, IsPermanent(tt.err))
+		})
+	}
+
+	assert.False(t, IsPermanent(nil))
+	assert.Nil(t, NewPermanent(nil))
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"go.uber.org/zap"
+)
+
+// checkTimeout bounds every readiness check so a hanging dependency can't hang the probe
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable, the server is not ready while any check fails
+type Check func(ctx context.Context) error
+
+// Server serves /healthz for liveness, /readyz for readiness and /metrics in the Prometheus format
+type Server struct {
+	port   int
+	checks map[string]Check
+}
+
+func NewServer(port int, checks map[string]Check) *Server {
+	return &Server{
+		port:   port,
+		checks: checks,
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
+// Run serves until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	log := logger.FromCtx(ctx)
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		// requests carry the logger of ctx but are not cancelled with it
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithoutCancel(ctx)
+		},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Info("Starting health server", zap.Int("port", s.port))
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("ok"))
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	results := make(map[string]string, len(s.checks))
+	ready := true
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		name, check := name, check
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			defer cancel()
+
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result != "ok" {
+				ready = false
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+		names := make([]string, 0, len(results))
+		for name, result := range results {
+			if result != "ok" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		logger.FromCtx(r.Context()).Warn("Not ready", zap.Strings("failing", names))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ready":  ready,
+		"checks": results,
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	brokerErr := errors.New("no brokers reachable")
+	checks := map[string]Check{
+		"database": func(ctx context.Context) error { return nil },
+		"kafka":    func(ctx context.Context) error { return brokerErr },
+	}
+	handler := NewServer(0, checks).Handler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	t.Run("Healthz", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/healthz").Code)
+	})
+
+	t.Run("ReadyzReportsFailingChecks", func(t *testing.T) {
+		recorder := get("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+		var body struct {
+			Ready  bool              `json:"ready"`
+			Checks map[string]string `json:"checks"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		assert.False(t, body.Ready)
+		assert.Equal(t, map[string]string{"database": "ok", "kafka": brokerErr.Error()}, body.Checks)
+	})
+
+	t.Run("ReadyzOnceChecksPass", func(t *testing.T) {
+		checks["kafka"] = func(ctx context.Context) error { return nil }
+		assert.Equal(t, http.StatusOK, get("/readyz").Code)
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		recorder := get("/metrics")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "go_goroutines")
+	})
+}
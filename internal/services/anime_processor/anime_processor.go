@@ -2,7 +2,8 @@ package anime_processor
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -11,373 +12,261 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/weeb-vip/anime-sync/internal/db"
 	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
-	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_tag"
-	"github.com/weeb-vip/anime-sync/internal/db/repositories/tag"
-	"github.com/weeb-vip/anime-sync/internal/logger"
-	"go.uber.org/zap"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"github.com/weeb-vip/anime-sync/internal/slug"
 )
 
+// seasonPattern matches the SEASON_YEAR format used by the season column, e.g. SPRING_2024
+var seasonPattern = regexp.MustCompile(`^(WINTER|SPRING|SUMMER|FALL)_[0-9]{4}$`)
+
+// maxTransactionRetries is how often the anime and tag transaction is retried in process,
+// e.g. after a deadlock, before the error is handed to the retry middleware
+const maxTransactionRetries = 3
+
+// positionEntity is the entity name anime rows are recorded under in sync_positions
+const positionEntity = "anime"
+
+// diffIgnoredFields are left out when comparing the before and after of an update, the timestamps
+// change on every write, tags and old titles are not part of change events
+var diffIgnoredFields = []string{"created_at", "updated_at", "tags", "old_titles"}
+
+// unstoredFields are schema fields without an anime column
+var unstoredFields = []string{"season"}
+
+// searchFields are the fields that change search results, updates to other fields are not published to algolia
+var searchFields = []string{
+	"title_en", "title_jp", "title_romaji", "title_kanji", "title_synonyms", "type", "image_url", "synopsis",
+	"episodes", "status", "duration", "broadcast", "source", "rating", "start_date", "end_date", "genres",
+	"licensors", "studios", "ranking", "season",
+}
+
+// imageFields are the fields the image request is built from, the image name is derived from the titles
+var imageFields = []string{"image_url", "title_en", "title_jp"}
+
 type Options struct {
 	NoErrorOnDelete bool
+	// ForceReplay applies events even when a newer one was already applied to the row, e.g. during backfills
+	ForceReplay bool
 }
 
 type AnimeProcessor interface {
 	Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error)
 }
 
+// AnimeProcessorImpl adapts anime change events consumed from Kafka to the sync service
 type AnimeProcessorImpl struct {
-	Repository         anime.AnimeRepositoryImpl
-	TagRepository      tag.TagRepositoryImpl
-	AnimeTagRepository anime_tag.AnimeTagRepositoryImpl
-	Options            Options
-	AlgoliaProducer    func(ctx context.Context, message *kafka.Message) error
-	Producer           func(ctx context.Context, message *kafka.Message) error
+	*AnimeSyncService
 }
 
-func NewAnimeProcessor(opt Options, db *db.DB, algoliaProducer func(ctx context.Context, message *kafka.Message) error, producer func(ctx context.Context, message *kafka.Message) error) AnimeProcessor {
+func NewAnimeProcessor(opt Options, db *db.DB, search publisher.Publisher[ProducerPayload], images publisher.Publisher[ImagePayload]) AnimeProcessor {
 	return &AnimeProcessorImpl{
-		Repository:         anime.NewAnimeRepository(db),
-		TagRepository:      tag.NewTagRepository(db),
-		AnimeTagRepository: anime_tag.NewAnimeTagRepository(db),
-		Options:            opt,
-		AlgoliaProducer:    algoliaProducer,
-		Producer:           producer,
+		AnimeSyncService: NewAnimeSyncService(opt, db, search, images),
 	}
 }
 
 func (p *AnimeProcessorImpl) Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error) {
-	log := logger.FromCtx(ctx)
-
-	payload := data.Payload
-
-	return p.processPayload(ctx, data, payload, log)
+	return data, p.Sync(ctx, data.Payload)
 }
 
-// processPayload handles the main processing logic
-func (p *AnimeProcessorImpl) processPayload(ctx context.Context, data event.Event[*kafka.Message, Payload], payload Payload, log *zap.Logger) (event.Event[*kafka.Message, Payload], error) {
-	// log the payload
-	log.Debug("Payload", zap.Any("payload", payload))
-
-	if payload.Before == nil && payload.After != nil {
-		// add to db
-		// Log the incoming payload for debugging
-		if payload.After.TheTVDBID != nil {
-			log.Info("Create operation with TheTVDBID", zap.String("id", payload.After.ID), zap.String("thetvdbid", *payload.After.TheTVDBID))
-		} else {
-			log.Info("Create operation without TheTVDBID", zap.String("id", payload.After.ID))
-		}
-
-		newAnime, err := p.ParseToEntity(ctx, *payload.After)
-		if err != nil {
-			return data, err
-		}
-
-		// Log the anime entity before saving
-		if newAnime.TheTVDBID != nil {
-			log.Info("Creating anime with TheTVDBID", zap.String("id", newAnime.ID), zap.String("thetvdbid", *newAnime.TheTVDBID))
-		} else {
-			log.Info("Creating anime without TheTVDBID", zap.String("id", newAnime.ID))
-		}
-
-		err = p.Repository.Upsert(newAnime, nil)
-		if err != nil {
-			return data, err
-		}
-
-		// Handle tag associations
-		err = p.syncTags(ctx, newAnime.ID, payload.After.Genres)
-		if err != nil {
-			log.Warn("Failed to sync tags", zap.Error(err))
-		}
-
-		jsonAnime, err := json.Marshal(ProducerPayload{
-			Action: CreateAction,
-			Data:   payload.After,
-		})
-		if err != nil {
-			log.Error("Error marshalling payload", zap.Error(err))
-			return data, err
-		}
-		var title string
-		if payload.After.TitleEn != nil {
-			title = strings.ToLower(*payload.After.TitleEn)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else if payload.After.TitleJp != nil {
-			title = strings.ToLower(*payload.After.TitleJp)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else {
-			return data, nil
-		}
-		imageURL := ""
-		if payload.After.ImageUrl != nil {
-			imageURL = *payload.After.ImageUrl
-		}
-		imagePayload := &ImagePayload{
-			Data: ImageSchema{
-				Name: title,
-				URL:  imageURL,
-				Type: DataTypeAnime,
-			},
-		}
-
-		jsonImage, err := json.Marshal(imagePayload)
-		if err != nil {
-			log.Error("Error marshalling image payload", zap.Error(err))
-			return data, err
-		}
-
-		err = p.AlgoliaProducer(ctx, &kafka.Message{
-			Value: jsonAnime,
-		})
-		if err != nil {
-			log.Error("Error sending message to algolia producer", zap.Error(err))
-			return data, err
-		}
-
-		if payload.After.ImageUrl != nil {
-			log.Info("Sending update to producer", zap.String("title", title), zap.String("imageURL", imageURL))
-			log.Info("Sending image to Kafka", zap.String("imageURL", *payload.After.ImageUrl))
-			err = p.Producer(ctx, &kafka.Message{
-				Value: jsonImage,
-			})
-
-			if err != nil {
-				log.Error("Error sending message to Kafka producer", zap.Error(err))
-				return data, err
-			}
-
-		} else {
-			log.Warn("ImageURL is nil, skipping image producer")
-		}
+// SearchMessage returns the message publishing action for the search document of an anime
+func SearchMessage(action Action, document *Schema) publisher.Message[ProducerPayload] {
+	return publisher.Message[ProducerPayload]{
+		Key:           document.ID,
+		Action:        action,
+		Entity:        EntityAnime,
+		SchemaVersion: SchemaVersion,
+		Value:         ProducerPayload{Action: action, Data: document},
 	}
+}
 
-	if payload.After == nil && payload.Before != nil {
-		// delete from db
-		oldAnime, err := p.ParseToEntity(ctx, *payload.Before)
-		if err != nil {
-			return data, err
-		}
-
-		err = p.Repository.Delete(oldAnime)
-		if err != nil {
-			if p.Options.NoErrorOnDelete {
-				log.Warn("WARN: error deleting from db: ", zap.Error(err))
-				return data, nil
-			} else {
-				return data, err
-			}
-		}
-		return data, nil
-
+// ImageMessage returns the message of the image sync request of the anime, character or staff member
+// with id, sent because of action
+func ImageMessage(action Action, id string, image *ImagePayload) publisher.Message[ImagePayload] {
+	return publisher.Message[ImagePayload]{
+		Key:           id,
+		Action:        action,
+		Entity:        strings.ToLower(image.Data.Type),
+		SchemaVersion: ImageSchemaVersion,
+		Value:         *image,
 	}
+}
 
-	if payload.Before != nil && payload.After != nil {
-		// update db
-		// Log the incoming payload for debugging
-		if payload.After.TheTVDBID != nil {
-			log.Info("Update operation with TheTVDBID", zap.String("id", payload.After.ID), zap.String("thetvdbid", *payload.After.TheTVDBID))
-		} else {
-			log.Info("Update operation without TheTVDBID", zap.String("id", payload.After.ID))
-		}
-
-		newAnime, err := p.ParseToEntity(ctx, *payload.After)
-		if err != nil {
-			return data, err
-		}
-		var oldTitle *string
-		if payload.Before.TitleEn != nil && *payload.Before.TitleEn != *payload.After.TitleEn {
-			oldTitle = payload.Before.TitleEn
-		}
-		if payload.Before.TitleEn == nil && payload.Before.TitleJp != nil && *payload.Before.TitleJp != *payload.After.TitleJp {
-			oldTitle = payload.Before.TitleJp
-		}
-
-		// Log the anime entity before saving
-		if newAnime.TheTVDBID != nil {
-			log.Info("Saving anime with TheTVDBID", zap.String("id", newAnime.ID), zap.String("thetvdbid", *newAnime.TheTVDBID))
-		} else {
-			log.Info("Saving anime without TheTVDBID", zap.String("id", newAnime.ID))
-		}
-
-		err = p.Repository.Upsert(newAnime, oldTitle)
-		if err != nil {
-			return data, err
-		}
-
-		// Handle tag associations
-		err = p.syncTags(ctx, newAnime.ID, payload.After.Genres)
-		if err != nil {
-			log.Warn("Failed to sync tags", zap.Error(err))
-		}
+// searchDocument returns the schema published to algolia with the normalized season and the titles
+// the anime had before
+func searchDocument(data *Schema, entity *anime.Anime, oldTitles []string) *Schema {
+	document := *data
+	document.Season = entity.Season
+	document.OldTitles = PreviousTitles(&document, oldTitles)
+	return &document
+}
 
-		// convert new anime to json
-		jsonAnime, err := json.Marshal(ProducerPayload{
-			Action: CreateAction,
-			Data:   payload.After,
-		})
-		if err != nil {
-			return data, err
-		}
-		var title string
-		if payload.After.TitleEn != nil {
-			title = strings.ToLower(*payload.After.TitleEn)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else if payload.After.TitleJp != nil {
-			title = strings.ToLower(*payload.After.TitleJp)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else {
-			return data, nil
-		}
-		imageURL := ""
-		if payload.After.ImageUrl != nil {
-			imageURL = *payload.After.ImageUrl
-		}
-		imagePayload := &ImagePayload{
-			Data: ImageSchema{
-				Name: title,
-				URL:  imageURL,
-				Type: DataTypeAnime,
-			},
-		}
+// TitleChanges returns a history entry for every title field that differs between before and after.
+// Titles are compared by value, a title that was added or removed is a change as well
+func TitleChanges(before *Schema, after *Schema, changedAt time.Time) []anime_title_history.AnimeTitleHistory {
+	fields := []struct {
+		name   string
+		before *string
+		after  *string
+	}{
+		{"title_en", before.TitleEn, after.TitleEn},
+		{"title_jp", before.TitleJp, after.TitleJp},
+		{"title_romaji", before.TitleRomaji, after.TitleRomaji},
+		{"title_kanji", before.TitleKanji, after.TitleKanji},
+	}
 
-		jsonImage, err := json.Marshal(imagePayload)
-		if err != nil {
-			return data, err
-		}
-		err = p.AlgoliaProducer(ctx, &kafka.Message{
-			Value: jsonAnime,
+	var titleChanges []anime_title_history.AnimeTitleHistory
+	for _, field := range fields {
+		if equalTitle(field.before, field.after) {
+			continue
+		}
+		titleChanges = append(titleChanges, anime_title_history.AnimeTitleHistory{
+			AnimeID:   after.ID,
+			Field:     field.name,
+			OldTitle:  field.before,
+			NewTitle:  field.after,
+			ChangedAt: changedAt,
 		})
-		if err != nil {
-			return data, err
-		}
-		if payload.After.ImageUrl != nil {
-			log.Info("Sending update to producer", zap.String("title", title), zap.String("imageURL", imageURL))
+	}
+	return titleChanges
+}
 
-			log.Info("Sending image to Kafka producer", zap.String("title", title), zap.String("imageURL", imageURL))
-			err = p.Producer(ctx, &kafka.Message{
-				Value: jsonImage,
-			})
+func equalTitle(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
 
-			if err != nil {
-				return data, err
+// PreviousTitles leaves the titles the anime currently has out of oldTitles, e.g. after it was renamed back
+func PreviousTitles(data *Schema, oldTitles []string) []string {
+	var previous []string
+	for _, title := range oldTitles {
+		current := false
+		for _, t := range []*string{data.TitleEn, data.TitleJp, data.TitleRomaji, data.TitleKanji} {
+			if t != nil && *t == title {
+				current = true
+				break
 			}
-		} else {
-			log.Warn("ImageURL is nil, skipping image producer")
+		}
+		if !current {
+			previous = append(previous, title)
 		}
 	}
+	return previous
+}
 
-	if payload.Before != nil && payload.After == nil {
-		log.Warn("WARN: payload.After is nil, skipping update")
+// eventTime is when the change happened in the source database, or now for events without a source timestamp
+func eventTime(source Source) time.Time {
+	if source.TsMs > 0 {
+		return time.UnixMilli(source.TsMs)
 	}
-
-	return data, nil
+	return time.Now()
 }
 
-func (p *AnimeProcessorImpl) ParseToEntity(ctx context.Context, data Schema) (*anime.Anime, error) {
-	log := logger.FromCtx(ctx)
-	var newAnime anime.Anime
-
-	var animeStartDate *string
-	if data.StartDate != nil {
-		startDate, err := time.Parse(time.RFC3339, *data.StartDate)
-		if err != nil {
-			return nil, err
-		}
-		animeStartDateFormatted := startDate.Format("2006-01-02 15:04:05")
-		animeStartDate = &animeStartDateFormatted
-
+// SchemaFromEntity rebuilds the search document of a stored anime the way its change event carries it.
+// Dates go back to RFC3339 and timestamps to microseconds as Debezium encodes them
+func SchemaFromEntity(entity *anime.Anime) *Schema {
+	schema := &Schema{
+		ID:            entity.ID,
+		AnidbID:       entity.AnidbID,
+		TheTVDBID:     entity.TheTVDBID,
+		TitleEn:       entity.TitleEn,
+		TitleJp:       entity.TitleJp,
+		TitleRomaji:   entity.TitleRomaji,
+		TitleKanji:    entity.TitleKanji,
+		ImageUrl:      entity.ImageURL,
+		Synopsis:      entity.Synopsis,
+		Episodes:      entity.Episodes,
+		Status:        entity.Status,
+		Duration:      entity.Duration,
+		Broadcast:     entity.Broadcast,
+		Source:        entity.Source,
+		StartDate:     storedDate(entity.StartDate),
+		EndDate:       storedDate(entity.EndDate),
+		TitleSynonyms: entity.TitleSynonyms,
+		Genres:        entity.Genres,
+		Licensors:     entity.Licensors,
+		Studios:       entity.Studios,
+		Ranking:       entity.Ranking,
+		Season:        entity.Season,
 	}
 
-	var animeEndDate *string
-	if data.EndDate != nil {
-		endDate, err := time.Parse(time.RFC3339, *data.EndDate)
-		if err != nil {
-			return nil, err
-		}
-		animeEndDateFormatted := endDate.Format("2006-01-02 15:04:05")
-		animeEndDate = &animeEndDateFormatted
+	if entity.Type != nil {
+		recordType := string(*entity.Type)
+		schema.Type = &recordType
 	}
-	var record_type *anime.RECORD_TYPE
-	if data.Type != nil {
-		record := anime.RECORD_TYPE(*data.Type)
-		record_type = &record
-
+	if entity.Rating != nil {
+		rating := strconv.FormatFloat(*entity.Rating, 'f', -1, 64)
+		schema.Rating = &rating
+	}
+	if !entity.CreatedAt.IsZero() {
+		createdAt := entity.CreatedAt.UnixMicro()
+		schema.CreatedAt = &createdAt
+	}
+	if !entity.UpdatedAt.IsZero() {
+		updatedAt := entity.UpdatedAt.UnixMicro()
+		schema.UpdatedAt = &updatedAt
 	}
 
-	newAnime.ID = data.ID
-	newAnime.Ranking = data.Ranking
-	newAnime.AnidbID = data.AnidbID
-	newAnime.TheTVDBID = data.TheTVDBID
-	newAnime.Type = record_type
-	newAnime.TitleEn = data.TitleEn
-	newAnime.TitleJp = data.TitleJp
-	newAnime.TitleRomaji = data.TitleRomaji
-	newAnime.TitleKanji = data.TitleKanji
-	newAnime.TitleSynonyms = data.TitleSynonyms
-	newAnime.ImageURL = data.ImageUrl
-	newAnime.Synopsis = data.Synopsis
-	newAnime.Episodes = data.Episodes
-	newAnime.Status = data.Status
-	newAnime.StartDate = animeStartDate
-	newAnime.EndDate = animeEndDate
-	newAnime.Genres = data.Genres
-	newAnime.Duration = data.Duration
-	newAnime.Broadcast = data.Broadcast
-	newAnime.Source = data.Source
-	newAnime.Licensors = data.Licensors
-	newAnime.Studios = data.Studios
+	return schema
+}
 
-	// Convert rating from string to float64
-	var animeRating *float64
-	if data.Rating != nil {
-		if ratingFloat, err := strconv.ParseFloat(*data.Rating, 64); err == nil {
-			animeRating = &ratingFloat
-		} else {
-			log.Warn("Failed to parse rating as float", zap.String("rating", *data.Rating), zap.Error(err))
+// storedDate formats a date read back from the anime table as RFC3339, values it can't parse are passed on as is
+func storedDate(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, *value); err == nil {
+			formatted := parsed.Format(time.RFC3339)
+			return &formatted
 		}
 	}
-	newAnime.Rating = animeRating
-
-	newAnime.CreatedAt = time.Now()
-	newAnime.UpdatedAt = time.Now()
+	return value
+}
 
-	// Debug logging for TheTVDBID
-	if data.TheTVDBID != nil {
-		log.Info("TheTVDBID received in parseToEntity", zap.String("id", data.ID), zap.String("thetvdbid", *data.TheTVDBID))
-	} else {
-		log.Info("TheTVDBID is nil for anime", zap.String("id", data.ID))
+// ParseSeason validates a SEASON_YEAR value and returns it upper cased
+func ParseSeason(value string) (string, error) {
+	season := strings.ToUpper(strings.TrimSpace(value))
+	if !seasonPattern.MatchString(season) {
+		return "", retryable.NewPermanent(fmt.Errorf("invalid season %q, expected SEASON_YEAR like SPRING_2024", value))
 	}
-
-	return &newAnime, nil
+	return season, nil
 }
 
-func (p *AnimeProcessorImpl) syncTags(ctx context.Context, animeID string, genres *string) error {
-	log := logger.FromCtx(ctx)
-
-	if genres == nil || *genres == "" {
-		// No genres, clear all tags
-		return p.AnimeTagRepository.SetTagsForAnime(animeID, []int64{})
+// NewAnimeImagePayload builds the image sync request of an anime, named after its English title or its
+// Japanese title when the English one has no usable slug. False without an image url
+func NewAnimeImagePayload(id string, titleEn *string, titleJp *string, imageURL *string) (*ImagePayload, bool) {
+	var titles []string
+	for _, title := range []*string{titleEn, titleJp} {
+		if title != nil {
+			titles = append(titles, *title)
+		}
 	}
+	return NewImagePayload(DataTypeAnime, id, imageURL, titles...)
+}
 
-	// Parse genres JSON array into tag names
-	var genreList []string
-	if err := json.Unmarshal([]byte(*genres), &genreList); err != nil {
-		log.Warn("Failed to parse genres as JSON array", zap.String("genres", *genres), zap.Error(err))
-		return p.AnimeTagRepository.SetTagsForAnime(animeID, []int64{})
+// NewImagePayload builds the image sync request of an anime, character or staff member. The image is
+// named by slug.ImageName, the legacy name is derived from the first non empty name. False without an image url
+func NewImagePayload(dataType DataType, id string, imageURL *string, names ...string) (*ImagePayload, bool) {
+	if imageURL == nil {
+		return nil, false
 	}
-
-	var tagIDs []int64
-	for _, genreName := range genreList {
-		trimmedName := strings.TrimSpace(genreName)
-		if trimmedName != "" {
-			t, err := p.TagRepository.FindOrCreate(trimmedName)
-			if err != nil {
-				log.Warn("Failed to find or create tag", zap.String("tag", trimmedName), zap.Error(err))
-				continue
-			}
-			tagIDs = append(tagIDs, t.ID)
+	var legacyName string
+	for _, name := range names {
+		if name != "" {
+			legacyName = slug.LegacyImageName(name)
+			break
 		}
 	}
-
-	return p.AnimeTagRepository.SetTagsForAnime(animeID, tagIDs)
+	return &ImagePayload{
+		Data: ImageSchema{
+			Name:       slug.ImageName(id, names...),
+			URL:        *imageURL,
+			Type:       dataType,
+			LegacyName: legacyName,
+		},
+	}, true
 }
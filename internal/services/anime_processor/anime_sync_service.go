@@ -0,0 +1,432 @@
+package anime_processor
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/weeb-vip/anime-sync/internal/changes"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_tag"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/tag"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"go.uber.org/zap"
+)
+
+// AnimeSyncService applies anime change events to the database and publishes the search documents
+// and images derived from them. It does not know which transport the events came from, the Kafka and
+// Pulsar processors adapt their messages to it
+type AnimeSyncService struct {
+	Transactor         db.Transactor
+	Repository         anime.AnimeRepositoryImpl
+	TagRepository      tag.TagRepositoryImpl
+	AnimeTagRepository anime_tag.AnimeTagRepositoryImpl
+	PositionRepository sync_position.SyncPositionRepositoryImpl
+	// TitleHistoryRepository records title changes, the old titles are published with the search document
+	TitleHistoryRepository anime_title_history.AnimeTitleHistoryRepositoryImpl
+	Options                Options
+	Search                 publisher.Publisher[ProducerPayload]
+	Images                 publisher.Publisher[ImagePayload]
+}
+
+func NewAnimeSyncService(opt Options, db *db.DB, search publisher.Publisher[ProducerPayload], images publisher.Publisher[ImagePayload]) *AnimeSyncService {
+	return &AnimeSyncService{
+		Transactor:             db,
+		Repository:             anime.NewAnimeRepository(db),
+		TagRepository:          tag.NewTagRepository(db),
+		AnimeTagRepository:     anime_tag.NewAnimeTagRepository(db),
+		PositionRepository:     sync_position.NewSyncPositionRepository(db),
+		TitleHistoryRepository: anime_title_history.NewAnimeTitleHistoryRepository(db),
+		Options:                opt,
+		Search:                 search,
+		Images:                 images,
+	}
+}
+
+// Sync applies a create, update or delete event of an anime
+func (s *AnimeSyncService) Sync(ctx context.Context, payload Payload) error {
+	log := logger.FromCtx(ctx)
+
+	// log the payload
+	log.Debug("Payload", zap.Any("payload", payload))
+
+	if payload.Before == nil && payload.After != nil {
+		// add to db
+		// Log the incoming payload for debugging
+		if payload.After.TheTVDBID != nil {
+			log.Info("Create operation with TheTVDBID", zap.String("id", payload.After.ID), zap.String("thetvdbid", *payload.After.TheTVDBID))
+		} else {
+			log.Info("Create operation without TheTVDBID", zap.String("id", payload.After.ID))
+		}
+
+		newAnime, err := s.ParseToEntity(ctx, *payload.After)
+		if err != nil {
+			return err
+		}
+
+		// Log the anime entity before saving
+		if newAnime.TheTVDBID != nil {
+			log.Info("Creating anime with TheTVDBID", zap.String("id", newAnime.ID), zap.String("thetvdbid", *newAnime.TheTVDBID))
+		} else {
+			log.Info("Creating anime without TheTVDBID", zap.String("id", newAnime.ID))
+		}
+
+		return s.saveAnime(ctx, newAnime, nil, payload.After.Genres, payload.Source, nil, func(ctx context.Context, tx *db.DB) error {
+			return s.publish(ctx, tx, CreateAction, payload.After, newAnime, nil)
+		})
+	}
+
+	if payload.After == nil && payload.Before != nil {
+		// delete from db
+		oldAnime, err := s.ParseToEntity(ctx, *payload.Before)
+		if err != nil {
+			return err
+		}
+
+		var deleteErr error
+		err = s.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			stale, err := s.isStale(ctx, tx, oldAnime.ID, payload.Source)
+			if err != nil || stale {
+				return err
+			}
+
+			// the recorded position is kept so older events can't bring the row back
+			deleteErr = s.Repository.WithTx(tx).Delete(oldAnime)
+			if deleteErr != nil {
+				return deleteErr
+			}
+
+			return s.publishSearch(ctx, SearchMessage(DeleteAction, payload.Before))
+		})
+		if err != nil {
+			// only db errors are ignored, a failed algolia delete has to be retried
+			if deleteErr != nil && s.Options.NoErrorOnDelete {
+				log.Warn("WARN: error deleting from db: ", zap.Error(err))
+				return nil
+			}
+			return err
+		}
+		return nil
+	}
+
+	if payload.Before != nil && payload.After != nil {
+		// update db
+		// Log the incoming payload for debugging
+		if payload.After.TheTVDBID != nil {
+			log.Info("Update operation with TheTVDBID", zap.String("id", payload.After.ID), zap.String("thetvdbid", *payload.After.TheTVDBID))
+		} else {
+			log.Info("Update operation without TheTVDBID", zap.String("id", payload.After.ID))
+		}
+
+		newAnime, err := s.ParseToEntity(ctx, *payload.After)
+		if err != nil {
+			return err
+		}
+		titleChanges := TitleChanges(payload.Before, payload.After, eventTime(payload.Source))
+
+		// Log the anime entity before saving
+		if newAnime.TheTVDBID != nil {
+			log.Info("Saving anime with TheTVDBID", zap.String("id", newAnime.ID), zap.String("thetvdbid", *newAnime.TheTVDBID))
+		} else {
+			log.Info("Saving anime without TheTVDBID", zap.String("id", newAnime.ID))
+		}
+
+		changed := changes.Diff(payload.Before, payload.After, diffIgnoredFields...)
+		if changed.Empty() {
+			log.Info("Update changed no fields, skipping writes and publishing", zap.String("id", newAnime.ID))
+		}
+
+		return s.saveAnime(ctx, newAnime, titleChanges, payload.After.Genres, payload.Source, changed, func(ctx context.Context, tx *db.DB) error {
+			return s.publish(ctx, tx, UpdateAction, payload.After, newAnime, changed)
+		})
+	}
+
+	log.Warn("WARN: payload has neither before nor after, skipping")
+	return nil
+}
+
+func (s *AnimeSyncService) ParseToEntity(ctx context.Context, data Schema) (*anime.Anime, error) {
+	log := logger.FromCtx(ctx)
+	var newAnime anime.Anime
+
+	var animeStartDate *string
+	if data.StartDate != nil {
+		startDate, err := time.Parse(time.RFC3339, *data.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		animeStartDateFormatted := startDate.Format("2006-01-02 15:04:05")
+		animeStartDate = &animeStartDateFormatted
+
+	}
+
+	var animeEndDate *string
+	if data.EndDate != nil {
+		endDate, err := time.Parse(time.RFC3339, *data.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		animeEndDateFormatted := endDate.Format("2006-01-02 15:04:05")
+		animeEndDate = &animeEndDateFormatted
+	}
+	var animeSeason *string
+	if data.Season != nil && *data.Season != "" {
+		season, err := ParseSeason(*data.Season)
+		if err != nil {
+			return nil, err
+		}
+		animeSeason = &season
+	}
+
+	var record_type *anime.RECORD_TYPE
+	if data.Type != nil {
+		record := anime.RECORD_TYPE(*data.Type)
+		record_type = &record
+
+	}
+
+	newAnime.ID = data.ID
+	newAnime.Ranking = data.Ranking
+	newAnime.AnidbID = data.AnidbID
+	newAnime.TheTVDBID = data.TheTVDBID
+	newAnime.Type = record_type
+	newAnime.TitleEn = data.TitleEn
+	newAnime.TitleJp = data.TitleJp
+	newAnime.TitleRomaji = data.TitleRomaji
+	newAnime.TitleKanji = data.TitleKanji
+	newAnime.TitleSynonyms = data.TitleSynonyms
+	newAnime.ImageURL = data.ImageUrl
+	newAnime.Synopsis = data.Synopsis
+	newAnime.Episodes = data.Episodes
+	newAnime.Status = data.Status
+	newAnime.StartDate = animeStartDate
+	newAnime.EndDate = animeEndDate
+	newAnime.Genres = data.Genres
+	newAnime.Duration = data.Duration
+	newAnime.Broadcast = data.Broadcast
+	newAnime.Source = data.Source
+	newAnime.Licensors = data.Licensors
+	newAnime.Studios = data.Studios
+	newAnime.Season = animeSeason
+
+	// Convert rating from string to float64
+	var animeRating *float64
+	if data.Rating != nil {
+		if ratingFloat, err := strconv.ParseFloat(*data.Rating, 64); err == nil {
+			animeRating = &ratingFloat
+		} else {
+			log.Warn("Failed to parse rating as float", zap.String("rating", *data.Rating), zap.Error(err))
+		}
+	}
+	newAnime.Rating = animeRating
+
+	newAnime.CreatedAt = time.Now()
+	newAnime.UpdatedAt = time.Now()
+
+	// Debug logging for TheTVDBID
+	if data.TheTVDBID != nil {
+		log.Info("TheTVDBID received in parseToEntity", zap.String("id", data.ID), zap.String("thetvdbid", *data.TheTVDBID))
+	} else {
+		log.Info("TheTVDBID is nil for anime", zap.String("id", data.ID))
+	}
+
+	return &newAnime, nil
+}
+
+// publish sends the search document and the image of the anime. It runs inside the save
+// transaction so outbox producers write their rows together with the anime. For updates changed
+// holds the changed fields, the search document and the image are only sent when fields they use
+// changed. It is nil for creates, which send both
+func (s *AnimeSyncService) publish(ctx context.Context, tx *db.DB, action Action, data *Schema, newAnime *anime.Anime, changed changes.Set) error {
+	log := logger.FromCtx(ctx)
+
+	if changed == nil || changed.Has(searchFields...) {
+		oldTitles, err := s.TitleHistoryRepository.WithTx(tx).GetOldTitlesForAnimes([]string{newAnime.ID})
+		if err != nil {
+			return err
+		}
+
+		err = s.publishSearch(ctx, SearchMessage(action, searchDocument(data, newAnime, oldTitles[newAnime.ID])))
+		if err != nil {
+			return err
+		}
+	}
+
+	if changed != nil && !changed.Has(imageFields...) {
+		return nil
+	}
+
+	image, ok := animeImage(ctx, data)
+	if !ok {
+		return nil
+	}
+
+	err := s.Images.Publish(ctx, ImageMessage(action, data.ID, image))
+	if err != nil {
+		log.Error("Error publishing image", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// publishSearch publishes the search document message to the search index
+func (s *AnimeSyncService) publishSearch(ctx context.Context, message publisher.Message[ProducerPayload]) error {
+	err := s.Search.Publish(ctx, message)
+	if err != nil {
+		logger.FromCtx(ctx).Error("Error publishing search document", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// animeImage returns the image sync request of the anime, false when it has no image
+func animeImage(ctx context.Context, data *Schema) (*ImagePayload, bool) {
+	log := logger.FromCtx(ctx)
+
+	image, ok := NewAnimeImagePayload(data.ID, data.TitleEn, data.TitleJp, data.ImageUrl)
+	if !ok {
+		log.Warn("ImageURL is nil, skipping image producer")
+		return nil, false
+	}
+
+	log.Info("Sending image to image sync", zap.String("title", image.Data.Name), zap.String("imageURL", image.Data.URL))
+	return image, true
+}
+
+// isStale records the source position of the event for the row and reports whether it has to be
+// skipped because a newer event was already applied
+func (s *AnimeSyncService) isStale(ctx context.Context, tx *db.DB, id string, source Source) (bool, error) {
+	position := &sync_position.SyncPosition{
+		Entity:   positionEntity,
+		EntityID: id,
+		Lsn:      int64(source.Lsn),
+		TsMs:     source.TsMs,
+		TxID:     int64(source.TxId),
+	}
+	stale, err := s.PositionRepository.WithTx(tx).Check(position, s.Options.ForceReplay)
+	if err != nil || !stale {
+		return false, err
+	}
+
+	logger.FromCtx(ctx).Warn("Skipping stale anime event, a newer one was already applied", zap.String("id", id), zap.Int64("lsn", position.Lsn), zap.Int64("tsMs", position.TsMs))
+	return true, nil
+}
+
+// saveAnime upserts the anime, replaces its tags and runs publish in a single transaction,
+// retrying the whole unit so the row, its tags and its outbox messages are always committed together.
+// Nothing is written or published when the event is stale. For updates only the changed columns are
+// written and tags are only replaced when genres changed, changed is nil for creates. Title changes
+// are recorded in the title history before publish runs, so the search document carries the old titles
+func (s *AnimeSyncService) saveAnime(ctx context.Context, newAnime *anime.Anime, titleChanges []anime_title_history.AnimeTitleHistory, genres *string, source Source, changed changes.Set, publish func(ctx context.Context, tx *db.DB) error) error {
+	log := logger.FromCtx(ctx)
+
+	operation := func() error {
+		err := s.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			stale, err := s.isStale(ctx, tx, newAnime.ID, source)
+			if err != nil || stale {
+				return err
+			}
+
+			repository := s.Repository.WithTx(tx)
+			if changed == nil {
+				err = repository.Upsert(newAnime)
+			} else if columns := changed.Columns(unstoredFields...); len(columns) > 0 {
+				err = repository.UpdateColumns(newAnime, columns)
+			}
+			if err != nil {
+				return err
+			}
+
+			err = s.TitleHistoryRepository.WithTx(tx).Create(titleChanges)
+			if err != nil {
+				return err
+			}
+
+			// Handle tag associations
+			if changed == nil || changed.Has("genres") {
+				err = s.syncTags(ctx, tx, newAnime.ID, genres)
+				if err != nil {
+					return err
+				}
+			}
+
+			return publish(ctx, tx)
+		})
+		if retryable.IsPermanent(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	notify := func(err error, wait time.Duration) {
+		log.Warn("Failed to save anime and tags, retrying", zap.String("id", newAnime.ID), zap.Duration("wait", wait), zap.Error(err))
+	}
+
+	retry := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxTransactionRetries), ctx)
+	return backoff.RetryNotify(operation, retry, notify)
+}
+
+func (s *AnimeSyncService) syncTags(ctx context.Context, tx *db.DB, animeID string, genres *string) error {
+	tagIDs, err := resolveTags(ctx, s.TagRepository.WithTx(tx), genreNames(ctx, genres), map[string]int64{})
+	if err != nil {
+		return err
+	}
+
+	return s.AnimeTagRepository.WithTx(tx).SetTagsForAnime(animeID, tagIDs)
+}
+
+// genreNames parses the genres JSON array into tag names. Anime without genres or with genres
+// that can't be parsed have no tags
+func genreNames(ctx context.Context, genres *string) []string {
+	if genres == nil || *genres == "" {
+		return nil
+	}
+
+	var genreList []string
+	if err := json.Unmarshal([]byte(*genres), &genreList); err != nil {
+		logger.FromCtx(ctx).Warn("Failed to parse genres as JSON array", zap.String("genres", *genres), zap.Error(err))
+		return nil
+	}
+
+	var names []string
+	for _, genreName := range genreList {
+		if trimmedName := strings.TrimSpace(genreName); trimmedName != "" {
+			names = append(names, trimmedName)
+		}
+	}
+	return names
+}
+
+// resolveTags finds or creates the tags named names and returns their distinct ids. known caches
+// the ids by name, so a batch looks every tag up once
+func resolveTags(ctx context.Context, tagRepository tag.TagRepositoryImpl, names []string, known map[string]int64) ([]int64, error) {
+	tagIDs := []int64{}
+	seen := map[int64]bool{}
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			t, err := tagRepository.FindOrCreate(name)
+			if err != nil {
+				logger.FromCtx(ctx).Warn("Failed to find or create tag", zap.String("tag", name), zap.Error(err))
+				return nil, err
+			}
+			id = t.ID
+			known[name] = id
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		tagIDs = append(tagIDs, id)
+	}
+	return tagIDs, nil
+}
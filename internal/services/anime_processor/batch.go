@@ -0,0 +1,192 @@
+package anime_processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"go.uber.org/zap"
+)
+
+// AnimeBatchProcessor applies snapshot events in batches besides processing single events
+type AnimeBatchProcessor interface {
+	AnimeProcessor
+	ProcessBatch(ctx context.Context, payloads []Payload) error
+}
+
+// NewAnimeBatchProcessor returns a processor that also applies batches, the publishers should send
+// the messages of a call as one batch, see publisher.NewKafkaBatch
+func NewAnimeBatchProcessor(opt Options, db *db.DB, search publisher.Publisher[ProducerPayload], images publisher.Publisher[ImagePayload]) AnimeBatchProcessor {
+	return &AnimeProcessorImpl{
+		AnimeSyncService: NewAnimeSyncService(opt, db, search, images),
+	}
+}
+
+// ProcessBatch applies the create events of a snapshot at once, see AnimeSyncService.SyncBatch
+func (p *AnimeProcessorImpl) ProcessBatch(ctx context.Context, payloads []Payload) error {
+	return p.SyncBatch(ctx, payloads)
+}
+
+// batchEvent is a create event of a batch with its parsed anime
+type batchEvent struct {
+	data   *Schema
+	entity *anime.Anime
+	source Source
+}
+
+// SyncBatch applies the create events of a snapshot at once. The anime are written with multi row
+// upserts, their tags and source positions in bulk and their search documents and images are published
+// as batches, all in one transaction that is retried as a whole. Several events of the same anime are
+// folded into the last one. Updates and deletes are rejected, they have to go through Sync
+func (s *AnimeSyncService) SyncBatch(ctx context.Context, payloads []Payload) error {
+	log := logger.FromCtx(ctx)
+
+	var events []batchEvent
+	index := map[string]int{}
+	for _, payload := range payloads {
+		if payload.Before != nil || payload.After == nil {
+			return fmt.Errorf("anime batches only take create events")
+		}
+
+		entity, err := s.ParseToEntity(ctx, *payload.After)
+		if err != nil {
+			return err
+		}
+
+		event := batchEvent{data: payload.After, entity: entity, source: payload.Source}
+		if i, ok := index[entity.ID]; ok {
+			events[i] = event
+			continue
+		}
+		index[entity.ID] = len(events)
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	log.Info("Applying anime batch", zap.Int("events", len(payloads)), zap.Int("anime", len(events)))
+
+	operation := func() error {
+		err := s.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			fresh, err := s.freshEvents(ctx, tx, events)
+			if err != nil || len(fresh) == 0 {
+				return err
+			}
+
+			animes := make([]*anime.Anime, len(fresh))
+			for i, event := range fresh {
+				animes[i] = event.entity
+			}
+			err = s.Repository.WithTx(tx).UpsertMany(animes)
+			if err != nil {
+				return err
+			}
+
+			err = s.syncBatchTags(ctx, tx, fresh)
+			if err != nil {
+				return err
+			}
+
+			return s.publishBatch(ctx, tx, fresh)
+		})
+		if retryable.IsPermanent(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	notify := func(err error, wait time.Duration) {
+		log.Warn("Failed to save anime batch, retrying", zap.Int("anime", len(events)), zap.Duration("wait", wait), zap.Error(err))
+	}
+
+	retry := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxTransactionRetries), ctx)
+	return backoff.RetryNotify(operation, retry, notify)
+}
+
+// freshEvents records the source positions of the events and leaves out the stale ones, unless
+// ForceReplay is set
+func (s *AnimeSyncService) freshEvents(ctx context.Context, tx *db.DB, events []batchEvent) ([]batchEvent, error) {
+	log := logger.FromCtx(ctx)
+
+	positions := make([]*sync_position.SyncPosition, len(events))
+	for i, event := range events {
+		positions[i] = &sync_position.SyncPosition{
+			Entity:   positionEntity,
+			EntityID: event.entity.ID,
+			Lsn:      int64(event.source.Lsn),
+			TsMs:     event.source.TsMs,
+			TxID:     int64(event.source.TxId),
+		}
+	}
+
+	stale, err := s.PositionRepository.WithTx(tx).AdvanceMany(positions)
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := make([]batchEvent, 0, len(events))
+	for i, event := range events {
+		if stale[i] && !s.Options.ForceReplay {
+			log.Warn("Skipping stale anime event, a newer one was already applied", zap.String("id", event.entity.ID), zap.Int64("lsn", positions[i].Lsn), zap.Int64("tsMs", positions[i].TsMs))
+			continue
+		}
+		fresh = append(fresh, event)
+	}
+	return fresh, nil
+}
+
+// syncBatchTags replaces the tags of all anime of the batch, every tag is looked up once
+func (s *AnimeSyncService) syncBatchTags(ctx context.Context, tx *db.DB, events []batchEvent) error {
+	tagRepository := s.TagRepository.WithTx(tx)
+	known := map[string]int64{}
+
+	tagIDs := make(map[string][]int64, len(events))
+	for _, event := range events {
+		ids, err := resolveTags(ctx, tagRepository, genreNames(ctx, event.data.Genres), known)
+		if err != nil {
+			return err
+		}
+		tagIDs[event.entity.ID] = ids
+	}
+
+	return s.AnimeTagRepository.WithTx(tx).SetTagsForAnimes(tagIDs)
+}
+
+// publishBatch publishes the search documents and the images of the batch, each as one batch
+func (s *AnimeSyncService) publishBatch(ctx context.Context, tx *db.DB, events []batchEvent) error {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.entity.ID
+	}
+	oldTitles, err := s.TitleHistoryRepository.WithTx(tx).GetOldTitlesForAnimes(ids)
+	if err != nil {
+		return err
+	}
+
+	documents := make([]publisher.Message[ProducerPayload], len(events))
+	var images []publisher.Message[ImagePayload]
+	for i, event := range events {
+		documents[i] = SearchMessage(CreateAction, searchDocument(event.data, event.entity, oldTitles[event.entity.ID]))
+
+		if image, ok := animeImage(ctx, event.data); ok {
+			images = append(images, ImageMessage(CreateAction, event.entity.ID, image))
+		}
+	}
+
+	err = s.Search.Publish(ctx, documents...)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	return s.Images.Publish(ctx, images...)
+}
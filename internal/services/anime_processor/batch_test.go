@@ -0,0 +1,72 @@
+package anime_processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor/synctest"
+)
+
+func TestProcessBatch(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	newProcessor := func(fakes *synctest.Fakes) *anime_processor.AnimeProcessorImpl {
+		return &anime_processor.AnimeProcessorImpl{AnimeSyncService: fakes.Service(anime_processor.Options{})}
+	}
+	create := func(id string, title string, genres string, lsn int) anime_processor.Payload {
+		return anime_processor.Payload{After: &anime_processor.Schema{ID: id, TitleEn: &title, Genres: &genres}, Source: anime_processor.Source{Lsn: lsn, TsMs: int64(lsn)}}
+	}
+
+	t.Run("AppliesSnapshotInOneUpsert", func(t *testing.T) {
+		fakes := synctest.NewFakes()
+		err := newProcessor(fakes).ProcessBatch(ctx, []anime_processor.Payload{
+			create("batch-1", "First", `["Drama","Action"]`, 100),
+			create("batch-2", "Second", `["Drama"]`, 101),
+			create("batch-1", "First Renamed", `["Action"]`, 102),
+		})
+		require.NoError(t, err)
+
+		store := fakes.Store
+		assert.Equal(t, 1, fakes.Anime.Upserts)
+		assert.Equal(t, 1, store.Transactions)
+		require.Contains(t, store.Anime, "batch-1")
+		assert.Equal(t, "First Renamed", *store.Anime["batch-1"].TitleEn, "the last event of an anime should win")
+		assert.Len(t, store.AnimeTags["batch-1"], 1)
+		assert.Len(t, store.AnimeTags["batch-2"], 1)
+		assert.Equal(t, store.AnimeTags["batch-1"], []int64{store.Tags["Action"]})
+		assert.Equal(t, 1, fakes.Search.Calls(), "documents should be published as one batch")
+		assert.Len(t, fakes.Search.Messages(), 2)
+	})
+
+	t.Run("SkipsStaleEvents", func(t *testing.T) {
+		fakes := synctest.NewFakes()
+		fakes.Positions.Positions["anime/batch-1"] = sync_position.SyncPosition{Entity: "anime", EntityID: "batch-1", Lsn: 500, TsMs: 500}
+
+		err := newProcessor(fakes).ProcessBatch(ctx, []anime_processor.Payload{
+			create("batch-1", "Stale", `[]`, 100),
+			create("batch-2", "Fresh", `[]`, 101),
+		})
+		require.NoError(t, err)
+
+		assert.NotContains(t, fakes.Store.Anime, "batch-1")
+		assert.Contains(t, fakes.Store.Anime, "batch-2")
+		assert.Equal(t, 1, fakes.Search.Calls())
+		assert.Len(t, fakes.Search.Messages(), 1)
+	})
+
+	t.Run("RejectsUpdates", func(t *testing.T) {
+		fakes := synctest.NewFakes()
+		update := create("batch-1", "Updated", `[]`, 100)
+		update.Before = update.After
+
+		require.Error(t, newProcessor(fakes).ProcessBatch(ctx, []anime_processor.Payload{update}))
+		assert.Zero(t, fakes.Store.Transactions)
+	})
+}
@@ -0,0 +1,27 @@
+package anime_processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weeb-vip/anime-sync/internal/slug"
+)
+
+func TestNewAnimeImagePayload(t *testing.T) {
+	titleEn := "Fate/Zero"
+	titleJp := "フェイト/ゼロ"
+	imageURL := "https://example.com/fate.jpg"
+
+	image, ok := NewAnimeImagePayload("fate-zero", &titleEn, &titleJp, &imageURL)
+	require.True(t, ok)
+	assert.Equal(t, ImageSchema{Name: "fate_zero-" + slug.Suffix("fate-zero"), URL: imageURL, Type: DataTypeAnime, LegacyName: "fate/zero"}, image.Data)
+
+	image, ok = NewAnimeImagePayload("fate-zero", nil, &titleJp, &imageURL)
+	require.True(t, ok)
+	assert.Equal(t, "feito_zero-"+slug.Suffix("fate-zero"), image.Data.Name)
+
+	_, ok = NewAnimeImagePayload("fate-zero", &titleEn, &titleJp, nil)
+	assert.False(t, ok)
+}
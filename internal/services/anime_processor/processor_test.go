@@ -0,0 +1,22 @@
+package anime_processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor/synctest"
+)
+
+func TestKafkaAdapter(t *testing.T) {
+	synctest.Run(t, func(service *anime_processor.AnimeSyncService) synctest.Adapter {
+		processor := &anime_processor.AnimeProcessorImpl{AnimeSyncService: service}
+		return func(ctx context.Context, payload anime_processor.Payload) error {
+			_, err := processor.Process(ctx, event.Event[*kafka.Message, anime_processor.Payload]{Payload: payload})
+			return err
+		}
+	})
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/weeb-vip/anime-sync/internal/db"
 	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
 	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
 	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
 )
 
@@ -68,7 +69,7 @@ func TestRealProcessorWorkflowWithMocks(t *testing.T) {
 
 	// Create real processor
 	options := anime_processor.Options{NoErrorOnDelete: false}
-	processor := anime_processor.NewAnimeProcessor(options, database, algoliaProducer, kafkaProducer)
+	processor := anime_processor.NewAnimeProcessor(options, database, publisher.NewKafka[anime_processor.ProducerPayload](algoliaProducer), publisher.NewKafka[anime_processor.ImagePayload](kafkaProducer))
 
 	// Setup context with logger
 	log := zap.NewNop()
@@ -189,7 +190,12 @@ func TestRealProcessorWorkflowWithMocks(t *testing.T) {
 		assert.Equal(t, newTitle, *updatedAnime.TitleEn)
 
 		// Verify producers were called for update
-		assert.Len(t, algoliaMessages, 1, "Algolia producer should be called once for update")
+		require.Len(t, algoliaMessages, 1, "Algolia producer should be called once for update")
+
+		var producerPayload anime_processor.ProducerPayload
+		err = json.Unmarshal(algoliaMessages[0].Value, &producerPayload)
+		require.NoError(t, err)
+		assert.Equal(t, anime_processor.UpdateAction, producerPayload.Action)
 	})
 
 	t.Run("TestRealProcessorDeleteWithTheTVDBID", func(t *testing.T) {
@@ -242,6 +248,15 @@ func TestRealProcessorWorkflowWithMocks(t *testing.T) {
 		var deletedAnime anime.Anime
 		err = database.DB.Where("id = ?", "real-proc-delete").First(&deletedAnime).Error
 		assert.Error(t, err, "Anime should be deleted from database")
+
+		// Verify the delete was sent to algolia
+		require.Len(t, algoliaMessages, 1, "Algolia producer should be called once for delete")
+		var producerPayload anime_processor.ProducerPayload
+		err = json.Unmarshal(algoliaMessages[0].Value, &producerPayload)
+		require.NoError(t, err)
+		assert.Equal(t, anime_processor.DeleteAction, producerPayload.Action)
+		assert.Equal(t, "real-proc-delete", producerPayload.Data.ID)
+		assert.Empty(t, kafkaMessages, "No image should be sent for a delete")
 	})
 
 	t.Run("TestRealProcessorWithoutImageURL", func(t *testing.T) {
@@ -377,7 +392,7 @@ func TestSyncTagsWithJSONGenres(t *testing.T) {
 
 	// Create processor
 	options := anime_processor.Options{NoErrorOnDelete: false}
-	processor := anime_processor.NewAnimeProcessor(options, database, algoliaProducer, kafkaProducer)
+	processor := anime_processor.NewAnimeProcessor(options, database, publisher.NewKafka[anime_processor.ProducerPayload](algoliaProducer), publisher.NewKafka[anime_processor.ImagePayload](kafkaProducer))
 
 	// Setup context with logger
 	log := zap.NewNop()
@@ -0,0 +1,166 @@
+package anime_processor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"go.uber.org/zap"
+)
+
+// TestParseSeason checks the SEASON_YEAR validation
+func TestParseSeason(t *testing.T) {
+	validCases := map[string]string{
+		"SPRING_2024":   "SPRING_2024",
+		"winter_1999":   "WINTER_1999",
+		" Summer_2010 ": "SUMMER_2010",
+		"FALL_2023":     "FALL_2023",
+	}
+	for input, expected := range validCases {
+		season, err := ParseSeason(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, season)
+	}
+
+	for _, input := range []string{"AUTUMN_2024", "SPRING 2024", "SPRING_24", "2024_SPRING", "SPRING"} {
+		_, err := ParseSeason(input)
+		assert.Error(t, err, input)
+	}
+}
+
+// TestSeasonIntegration focuses on carrying the season column through the processor
+func TestSeasonIntegration(t *testing.T) {
+	// Skip if not running integration tests
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	// Setup database connection
+	cfg := &config.DBConfig{
+		Host:     "localhost",
+		Port:     3306,
+		User:     "weeb",
+		Password: "mysecretpassword",
+		DataBase: "weeb",
+		SSLMode:  "false",
+	}
+
+	database := db.NewDB(*cfg)
+	require.NotNil(t, database)
+	require.NotNil(t, database.DB)
+
+	// Test database connection
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	err = sqlDB.Ping()
+	require.NoError(t, err, "Database should be accessible")
+
+	// Clean up test data before and after
+	cleanup := func() {
+		database.DB.Where("id LIKE ?", "season-test-%").Delete(&anime.Anime{})
+	}
+	cleanup()
+	defer cleanup()
+
+	// Setup context with logger
+	log := zap.NewNop()
+	ctx := logger.WithCtx(context.Background(), log)
+
+	var algoliaMessages []*kafka.Message
+	algoliaProducer := func(ctx context.Context, message *kafka.Message) error {
+		algoliaMessages = append(algoliaMessages, message)
+		return nil
+	}
+	imageProducer := func(ctx context.Context, message *kafka.Message) error {
+		return nil
+	}
+
+	processor := NewAnimeProcessor(Options{NoErrorOnDelete: false}, database, publisher.NewKafka[ProducerPayload](algoliaProducer), publisher.NewKafka[ImagePayload](imageProducer))
+
+	t.Run("TestParseToEntityWithSeason", func(t *testing.T) {
+		season := "spring_2024"
+		titleEn := "Season Parse Test"
+
+		animeEntity, err := processor.(*AnimeProcessorImpl).ParseToEntity(ctx, Schema{
+			ID:      "season-test-parse",
+			TitleEn: &titleEn,
+			Season:  &season,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, animeEntity.Season)
+		assert.Equal(t, "SPRING_2024", *animeEntity.Season)
+	})
+
+	t.Run("TestParseToEntityWithInvalidSeason", func(t *testing.T) {
+		season := "Spring 2024"
+		titleEn := "Season Invalid Test"
+
+		_, err := processor.(*AnimeProcessorImpl).ParseToEntity(ctx, Schema{
+			ID:      "season-test-invalid",
+			TitleEn: &titleEn,
+			Season:  &season,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("TestCreateWithSeasonPublishesSeason", func(t *testing.T) {
+		algoliaMessages = nil
+		season := "fall_2023"
+		titleEn := "Season Create Test"
+
+		payload := Payload{
+			After: &Schema{
+				ID:      "season-test-create",
+				TitleEn: &titleEn,
+				Season:  &season,
+			},
+		}
+
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: payload})
+		require.NoError(t, err)
+
+		// the anime row is still written even though season is not a column on anime anymore
+		var savedAnime anime.Anime
+		err = database.DB.Where("id = ?", "season-test-create").First(&savedAnime).Error
+		require.NoError(t, err)
+		assert.Equal(t, titleEn, *savedAnime.TitleEn)
+
+		require.Len(t, algoliaMessages, 1)
+		var producerPayload ProducerPayload
+		err = json.Unmarshal(algoliaMessages[0].Value, &producerPayload)
+		require.NoError(t, err)
+		require.NotNil(t, producerPayload.Data.Season)
+		assert.Equal(t, "FALL_2023", *producerPayload.Data.Season)
+
+		// the incoming payload is left untouched
+		assert.Equal(t, "fall_2023", *payload.After.Season)
+	})
+
+	t.Run("TestCreateWithoutSeason", func(t *testing.T) {
+		algoliaMessages = nil
+		titleEn := "Season Missing Test"
+
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: Payload{
+			After: &Schema{
+				ID:      "season-test-missing",
+				TitleEn: &titleEn,
+			},
+		}})
+		require.NoError(t, err)
+
+		require.Len(t, algoliaMessages, 1)
+		var producerPayload ProducerPayload
+		err = json.Unmarshal(algoliaMessages[0].Value, &producerPayload)
+		require.NoError(t, err)
+		assert.Nil(t, producerPayload.Data.Season)
+	})
+}
@@ -0,0 +1,227 @@
+// Package synctest holds fakes of the stores of the anime sync service and a test
+// suite every transport adapter of the service runs
+package synctest
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_tag"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/tag"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+)
+
+// Store keeps committed state and stages writes of the running transaction
+type Store struct {
+	Anime        map[string]*anime.Anime
+	AnimeTags    map[string][]int64
+	Tags         map[string]int64
+	Transactions int
+	// FailTag makes finding the tag of that name fail like a deadlock
+	FailTag string
+	staged  *Store
+}
+
+func NewStore() *Store {
+	return &Store{Anime: map[string]*anime.Anime{}, AnimeTags: map[string][]int64{}, Tags: map[string]int64{}}
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(ctx context.Context, tx *db.DB) error) error {
+	s.Transactions++
+	s.staged = NewStore()
+	defer func() { s.staged = nil }()
+
+	if err := fn(ctx, nil); err != nil {
+		return err
+	}
+	for id, a := range s.staged.Anime {
+		s.Anime[id] = a
+	}
+	for id, tagIDs := range s.staged.AnimeTags {
+		s.AnimeTags[id] = tagIDs
+	}
+	for name, id := range s.staged.Tags {
+		s.Tags[name] = id
+	}
+	return nil
+}
+
+type AnimeRepository struct {
+	Store *Store
+	// UpdatedColumns records the columns of every UpdateColumns call
+	UpdatedColumns [][]string
+	// Upserts counts the UpsertMany calls
+	Upserts int
+}
+
+func (r *AnimeRepository) Upsert(a *anime.Anime) error {
+	r.Store.staged.Anime[a.ID] = a
+	return nil
+}
+func (r *AnimeRepository) UpsertMany(animes []*anime.Anime) error {
+	r.Upserts++
+	for _, a := range animes {
+		r.Store.staged.Anime[a.ID] = a
+	}
+	return nil
+}
+func (r *AnimeRepository) UpdateColumns(a *anime.Anime, columns []string) error {
+	r.UpdatedColumns = append(r.UpdatedColumns, columns)
+	r.Store.staged.Anime[a.ID] = a
+	return nil
+}
+func (r *AnimeRepository) Delete(a *anime.Anime) error                { delete(r.Store.Anime, a.ID); return nil }
+func (r *AnimeRepository) WithTx(tx *db.DB) anime.AnimeRepositoryImpl { return r }
+func (r *AnimeRepository) FindPage(filter anime.Filter, afterID string, limit int) ([]anime.Anime, error) {
+	return nil, nil
+}
+func (r *AnimeRepository) Count(filter anime.Filter) (int64, error) { return 0, nil }
+
+type TagRepository struct{ Store *Store }
+
+func (r *TagRepository) FindOrCreate(name string) (*tag.Tag, error) {
+	if name == r.Store.FailTag {
+		return nil, errors.New("deadlock found when trying to get lock")
+	}
+	if id, ok := r.Store.staged.Tags[name]; ok {
+		return &tag.Tag{ID: id, Name: name}, nil
+	}
+	id := int64(len(r.Store.Tags) + len(r.Store.staged.Tags) + 1)
+	r.Store.staged.Tags[name] = id
+	return &tag.Tag{ID: id, Name: name}, nil
+}
+func (r *TagRepository) FindByName(name string) (*tag.Tag, error)      { return nil, nil }
+func (r *TagRepository) FindByNames(names []string) ([]tag.Tag, error) { return nil, nil }
+func (r *TagRepository) Create(t *tag.Tag) error                       { return nil }
+func (r *TagRepository) WithTx(tx *db.DB) tag.TagRepositoryImpl        { return r }
+
+type AnimeTagRepository struct{ Store *Store }
+
+func (r *AnimeTagRepository) SetTagsForAnime(animeID string, tagIDs []int64) error {
+	r.Store.staged.AnimeTags[animeID] = tagIDs
+	return nil
+}
+func (r *AnimeTagRepository) SetTagsForAnimes(tagIDs map[string][]int64) error {
+	for animeID, ids := range tagIDs {
+		r.Store.staged.AnimeTags[animeID] = ids
+	}
+	return nil
+}
+func (r *AnimeTagRepository) GetTagIDsForAnime(animeID string) ([]int64, error) { return nil, nil }
+func (r *AnimeTagRepository) GetTagNamesForAnimes(animeIDs []string) (map[string][]string, error) {
+	return nil, nil
+}
+func (r *AnimeTagRepository) AddTagToAnime(animeID string, tagID int64) error { return nil }
+func (r *AnimeTagRepository) RemoveTagFromAnime(animeID string, tagID int64) error {
+	return nil
+}
+func (r *AnimeTagRepository) DeleteAllTagsForAnime(animeID string) error { return nil }
+func (r *AnimeTagRepository) WithTx(tx *db.DB) anime_tag.AnimeTagRepositoryImpl {
+	return r
+}
+
+type TitleHistoryRepository struct {
+	Entries []anime_title_history.AnimeTitleHistory
+}
+
+func (r *TitleHistoryRepository) Create(entries []anime_title_history.AnimeTitleHistory) error {
+	r.Entries = append(r.Entries, entries...)
+	return nil
+}
+func (r *TitleHistoryRepository) GetOldTitlesForAnimes(animeIDs []string) (map[string][]string, error) {
+	oldTitles := map[string][]string{}
+	for _, entry := range r.Entries {
+		if entry.OldTitle != nil && slices.Contains(animeIDs, entry.AnimeID) {
+			oldTitles[entry.AnimeID] = append(oldTitles[entry.AnimeID], *entry.OldTitle)
+		}
+	}
+	return oldTitles, nil
+}
+func (r *TitleHistoryRepository) WithTx(tx *db.DB) anime_title_history.AnimeTitleHistoryRepositoryImpl {
+	return r
+}
+
+type PositionRepository struct {
+	Positions map[string]sync_position.SyncPosition
+}
+
+func (r *PositionRepository) Find(entity string, entityID string) (*sync_position.SyncPosition, error) {
+	if position, ok := r.Positions[entity+"/"+entityID]; ok {
+		return &position, nil
+	}
+	return nil, nil
+}
+
+func (r *PositionRepository) Advance(position *sync_position.SyncPosition) (bool, error) {
+	stored, _ := r.Find(position.Entity, position.EntityID)
+	if stored != nil && position.OlderThan(*stored) {
+		return true, nil
+	}
+	r.Positions[position.Entity+"/"+position.EntityID] = *position
+	return false, nil
+}
+
+func (r *PositionRepository) Check(position *sync_position.SyncPosition, forceReplay bool) (bool, error) {
+	if position.IsZero() {
+		return false, nil
+	}
+	stale, _ := r.Advance(position)
+	return stale && !forceReplay, nil
+}
+
+func (r *PositionRepository) AdvanceMany(positions []*sync_position.SyncPosition) ([]bool, error) {
+	stale := make([]bool, len(positions))
+	for i, position := range positions {
+		if !position.IsZero() {
+			stale[i], _ = r.Advance(position)
+		}
+	}
+	return stale, nil
+}
+
+func (r *PositionRepository) WithTx(tx *db.DB) sync_position.SyncPositionRepositoryImpl {
+	return r
+}
+
+// Fakes are the fakes a sync service built by Service works on
+type Fakes struct {
+	Store        *Store
+	Anime        *AnimeRepository
+	TitleHistory *TitleHistoryRepository
+	Positions    *PositionRepository
+	Search       *publisher.Memory[anime_processor.ProducerPayload]
+	Images       *publisher.Memory[anime_processor.ImagePayload]
+}
+
+func NewFakes() *Fakes {
+	store := NewStore()
+	return &Fakes{
+		Store:        store,
+		Anime:        &AnimeRepository{Store: store},
+		TitleHistory: &TitleHistoryRepository{},
+		Positions:    &PositionRepository{Positions: map[string]sync_position.SyncPosition{}},
+		Search:       &publisher.Memory[anime_processor.ProducerPayload]{},
+		Images:       &publisher.Memory[anime_processor.ImagePayload]{},
+	}
+}
+
+// Service returns a sync service that stores and publishes to the fakes
+func (f *Fakes) Service(options anime_processor.Options) *anime_processor.AnimeSyncService {
+	return &anime_processor.AnimeSyncService{
+		Transactor:             f.Store,
+		Repository:             f.Anime,
+		TagRepository:          &TagRepository{Store: f.Store},
+		AnimeTagRepository:     &AnimeTagRepository{Store: f.Store},
+		PositionRepository:     f.Positions,
+		TitleHistoryRepository: f.TitleHistory,
+		Options:                options,
+		Search:                 f.Search,
+		Images:                 f.Images,
+	}
+}
@@ -0,0 +1,206 @@
+package synctest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+)
+
+// Adapter hands a change event to the sync service the way a transport adapter does
+type Adapter func(ctx context.Context, payload anime_processor.Payload) error
+
+// Run tests the sync service through the adapter newAdapter builds around it, so every transport
+// applies and publishes events the same way
+func Run(t *testing.T, newAdapter func(service *anime_processor.AnimeSyncService) Adapter) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	str := func(value string) *string { return &value }
+
+	process := func(t *testing.T, fakes *Fakes, options anime_processor.Options, payloads ...anime_processor.Payload) error {
+		adapter := newAdapter(fakes.Service(options))
+		for _, payload := range payloads {
+			if err := adapter(ctx, payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t.Run("Actions", func(t *testing.T) {
+		imageURL := "https://example.com/algolia.jpg"
+		before := &anime_processor.Schema{ID: "algolia-test", TitleEn: str("Algolia Test"), ImageUrl: &imageURL}
+		after := &anime_processor.Schema{ID: "algolia-test", TitleEn: str("Algolia Test Renamed"), ImageUrl: &imageURL}
+
+		tests := []struct {
+			name           string
+			payload        anime_processor.Payload
+			action         anime_processor.Action
+			title          string
+			imagesProduced int
+		}{
+			{"Create", anime_processor.Payload{After: before}, anime_processor.CreateAction, "Algolia Test", 1},
+			{"Update", anime_processor.Payload{Before: before, After: after}, anime_processor.UpdateAction, "Algolia Test Renamed", 1},
+			{"Delete", anime_processor.Payload{Before: before}, anime_processor.DeleteAction, "Algolia Test", 0},
+			{"CreateWithoutTitle", anime_processor.Payload{After: &anime_processor.Schema{ID: "algolia-test"}}, anime_processor.CreateAction, "", 0},
+			{"CreateWithoutTitleWithImage", anime_processor.Payload{After: &anime_processor.Schema{ID: "algolia-test", ImageUrl: &imageURL}}, anime_processor.CreateAction, "", 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				fakes := NewFakes()
+				require.NoError(t, process(t, fakes, anime_processor.Options{}, tt.payload))
+
+				messages := fakes.Search.Messages()
+				require.Len(t, messages, 1)
+				assert.Equal(t, "algolia-test", messages[0].Key)
+				assert.Equal(t, map[string]string{
+					publisher.HeaderAction:        tt.action,
+					publisher.HeaderEntity:        anime_processor.EntityAnime,
+					publisher.HeaderSchemaVersion: anime_processor.SchemaVersion,
+				}, messages[0].Headers())
+
+				produced := messages[0].Value
+				assert.Equal(t, tt.action, produced.Action)
+				require.NotNil(t, produced.Data)
+				assert.Equal(t, "algolia-test", produced.Data.ID)
+				if tt.title != "" {
+					require.NotNil(t, produced.Data.TitleEn)
+					assert.Equal(t, tt.title, *produced.Data.TitleEn)
+				}
+				images := fakes.Images.Messages()
+				require.Len(t, images, tt.imagesProduced)
+				for _, image := range images {
+					assert.Equal(t, "algolia-test", image.Key)
+					assert.Equal(t, "anime", image.Entity)
+				}
+			})
+		}
+	})
+
+	t.Run("UpdatesOnlyApplyChangedFields", func(t *testing.T) {
+		ts := func(value int64) *int64 { return &value }
+		before := anime_processor.Schema{
+			ID:        "diff-test",
+			TitleEn:   str("Cowboy Bebop"),
+			ImageUrl:  str("https://example.com/bebop.jpg"),
+			Synopsis:  str("Bounty hunters in space"),
+			Genres:    str(`["Action","Space"]`),
+			UpdatedAt: ts(1),
+		}
+
+		tests := []struct {
+			name           string
+			change         func(after *anime_processor.Schema)
+			columns        [][]string
+			tagsSynced     bool
+			searchUpdates  int
+			imagesProduced int
+		}{
+			{"OnlyTimestamps", func(after *anime_processor.Schema) { after.UpdatedAt = ts(2) }, nil, false, 0, 0},
+			{"Synopsis", func(after *anime_processor.Schema) { after.Synopsis = str("Bounty hunters in 2071") }, [][]string{{"synopsis"}}, false, 1, 0},
+			{"Genres", func(after *anime_processor.Schema) { after.Genres = str(`["Action"]`) }, [][]string{{"genres"}}, true, 1, 0},
+			{"ImageURL", func(after *anime_processor.Schema) { after.ImageUrl = str("https://example.com/bebop-hd.jpg") }, [][]string{{"image_url"}}, false, 1, 1},
+			{"Title", func(after *anime_processor.Schema) { after.TitleEn = str("Cowboy Bebop: The Movie") }, [][]string{{"title_en"}}, false, 1, 1},
+			{"NotSearchable", func(after *anime_processor.Schema) { after.AnidbID = str("23") }, [][]string{{"anidbid"}}, false, 0, 0},
+			{"SeasonIsNotStored", func(after *anime_processor.Schema) { after.Season = str("SPRING_1998") }, nil, false, 1, 0},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				fakes := NewFakes()
+				after := before
+				tt.change(&after)
+				require.NoError(t, process(t, fakes, anime_processor.Options{}, anime_processor.Payload{Before: &before, After: &after}))
+
+				assert.Equal(t, tt.columns, fakes.Anime.UpdatedColumns)
+				_, tagsSynced := fakes.Store.AnimeTags["diff-test"]
+				assert.Equal(t, tt.tagsSynced, tagsSynced)
+				assert.Len(t, fakes.Search.Messages(), tt.searchUpdates)
+				assert.Len(t, fakes.Images.Messages(), tt.imagesProduced)
+			})
+		}
+	})
+
+	t.Run("AnimeAndTagsCommitAtomically", func(t *testing.T) {
+		payload := anime_processor.Payload{After: &anime_processor.Schema{ID: "tx-test", TitleEn: str("Transaction Test"), Genres: str(`["Drama","Action","Drama"]`)}}
+
+		t.Run("CommitsAnimeWithTags", func(t *testing.T) {
+			fakes := NewFakes()
+			require.NoError(t, process(t, fakes, anime_processor.Options{}, payload))
+
+			assert.Contains(t, fakes.Store.Anime, "tx-test")
+			assert.Len(t, fakes.Store.AnimeTags["tx-test"], 2, "duplicate genres should map to one tag")
+			assert.Equal(t, 1, fakes.Store.Transactions)
+			assert.Len(t, fakes.Search.Messages(), 1)
+		})
+
+		t.Run("TagFailureRollsBackAnimeAndRetries", func(t *testing.T) {
+			fakes := NewFakes()
+			fakes.Store.FailTag = "Action"
+			require.Error(t, process(t, fakes, anime_processor.Options{}, payload))
+
+			assert.NotContains(t, fakes.Store.Anime, "tx-test", "anime should not be saved without its tags")
+			assert.Empty(t, fakes.Store.AnimeTags)
+			// the transaction and its 3 retries
+			assert.Equal(t, 4, fakes.Store.Transactions, "whole unit should be retried")
+			assert.Empty(t, fakes.Search.Messages(), "nothing should be published when the transaction fails")
+		})
+	})
+
+	t.Run("StaleEventsAreSkipped", func(t *testing.T) {
+		newTitle := "Newer Title"
+		oldTitle := "Older Title"
+		newer := anime_processor.Payload{Before: &anime_processor.Schema{ID: "stale-test", TitleEn: &oldTitle}, After: &anime_processor.Schema{ID: "stale-test", TitleEn: &newTitle}, Source: anime_processor.Source{Lsn: 200, TsMs: 2000}}
+		older := anime_processor.Payload{Before: &anime_processor.Schema{ID: "stale-test", TitleEn: &newTitle}, After: &anime_processor.Schema{ID: "stale-test", TitleEn: &oldTitle}, Source: anime_processor.Source{Lsn: 100, TsMs: 1000}}
+
+		t.Run("OlderEventIsSkipped", func(t *testing.T) {
+			fakes := NewFakes()
+			require.NoError(t, process(t, fakes, anime_processor.Options{}, newer, older))
+
+			require.Contains(t, fakes.Store.Anime, "stale-test")
+			assert.Equal(t, newTitle, *fakes.Store.Anime["stale-test"].TitleEn)
+			assert.Len(t, fakes.Search.Messages(), 1, "stale event should not be published")
+		})
+
+		t.Run("ForceReplayAppliesOlderEvent", func(t *testing.T) {
+			fakes := NewFakes()
+			require.NoError(t, process(t, fakes, anime_processor.Options{ForceReplay: true}, newer, older))
+
+			require.Contains(t, fakes.Store.Anime, "stale-test")
+			assert.Equal(t, oldTitle, *fakes.Store.Anime["stale-test"].TitleEn)
+			assert.Len(t, fakes.Search.Messages(), 2)
+		})
+	})
+
+	t.Run("TitleChangesAreRecordedAndPublished", func(t *testing.T) {
+		first := &anime_processor.Schema{ID: "title-test", TitleEn: str("Cowboy Bebop"), TitleJp: str("カウボーイビバップ")}
+		// the English title is removed, comparing it used to dereference nil
+		second := &anime_processor.Schema{ID: "title-test", TitleJp: str("カウボーイビバップ")}
+		third := &anime_processor.Schema{ID: "title-test", TitleEn: str("Cowboy Bebop"), TitleJp: str("Kaubōi Bibappu")}
+
+		fakes := NewFakes()
+		require.NoError(t, process(t, fakes, anime_processor.Options{},
+			anime_processor.Payload{Before: first, After: second, Source: anime_processor.Source{TsMs: 1700000000000}},
+			anime_processor.Payload{Before: second, After: third, Source: anime_processor.Source{TsMs: 1700000060000}},
+		))
+
+		entries := fakes.TitleHistory.Entries
+		require.Len(t, entries, 3)
+		assert.Equal(t, "title_en", entries[0].Field)
+		assert.Equal(t, "Cowboy Bebop", *entries[0].OldTitle)
+		assert.Nil(t, entries[0].NewTitle)
+		assert.Equal(t, time.UnixMilli(1700000000000), entries[0].ChangedAt)
+
+		documents := fakes.Search.Values()
+		require.Len(t, documents, 2)
+		assert.Equal(t, []string{"Cowboy Bebop"}, documents[0].Data.OldTitles)
+		assert.Equal(t, []string{"カウボーイビバップ"}, documents[1].Data.OldTitles, "titles the anime has again are not old titles")
+	})
+}
@@ -53,7 +53,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 
 	// Create anime processor components
 	repository := anime.NewAnimeRepository(database)
-	processor := &AnimeProcessorImpl{Repository: repository}
+	processor := &AnimeSyncService{Repository: repository}
 
 	t.Run("TestParseToEntityWithTheTVDBID", func(t *testing.T) {
 		thetvdbid := "987654"
@@ -125,7 +125,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 		}
 
 		// Test Upsert (create)
-		err := repository.Upsert(animeEntity, nil)
+		err := repository.Upsert(animeEntity)
 		require.NoError(t, err)
 
 		// Verify anime was saved with TheTVDBID
@@ -141,7 +141,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 		animeEntity.TheTVDBID = &newTheTVDBID
 		animeEntity.UpdatedAt = time.Now()
 
-		err = repository.Upsert(animeEntity, nil)
+		err = repository.Upsert(animeEntity)
 		require.NoError(t, err)
 
 		// Verify TheTVDBID was updated
@@ -180,7 +180,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
 			}
-			err := repository.Upsert(animeEntity, nil)
+			err := repository.Upsert(animeEntity)
 			require.NoError(t, err)
 		}
 
@@ -235,7 +235,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 					UpdatedAt: time.Now(),
 				}
 
-				err := repository.Upsert(animeEntity, nil)
+				err := repository.Upsert(animeEntity)
 				if tc.shouldErr {
 					assert.Error(t, err)
 				} else {
@@ -269,7 +269,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := repository.Upsert(animeWithoutTheTVDBID, nil)
+		err := repository.Upsert(animeWithoutTheTVDBID)
 		require.NoError(t, err)
 
 		// Verify no TheTVDBID initially
@@ -283,7 +283,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 		animeWithoutTheTVDBID.TheTVDBID = &thetvdbid
 		animeWithoutTheTVDBID.UpdatedAt = time.Now()
 
-		err = repository.Upsert(animeWithoutTheTVDBID, nil)
+		err = repository.Upsert(animeWithoutTheTVDBID)
 		require.NoError(t, err)
 
 		// Verify TheTVDBID was added
@@ -296,7 +296,7 @@ func TestTheTVDBIDIntegration(t *testing.T) {
 		animeWithoutTheTVDBID.TheTVDBID = nil
 		animeWithoutTheTVDBID.UpdatedAt = time.Now()
 
-		err = repository.Upsert(animeWithoutTheTVDBID, nil)
+		err = repository.Upsert(animeWithoutTheTVDBID)
 		require.NoError(t, err)
 
 		// Verify TheTVDBID was removed
@@ -345,7 +345,7 @@ func TestProcessorCoreLogicWithTheTVDBID(t *testing.T) {
 
 	// Create processor implementation directly
 	repository := anime.NewAnimeRepository(database)
-	processor := &AnimeProcessorImpl{
+	processor := &AnimeSyncService{
 		Repository: repository,
 		Options:    Options{NoErrorOnDelete: false},
 	}
@@ -370,7 +370,7 @@ func TestProcessorCoreLogicWithTheTVDBID(t *testing.T) {
 		assert.Equal(t, thetvdbid, *animeEntity.TheTVDBID)
 
 		// Test repository upsert
-		err = processor.Repository.Upsert(animeEntity, nil)
+		err = processor.Repository.Upsert(animeEntity)
 		require.NoError(t, err)
 
 		// Verify in database
@@ -396,7 +396,7 @@ func TestProcessorCoreLogicWithTheTVDBID(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := processor.Repository.Upsert(initialEntity, nil)
+		err := processor.Repository.Upsert(initialEntity)
 		require.NoError(t, err)
 
 		// Now test update with new TheTVDBID
@@ -416,7 +416,7 @@ func TestProcessorCoreLogicWithTheTVDBID(t *testing.T) {
 		assert.Equal(t, newTheTVDBID, *updatedEntity.TheTVDBID)
 
 		// Test repository update
-		err = processor.Repository.Upsert(updatedEntity, &titleEn) // oldTitle
+		err = processor.Repository.Upsert(updatedEntity)
 		require.NoError(t, err)
 
 		// Verify update in database
@@ -441,7 +441,7 @@ func TestProcessorCoreLogicWithTheTVDBID(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := processor.Repository.Upsert(deleteEntity, nil)
+		err := processor.Repository.Upsert(deleteEntity)
 		require.NoError(t, err)
 
 		// Verify it exists
@@ -506,7 +506,7 @@ func TestProcessorCoreLogicWithTheTVDBID(t *testing.T) {
 		assert.Contains(t, *complexEntity.EndDate, "2024-09-30")
 
 		// Test saving complex entity
-		err = processor.Repository.Upsert(complexEntity, nil)
+		err = processor.Repository.Upsert(complexEntity)
 		require.NoError(t, err)
 
 		// Verify everything was saved correctly
@@ -0,0 +1,26 @@
+package anime_processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+)
+
+func TestTitleChanges(t *testing.T) {
+	str := func(value string) *string { return &value }
+	changedAt := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	before := &Schema{ID: "title-test", TitleEn: str("Shingeki no Kyojin"), TitleJp: str("進撃の巨人"), TitleRomaji: str("Shingeki no Kyojin")}
+	after := &Schema{ID: "title-test", TitleEn: str("Attack on Titan"), TitleJp: str("進撃の巨人"), TitleKanji: str("進撃の巨人")}
+
+	assert.Equal(t, []anime_title_history.AnimeTitleHistory{
+		{AnimeID: "title-test", Field: "title_en", OldTitle: str("Shingeki no Kyojin"), NewTitle: str("Attack on Titan"), ChangedAt: changedAt},
+		{AnimeID: "title-test", Field: "title_romaji", OldTitle: str("Shingeki no Kyojin"), ChangedAt: changedAt},
+		{AnimeID: "title-test", Field: "title_kanji", NewTitle: str("進撃の巨人"), ChangedAt: changedAt},
+	}, TitleChanges(before, after, changedAt))
+
+	assert.Empty(t, TitleChanges(after, after, changedAt))
+}
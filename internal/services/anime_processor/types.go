@@ -17,6 +17,16 @@ const (
 	DeleteAction Action = "delete"
 )
 
+// EntityAnime is the entity header of anime search documents
+const EntityAnime = "anime"
+
+// SchemaVersion and ImageSchemaVersion are sent with search documents and image requests, bump them
+// when their JSON changes in a way consumers have to handle
+const (
+	SchemaVersion      = "1"
+	ImageSchemaVersion = "1"
+)
+
 type Schema struct {
 	ID            string  `json:"id"`
 	AnidbID       *string `json:"anidbid"`
@@ -43,6 +53,11 @@ type Schema struct {
 	Licensors     *string `json:"licensors"`
 	Studios       *string `json:"studios"`
 	Ranking       *int    `json:"ranking"`
+	Season        *string `json:"season"`
+	// Tags are not part of change events, they are filled from anime_tags for re-indexed documents
+	Tags []string `json:"tags,omitempty"`
+	// OldTitles are not part of change events, they are filled from anime_title_history for search documents
+	OldTitles []string `json:"old_titles,omitempty"`
 }
 
 type Source struct {
@@ -75,6 +90,8 @@ type ImageSchema struct {
 	Name string   `json:"name"`
 	URL  string   `json:"url"`
 	Type DataType `json:"type"`
+	// LegacyName is the name the image was stored under before names were slugged, empty without a name
+	LegacyName string `json:"legacy_name,omitempty"`
 }
 type ImagePayload struct {
 	Data ImageSchema `json:"data"`
@@ -0,0 +1,276 @@
+package anime_relation_processor
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_relation"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"go.uber.org/zap"
+)
+
+// inverseRelations maps a relation type to the type of the edge pointing back.
+// Symmetric relations map to themselves, types missing here get no inverse edge.
+var inverseRelations = map[RelationType]RelationType{
+	RelationSequel:             RelationPrequel,
+	RelationPrequel:            RelationSequel,
+	RelationSideStory:          RelationParentStory,
+	RelationSpinOff:            RelationParentStory,
+	RelationSummary:            RelationFullStory,
+	RelationFullStory:          RelationSummary,
+	RelationAlternativeVersion: RelationAlternativeVersion,
+	RelationAlternativeSetting: RelationAlternativeSetting,
+	RelationCharacter:          RelationCharacter,
+	RelationOther:              RelationOther,
+}
+
+type Options struct {
+	NoErrorOnDelete bool
+	// MaintainInverse also writes the reverse edge, so a sequel A→B produces a prequel B→A
+	MaintainInverse bool
+}
+
+type AnimeRelationProcessor interface {
+	Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error)
+}
+
+type AnimeRelationProcessorImpl struct {
+	Transactor db.Transactor
+	Repository anime_relation.AnimeRelationRepositoryImpl
+	Options    Options
+}
+
+func NewAnimeRelationProcessor(opt Options, db *db.DB) AnimeRelationProcessor {
+	return &AnimeRelationProcessorImpl{
+		Transactor: db,
+		Repository: anime_relation.NewAnimeRelationRepository(db),
+		Options:    opt,
+	}
+}
+
+func (p *AnimeRelationProcessorImpl) Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error) {
+	log := logger.FromCtx(ctx)
+
+	payload := data.Payload
+
+	log.Debug("Payload", zap.Any("payload", payload))
+
+	if payload.After != nil {
+		// add or update db, together with the inverse edge
+		newRelation := p.parseToEntity(*payload.After)
+		err := p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			repository := p.Repository.WithTx(tx)
+			err := repository.Upsert(newRelation)
+			if err != nil {
+				return err
+			}
+
+			if !p.Options.MaintainInverse {
+				return nil
+			}
+
+			if payload.Before != nil {
+				oldRelation := p.parseToEntity(*payload.Before)
+				if !sameEdge(oldRelation, newRelation) {
+					// the relation type changed, reverse edges lost their explicit counterpart
+					err = p.restoreInverses(ctx, repository, oldRelation)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return p.upsertInverse(ctx, repository, newRelation, payload.Before)
+		})
+		if err != nil {
+			return data, err
+		}
+		return data, nil
+	}
+
+	if payload.Before != nil {
+		// delete from db, together with the inverse edge
+		oldRelation := p.parseToEntity(*payload.Before)
+
+		err := p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			repository := p.Repository.WithTx(tx)
+			err := repository.Delete(oldRelation)
+			if err != nil {
+				if p.Options.NoErrorOnDelete {
+					log.Warn("WARN: error deleting from db: ", zap.Error(err))
+					return nil
+				}
+				return err
+			}
+
+			if !p.Options.MaintainInverse {
+				return nil
+			}
+
+			err = p.deleteInverse(ctx, repository, oldRelation)
+			if err != nil {
+				return err
+			}
+			return p.restoreInverses(ctx, repository, oldRelation)
+		})
+		if err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+// upsertInverse writes the reverse edge of relation. When the source has the reverse edge itself the
+// reverse edge is not written, and the one derived from the reverse edge, which duplicates relation,
+// is dropped as well
+func (p *AnimeRelationProcessorImpl) upsertInverse(ctx context.Context, repository anime_relation.AnimeRelationRepositoryImpl, relation *anime_relation.AnimeRelation, before *Schema) error {
+	log := logger.FromCtx(ctx)
+
+	inverse := InverseOf(relation)
+	if inverse == nil {
+		if before == nil {
+			return nil
+		}
+		// the relation type changed to one without an inverse, drop the stale reverse edge
+		return p.deleteInverse(ctx, repository, p.parseToEntity(*before))
+	}
+
+	reverseEdges, err := repository.FindByEdge(inverse.AnimeID, inverse.RelatedAnimeID, *inverse.RelationType)
+	if err != nil {
+		return err
+	}
+
+	explicit := false
+	for _, reverse := range reverseEdges {
+		if reverse.ID == inverse.ID {
+			continue
+		}
+		explicit = true
+		if duplicate := InverseOf(&reverse); duplicate != nil {
+			err = repository.Delete(duplicate)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if explicit {
+		log.Info("Reverse relation exists, skipping inverse relation", zap.String("id", relation.ID))
+		return repository.Delete(inverse)
+	}
+
+	log.Info("Upserting inverse relation", zap.String("id", inverse.ID), zap.String("relationType", *inverse.RelationType))
+	return repository.Upsert(inverse)
+}
+
+// restoreInverses writes the inverse edges of the reverse edges of relation again, they were left out
+// while relation existed and relation is gone now
+func (p *AnimeRelationProcessorImpl) restoreInverses(ctx context.Context, repository anime_relation.AnimeRelationRepositoryImpl, relation *anime_relation.AnimeRelation) error {
+	log := logger.FromCtx(ctx)
+
+	inverse := InverseOf(relation)
+	if inverse == nil {
+		return nil
+	}
+
+	reverseEdges, err := repository.FindByEdge(inverse.AnimeID, inverse.RelatedAnimeID, *inverse.RelationType)
+	if err != nil {
+		return err
+	}
+
+	for _, reverse := range reverseEdges {
+		if reverse.ID == inverse.ID {
+			continue
+		}
+		restored := InverseOf(&reverse)
+		if restored == nil {
+			continue
+		}
+		log.Info("Restoring inverse relation", zap.String("id", restored.ID), zap.String("relationType", *restored.RelationType))
+		err = repository.Upsert(restored)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *AnimeRelationProcessorImpl) deleteInverse(ctx context.Context, repository anime_relation.AnimeRelationRepositoryImpl, relation *anime_relation.AnimeRelation) error {
+	log := logger.FromCtx(ctx)
+
+	inverse := InverseOf(relation)
+	if inverse == nil {
+		return nil
+	}
+
+	err := repository.Delete(inverse)
+	if err != nil {
+		if p.Options.NoErrorOnDelete {
+			log.Warn("WARN: error deleting inverse relation from db: ", zap.Error(err))
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *AnimeRelationProcessorImpl) parseToEntity(data Schema) *anime_relation.AnimeRelation {
+	var newRelation anime_relation.AnimeRelation
+
+	newRelation.ID = data.ID
+	newRelation.AnimeID = data.AnimeID
+	newRelation.RelatedAnimeID = data.RelatedAnimeID
+	newRelation.RelationType = data.RelationType
+	newRelation.CreatedAt = time.Now()
+
+	return &newRelation
+}
+
+// InverseOf returns the reverse edge of a relation, or nil when its type has no inverse.
+// The inverse ID is derived from the original ID so replays update the same row.
+func InverseOf(relation *anime_relation.AnimeRelation) *anime_relation.AnimeRelation {
+	if relation.RelationType == nil {
+		return nil
+	}
+
+	inverseType, ok := inverseRelations[relationType(relation)]
+	if !ok {
+		return nil
+	}
+
+	return &anime_relation.AnimeRelation{
+		ID:             inverseID(relation.ID),
+		AnimeID:        relation.RelatedAnimeID,
+		RelatedAnimeID: relation.AnimeID,
+		RelationType:   &inverseType,
+		CreatedAt:      relation.CreatedAt,
+	}
+}
+
+// sameEdge reports whether both relations connect the same anime with the same relation type
+func sameEdge(a *anime_relation.AnimeRelation, b *anime_relation.AnimeRelation) bool {
+	return a.AnimeID == b.AnimeID && a.RelatedAnimeID == b.RelatedAnimeID &&
+		relationType(a) == relationType(b)
+}
+
+func relationType(relation *anime_relation.AnimeRelation) RelationType {
+	if relation.RelationType == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(*relation.RelationType))
+}
+
+// inverseID builds a name based (version 5 style) UUID from the original relation ID
+func inverseID(id string) string {
+	sum := sha1.Sum([]byte("anime_relations:inverse:" + id))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,208 @@
+package anime_relation_processor
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_relation"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transaction(ctx context.Context, fn func(ctx context.Context, tx *db.DB) error) error {
+	return fn(ctx, nil)
+}
+
+type fakeRelationRepository struct {
+	relations map[string]anime_relation.AnimeRelation
+}
+
+func (r *fakeRelationRepository) Upsert(relation *anime_relation.AnimeRelation) error {
+	r.relations[relation.ID] = *relation
+	return nil
+}
+
+func (r *fakeRelationRepository) Delete(relation *anime_relation.AnimeRelation) error {
+	delete(r.relations, relation.ID)
+	return nil
+}
+
+func (r *fakeRelationRepository) FindByEdge(animeID string, relatedAnimeID string, relationType string) ([]anime_relation.AnimeRelation, error) {
+	var relations []anime_relation.AnimeRelation
+	for _, relation := range r.relations {
+		if relation.AnimeID == animeID && relation.RelatedAnimeID == relatedAnimeID && strings.EqualFold(*relation.RelationType, relationType) {
+			relations = append(relations, relation)
+		}
+	}
+	return relations, nil
+}
+
+func (r *fakeRelationRepository) WithTx(tx *db.DB) anime_relation.AnimeRelationRepositoryImpl {
+	return r
+}
+
+// edges lists the stored relations as "anime>related:type", sorted
+func (r *fakeRelationRepository) edges() []string {
+	edges := make([]string, 0, len(r.relations))
+	for _, relation := range r.relations {
+		edges = append(edges, relation.AnimeID+">"+relation.RelatedAnimeID+":"+*relation.RelationType)
+	}
+	sort.Strings(edges)
+	return edges
+}
+
+func relationSchema(id string, animeID string, relatedAnimeID string, relationType string) *Schema {
+	return &Schema{ID: id, AnimeID: animeID, RelatedAnimeID: relatedAnimeID, RelationType: &relationType}
+}
+
+func TestProcess(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	setup := func(options Options) (*AnimeRelationProcessorImpl, *fakeRelationRepository) {
+		repository := &fakeRelationRepository{relations: map[string]anime_relation.AnimeRelation{}}
+		return &AnimeRelationProcessorImpl{
+			Transactor: fakeTransactor{},
+			Repository: repository,
+			Options:    options,
+		}, repository
+	}
+	process := func(t *testing.T, processor *AnimeRelationProcessorImpl, before *Schema, after *Schema) {
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: Payload{Before: before, After: after}})
+		require.NoError(t, err)
+	}
+
+	sequel := relationSchema("relation-1", "anime-a", "anime-b", RelationSequel)
+	prequel := relationSchema("relation-2", "anime-b", "anime-a", RelationPrequel)
+
+	t.Run("Upsert", func(t *testing.T) {
+		processor, repository := setup(Options{})
+
+		process(t, processor, nil, sequel)
+		process(t, processor, sequel, sequel)
+
+		assert.Equal(t, []string{"anime-a>anime-b:sequel"}, repository.edges())
+		assert.Contains(t, repository.relations, "relation-1")
+	})
+
+	t.Run("UpsertWritesInverse", func(t *testing.T) {
+		processor, repository := setup(Options{MaintainInverse: true})
+
+		process(t, processor, nil, sequel)
+		process(t, processor, sequel, sequel)
+
+		assert.Equal(t, []string{"anime-a>anime-b:sequel", "anime-b>anime-a:prequel"}, repository.edges())
+	})
+
+	t.Run("ReverseEdgeReplacesInverse", func(t *testing.T) {
+		for name, order := range map[string][]*Schema{"ReverseLast": {sequel, prequel}, "ReverseFirst": {prequel, sequel}} {
+			t.Run(name, func(t *testing.T) {
+				processor, repository := setup(Options{MaintainInverse: true})
+
+				for _, relation := range order {
+					process(t, processor, nil, relation)
+				}
+				process(t, processor, sequel, sequel)
+
+				assert.Equal(t, []string{"anime-a>anime-b:sequel", "anime-b>anime-a:prequel"}, repository.edges())
+				assert.Contains(t, repository.relations, "relation-1")
+				assert.Contains(t, repository.relations, "relation-2")
+			})
+		}
+	})
+
+	t.Run("DeleteRemovesInverse", func(t *testing.T) {
+		processor, repository := setup(Options{MaintainInverse: true})
+
+		process(t, processor, nil, sequel)
+		process(t, processor, sequel, nil)
+
+		assert.Empty(t, repository.edges())
+	})
+
+	t.Run("DeleteRestoresInverseOfReverseEdge", func(t *testing.T) {
+		processor, repository := setup(Options{MaintainInverse: true})
+
+		process(t, processor, nil, sequel)
+		process(t, processor, nil, prequel)
+		process(t, processor, sequel, nil)
+
+		assert.Equal(t, []string{"anime-a>anime-b:sequel", "anime-b>anime-a:prequel"}, repository.edges())
+		assert.NotContains(t, repository.relations, "relation-1")
+		assert.Contains(t, repository.relations, "relation-2")
+	})
+
+	t.Run("TypeChangeReplacesInverse", func(t *testing.T) {
+		processor, repository := setup(Options{MaintainInverse: true})
+		spinOff := relationSchema("relation-1", "anime-a", "anime-b", RelationSpinOff)
+
+		process(t, processor, nil, sequel)
+		process(t, processor, sequel, spinOff)
+
+		assert.Equal(t, []string{"anime-a>anime-b:spin_off", "anime-b>anime-a:parent_story"}, repository.edges())
+	})
+
+	t.Run("TypeChangeWithoutInverse", func(t *testing.T) {
+		processor, repository := setup(Options{MaintainInverse: true})
+		adaptation := relationSchema("relation-1", "anime-a", "anime-b", "adaptation")
+
+		process(t, processor, nil, sequel)
+		process(t, processor, sequel, adaptation)
+
+		assert.Equal(t, []string{"anime-a>anime-b:adaptation"}, repository.edges())
+	})
+
+	t.Run("TypeChangeRestoresInverseOfReverseEdge", func(t *testing.T) {
+		processor, repository := setup(Options{MaintainInverse: true})
+		other := relationSchema("relation-1", "anime-a", "anime-b", RelationOther)
+
+		process(t, processor, nil, sequel)
+		process(t, processor, nil, prequel)
+		process(t, processor, sequel, other)
+
+		assert.Equal(t, []string{"anime-a>anime-b:other", "anime-a>anime-b:sequel", "anime-b>anime-a:other", "anime-b>anime-a:prequel"}, repository.edges())
+	})
+}
+
+func TestInverseOf(t *testing.T) {
+	t.Run("SequelProducesPrequel", func(t *testing.T) {
+		relationType := RelationSequel
+		relation := &anime_relation.AnimeRelation{
+			ID:             "relation-1",
+			AnimeID:        "anime-a",
+			RelatedAnimeID: "anime-b",
+			RelationType:   &relationType,
+		}
+
+		inverse := InverseOf(relation)
+		require.NotNil(t, inverse)
+		assert.Equal(t, "anime-b", inverse.AnimeID)
+		assert.Equal(t, "anime-a", inverse.RelatedAnimeID)
+		assert.Equal(t, RelationPrequel, *inverse.RelationType)
+		assert.Len(t, inverse.ID, 36)
+		assert.NotEqual(t, relation.ID, inverse.ID)
+		assert.Equal(t, inverse.ID, InverseOf(relation).ID, "inverse ID should be stable across replays")
+	})
+
+	t.Run("SymmetricRelation", func(t *testing.T) {
+		relationType := "Alternative_Version"
+		inverse := InverseOf(&anime_relation.AnimeRelation{ID: "relation-2", RelationType: &relationType})
+		require.NotNil(t, inverse)
+		assert.Equal(t, RelationAlternativeVersion, *inverse.RelationType)
+	})
+
+	t.Run("UnknownOrMissingType", func(t *testing.T) {
+		relationType := "adaptation"
+		assert.Nil(t, InverseOf(&anime_relation.AnimeRelation{ID: "relation-3", RelationType: &relationType}))
+		assert.Nil(t, InverseOf(&anime_relation.AnimeRelation{ID: "relation-4"}))
+	})
+}
@@ -0,0 +1,46 @@
+package anime_relation_processor
+
+type RelationType = string
+
+const (
+	RelationSequel             RelationType = "sequel"
+	RelationPrequel            RelationType = "prequel"
+	RelationSideStory          RelationType = "side_story"
+	RelationParentStory        RelationType = "parent_story"
+	RelationSummary            RelationType = "summary"
+	RelationFullStory          RelationType = "full_story"
+	RelationSpinOff            RelationType = "spin_off"
+	RelationAlternativeVersion RelationType = "alternative_version"
+	RelationAlternativeSetting RelationType = "alternative_setting"
+	RelationCharacter          RelationType = "character"
+	RelationOther              RelationType = "other"
+)
+
+type Schema struct {
+	ID             string  `json:"id"`
+	AnimeID        string  `json:"anime_id"`
+	RelatedAnimeID string  `json:"related_anime_id"`
+	RelationType   *string `json:"relation_type"`
+	CreatedAt      *int64  `json:"created_at"`
+}
+
+type Source struct {
+	Version   string      `json:"version"`
+	Connector string      `json:"connector"`
+	Name      string      `json:"name"`
+	TsMs      int64       `json:"ts_ms"`
+	Snapshot  string      `json:"snapshot"`
+	Db        string      `json:"db"`
+	Sequence  string      `json:"sequence"`
+	Schema    string      `json:"schema"`
+	Table     string      `json:"table"`
+	TxId      int         `json:"txId"`
+	Lsn       int         `json:"lsn"`
+	Xmin      interface{} `json:"xmin"`
+}
+
+type Payload struct {
+	Before *Schema `json:"before"`
+	After  *Schema `json:"after"`
+	Source Source  `json:"source"`
+}
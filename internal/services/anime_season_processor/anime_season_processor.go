@@ -2,18 +2,28 @@ package anime_season_processor
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/ThatCatDev/ep/v2/event"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/changes"
 	"github.com/weeb-vip/anime-sync/internal/db"
 	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_season"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
 	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
 	"go.uber.org/zap"
 	"time"
 )
 
+// positionEntity is the entity name season rows are recorded under in sync_positions
+const positionEntity = "anime_season"
+
+// diffIgnoredFields are left out when comparing the before and after of an update, they change on every write
+var diffIgnoredFields = []string{"created_at", "updated_at"}
+
 type Options struct {
 	NoErrorOnDelete bool
+	// ForceReplay applies events even when a newer one was already applied to the row, e.g. during backfills
+	ForceReplay bool
 }
 
 type AnimeSeasonProcessor interface {
@@ -21,16 +31,20 @@ type AnimeSeasonProcessor interface {
 }
 
 type AnimeSeasonProcessorImpl struct {
-	Repository      anime_season.AnimeSeasonRepositoryImpl
-	Options         Options
-	AlgoliaProducer func(ctx context.Context, message *kafka.Message) error
+	Transactor         db.Transactor
+	Repository         anime_season.AnimeSeasonRepositoryImpl
+	PositionRepository sync_position.SyncPositionRepositoryImpl
+	Options            Options
+	Search             publisher.Publisher[ProducerPayload]
 }
 
-func NewAnimeSeasonProcessor(opt Options, db *db.DB, algoliaProducer func(ctx context.Context, message *kafka.Message) error) AnimeSeasonProcessor {
+func NewAnimeSeasonProcessor(opt Options, db *db.DB, search publisher.Publisher[ProducerPayload]) AnimeSeasonProcessor {
 	return &AnimeSeasonProcessorImpl{
-		Repository:      anime_season.NewAnimeSeasonRepository(db),
-		Options:         opt,
-		AlgoliaProducer: algoliaProducer,
+		Transactor:         db,
+		Repository:         anime_season.NewAnimeSeasonRepository(db),
+		PositionRepository: sync_position.NewSyncPositionRepository(db),
+		Options:            opt,
+		Search:             search,
 	}
 }
 
@@ -47,25 +61,8 @@ func (p *AnimeSeasonProcessorImpl) Process(ctx context.Context, data event.Event
 		if err != nil {
 			return data, err
 		}
-		err = p.Repository.Upsert(newAnimeSeason)
-		if err != nil {
-			return data, err
-		}
-
-		jsonAnimeSeason, err := json.Marshal(ProducerPayload{
-			Action: CreateAction,
-			Data:   payload.After,
-		})
-		if err != nil {
-			log.Error("Error marshalling payload", zap.Error(err))
-			return data, err
-		}
-
-		err = p.AlgoliaProducer(ctx, &kafka.Message{
-			Value: jsonAnimeSeason,
-		})
+		err = p.save(ctx, newAnimeSeason, CreateAction, payload.After, payload.Source, nil)
 		if err != nil {
-			log.Error("Error sending message to algolia producer", zap.Error(err))
 			return data, err
 		}
 	}
@@ -77,9 +74,24 @@ func (p *AnimeSeasonProcessorImpl) Process(ctx context.Context, data event.Event
 			return data, err
 		}
 
-		err = p.Repository.Delete(oldAnimeSeason)
+		var deleteErr error
+		err = p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			stale, err := p.isStale(ctx, tx, oldAnimeSeason.ID, payload.Source)
+			if err != nil || stale {
+				return err
+			}
+
+			// the recorded position is kept so older events can't bring the row back
+			deleteErr = p.Repository.WithTx(tx).Delete(oldAnimeSeason)
+			if deleteErr != nil {
+				return deleteErr
+			}
+
+			return p.sendSearchDocument(ctx, DeleteAction, payload.Before)
+		})
 		if err != nil {
-			if p.Options.NoErrorOnDelete {
+			// only db errors are ignored, a failed algolia delete has to be retried
+			if deleteErr != nil && p.Options.NoErrorOnDelete {
 				log.Warn("WARN: error deleting from db: ", zap.Error(err))
 				return data, nil
 			} else {
@@ -96,32 +108,87 @@ func (p *AnimeSeasonProcessorImpl) Process(ctx context.Context, data event.Event
 			return data, err
 		}
 
-		err = p.Repository.Upsert(newAnimeSeason)
-		if err != nil {
-			return data, err
+		changed := changes.Diff(payload.Before, payload.After, diffIgnoredFields...)
+		if changed.Empty() {
+			log.Info("Update changed no fields, skipping writes and publishing", zap.String("id", newAnimeSeason.ID))
 		}
 
-		jsonAnimeSeason, err := json.Marshal(ProducerPayload{
-			Action: UpdateAction,
-			Data:   payload.After,
-		})
+		err = p.save(ctx, newAnimeSeason, UpdateAction, payload.After, payload.Source, changed)
 		if err != nil {
 			return data, err
 		}
+	}
 
-		err = p.AlgoliaProducer(ctx, &kafka.Message{
-			Value: jsonAnimeSeason,
-		})
+	return data, nil
+}
+
+// save upserts the season and publishes it to algolia in one transaction,
+// nothing is written or published when a newer event was already applied. For updates changed holds
+// the changed fields, only those columns are written and nothing is published when none changed.
+// It is nil for creates
+func (p *AnimeSeasonProcessorImpl) save(ctx context.Context, animeSeason *anime_season.AnimeSeason, action Action, data *Schema, source Source, changed changes.Set) error {
+	return p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		stale, err := p.isStale(ctx, tx, animeSeason.ID, source)
+		if err != nil || stale {
+			return err
+		}
+
+		if changed == nil {
+			err = p.Repository.WithTx(tx).Upsert(animeSeason)
+		} else if !changed.Empty() {
+			err = p.Repository.WithTx(tx).UpdateColumns(animeSeason, changed.Columns())
+		} else {
+			return nil
+		}
 		if err != nil {
-			return data, err
+			return err
 		}
+
+		return p.sendSearchDocument(ctx, action, data)
+	})
+}
+
+// sendSearchDocument publishes the action for the season to the algolia topic
+func (p *AnimeSeasonProcessorImpl) sendSearchDocument(ctx context.Context, action Action, data *Schema) error {
+	log := logger.FromCtx(ctx)
+
+	err := p.Search.Publish(ctx, SearchMessage(action, data))
+	if err != nil {
+		log.Error("Error publishing search document", zap.Error(err))
+		return err
 	}
 
-	if payload.Before != nil && payload.After == nil {
-		log.Warn("WARN: payload.After is nil, skipping update")
+	return nil
+}
+
+// SearchMessage returns the message publishing action for the search document of a season
+func SearchMessage(action Action, document *Schema) publisher.Message[ProducerPayload] {
+	return publisher.Message[ProducerPayload]{
+		Key:           document.ID,
+		Action:        action,
+		Entity:        EntityAnimeSeason,
+		SchemaVersion: SchemaVersion,
+		Value:         ProducerPayload{Action: action, Data: document},
 	}
+}
 
-	return data, nil
+// isStale records the source position of the event for the row and reports whether it has to be
+// skipped because a newer event was already applied
+func (p *AnimeSeasonProcessorImpl) isStale(ctx context.Context, tx *db.DB, id string, source Source) (bool, error) {
+	position := &sync_position.SyncPosition{
+		Entity:   positionEntity,
+		EntityID: id,
+		Lsn:      int64(source.Lsn),
+		TsMs:     source.TsMs,
+		TxID:     int64(source.TxId),
+	}
+	stale, err := p.PositionRepository.WithTx(tx).Check(position, p.Options.ForceReplay)
+	if err != nil || !stale {
+		return false, err
+	}
+
+	logger.FromCtx(ctx).Warn("Skipping stale season event, a newer one was already applied", zap.String("id", id), zap.Int64("lsn", position.Lsn), zap.Int64("tsMs", position.TsMs))
+	return true, nil
 }
 
 func (p *AnimeSeasonProcessorImpl) parseToEntity(ctx context.Context, data Schema) (*anime_season.AnimeSeason, error) {
@@ -138,3 +205,24 @@ func (p *AnimeSeasonProcessorImpl) parseToEntity(ctx context.Context, data Schem
 
 	return &newAnimeSeason, nil
 }
+
+// SchemaFromEntity rebuilds the search document of a stored season the way its change event carries it
+func SchemaFromEntity(entity *anime_season.AnimeSeason) *Schema {
+	schema := &Schema{
+		ID:           entity.ID,
+		Season:       entity.Season,
+		Status:       string(entity.Status),
+		EpisodeCount: entity.EpisodeCount,
+		Notes:        entity.Notes,
+		AnimeID:      entity.AnimeID,
+	}
+	if !entity.CreatedAt.IsZero() {
+		createdAt := entity.CreatedAt.UnixMicro()
+		schema.CreatedAt = &createdAt
+	}
+	if !entity.UpdatedAt.IsZero() {
+		updatedAt := entity.UpdatedAt.UnixMicro()
+		schema.UpdatedAt = &updatedAt
+	}
+	return schema
+}
@@ -0,0 +1,149 @@
+package anime_season_processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_season"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor/synctest"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transaction(ctx context.Context, fn func(ctx context.Context, tx *db.DB) error) error {
+	return fn(ctx, nil)
+}
+
+type fakeSeasonRepository struct {
+	seasons map[string]*anime_season.AnimeSeason
+	// updatedColumns records the columns of every UpdateColumns call
+	updatedColumns [][]string
+}
+
+func (r *fakeSeasonRepository) Upsert(animeSeason *anime_season.AnimeSeason) error {
+	r.seasons[animeSeason.ID] = animeSeason
+	return nil
+}
+
+func (r *fakeSeasonRepository) UpdateColumns(animeSeason *anime_season.AnimeSeason, columns []string) error {
+	r.updatedColumns = append(r.updatedColumns, columns)
+	r.seasons[animeSeason.ID] = animeSeason
+	return nil
+}
+
+func (r *fakeSeasonRepository) Delete(animeSeason *anime_season.AnimeSeason) error {
+	delete(r.seasons, animeSeason.ID)
+	return nil
+}
+
+func (r *fakeSeasonRepository) FindPage(filter anime_season.Filter, afterID string, limit int) ([]anime_season.AnimeSeason, error) {
+	return nil, nil
+}
+
+func (r *fakeSeasonRepository) Count(filter anime_season.Filter) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeSeasonRepository) WithTx(tx *db.DB) anime_season.AnimeSeasonRepositoryImpl {
+	return r
+}
+
+func TestAlgoliaActions(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	animeID := "season-test-anime"
+	before := &Schema{ID: "season-test", Season: "SPRING_2024", Status: "CONFIRMED", AnimeID: &animeID}
+	after := &Schema{ID: "season-test", Season: "SUMMER_2024", Status: "CONFIRMED", AnimeID: &animeID}
+
+	tests := []struct {
+		name    string
+		payload Payload
+		action  Action
+		season  string
+		stored  bool
+	}{
+		{"Create", Payload{After: before}, CreateAction, "SPRING_2024", true},
+		{"Update", Payload{Before: before, After: after}, UpdateAction, "SUMMER_2024", true},
+		{"Delete", Payload{Before: before}, DeleteAction, "SPRING_2024", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeSeasonRepository{seasons: map[string]*anime_season.AnimeSeason{
+				"season-test": {ID: "season-test"},
+			}}
+			search := &publisher.Memory[ProducerPayload]{}
+
+			processor := &AnimeSeasonProcessorImpl{
+				Transactor:         fakeTransactor{},
+				Repository:         repository,
+				PositionRepository: &synctest.PositionRepository{},
+				Search:             search,
+			}
+
+			_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: tt.payload})
+			require.NoError(t, err)
+
+			messages := search.Messages()
+			require.Len(t, messages, 1)
+			assert.Equal(t, "season-test", messages[0].Key)
+			assert.Equal(t, tt.action, messages[0].Action)
+			assert.Equal(t, EntityAnimeSeason, messages[0].Entity)
+
+			produced := messages[0].Value
+			assert.Equal(t, tt.action, produced.Action)
+			require.NotNil(t, produced.Data)
+			assert.Equal(t, "season-test", produced.Data.ID)
+			assert.Equal(t, tt.season, produced.Data.Season)
+			assert.Equal(t, tt.stored, repository.seasons["season-test"] != nil)
+		})
+	}
+}
+
+func TestUpdatesOnlyApplyChangedFields(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	updatedAt := int64(1)
+	touchedAt := int64(2)
+	episodes := 12
+	before := &Schema{ID: "season-test", Season: "SPRING_2024", Status: "CONFIRMED", UpdatedAt: &updatedAt}
+
+	tests := []struct {
+		name          string
+		after         *Schema
+		columns       [][]string
+		searchUpdates int
+	}{
+		{"OnlyTimestamps", &Schema{ID: "season-test", Season: "SPRING_2024", Status: "CONFIRMED", UpdatedAt: &touchedAt}, nil, 0},
+		{"EpisodeCount", &Schema{ID: "season-test", Season: "SPRING_2024", Status: "CONFIRMED", EpisodeCount: &episodes}, [][]string{{"episode_count"}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeSeasonRepository{seasons: map[string]*anime_season.AnimeSeason{}}
+			search := &publisher.Memory[ProducerPayload]{}
+
+			processor := &AnimeSeasonProcessorImpl{
+				Transactor:         fakeTransactor{},
+				Repository:         repository,
+				PositionRepository: &synctest.PositionRepository{},
+				Search:             search,
+			}
+
+			_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: Payload{Before: before, After: tt.after}})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.columns, repository.updatedColumns)
+			assert.Len(t, search.Messages(), tt.searchUpdates)
+		})
+	}
+}
@@ -8,6 +8,13 @@ const (
 	DeleteAction Action = "delete"
 )
 
+// EntityAnimeSeason is the entity header of season search documents
+const EntityAnimeSeason = "anime_season"
+
+// SchemaVersion is sent with season search documents, bump it when their JSON changes in a way
+// consumers have to handle
+const SchemaVersion = "1"
+
 type Schema struct {
 	ID           string  `json:"id"`
 	Season       string  `json:"season"`
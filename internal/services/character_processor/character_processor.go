@@ -0,0 +1,148 @@
+package character_processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	NoErrorOnDelete bool
+}
+
+type CharacterProcessor interface {
+	Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error)
+}
+
+type CharacterProcessorImpl struct {
+	Transactor db.Transactor
+	Repository anime_character.AnimeCharacterRepositoryImpl
+	Options    Options
+	Images     publisher.Publisher[anime_processor.ImagePayload]
+}
+
+func NewCharacterProcessor(opt Options, db *db.DB, images publisher.Publisher[anime_processor.ImagePayload]) CharacterProcessor {
+	return &CharacterProcessorImpl{
+		Transactor: db,
+		Repository: anime_character.NewAnimeCharacterRepository(db),
+		Options:    opt,
+		Images:     images,
+	}
+}
+
+func (p *CharacterProcessorImpl) Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error) {
+	log := logger.FromCtx(ctx)
+
+	payload := data.Payload
+
+	log.Debug("Payload", zap.Any("payload", payload))
+
+	if payload.Before == nil && payload.After != nil {
+		// add to db
+		newCharacter, err := p.parseToEntity(ctx, *payload.After)
+		if err != nil {
+			return data, err
+		}
+		err = p.save(ctx, newCharacter, anime_processor.CreateAction, *payload.After)
+		if err != nil {
+			return data, err
+		}
+	}
+
+	if payload.After == nil && payload.Before != nil {
+		// delete from db
+		oldCharacter, err := p.parseToEntity(ctx, *payload.Before)
+		if err != nil {
+			return data, err
+		}
+
+		err = p.Repository.Delete(oldCharacter)
+		if err != nil {
+			if p.Options.NoErrorOnDelete {
+				log.Warn("WARN: error deleting from db: ", zap.Error(err))
+				return data, nil
+			} else {
+				return data, err
+			}
+		}
+		return data, nil
+	}
+
+	if payload.Before != nil && payload.After != nil {
+		// update db
+		newCharacter, err := p.parseToEntity(ctx, *payload.After)
+		if err != nil {
+			return data, err
+		}
+		err = p.save(ctx, newCharacter, anime_processor.UpdateAction, *payload.After)
+		if err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+// save upserts the character and publishes its image in one transaction
+func (p *CharacterProcessorImpl) save(ctx context.Context, character *anime_character.AnimeCharacter, action anime_processor.Action, data Schema) error {
+	return p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		err := p.Repository.WithTx(tx).Upsert(character)
+		if err != nil {
+			return err
+		}
+
+		return p.sendImage(ctx, action, data)
+	})
+}
+
+// sendImage forwards the character image to the image sync topic
+func (p *CharacterProcessorImpl) sendImage(ctx context.Context, action anime_processor.Action, data Schema) error {
+	log := logger.FromCtx(ctx)
+
+	if data.Image == nil || *data.Image == "" {
+		log.Warn("Image is nil, skipping image producer", zap.String("id", data.ID))
+		return nil
+	}
+
+	image, _ := anime_processor.NewImagePayload(anime_processor.DataTypeCharacter, data.ID, data.Image, data.Name)
+
+	log.Info("Sending character image to image sync", zap.String("name", image.Data.Name), zap.String("imageURL", *data.Image))
+	err := p.Images.Publish(ctx, anime_processor.ImageMessage(action, data.ID, image))
+	if err != nil {
+		log.Error("Error publishing image", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (p *CharacterProcessorImpl) parseToEntity(ctx context.Context, data Schema) (*anime_character.AnimeCharacter, error) {
+	var newCharacter anime_character.AnimeCharacter
+
+	newCharacter.ID = data.ID
+	newCharacter.AnimeID = data.AnimeID
+	newCharacter.Name = data.Name
+	newCharacter.Role = data.Role
+	newCharacter.Birthday = data.Birthday
+	newCharacter.Zodiac = data.Zodiac
+	newCharacter.Gender = data.Gender
+	newCharacter.Race = data.Race
+	newCharacter.Height = data.Height
+	newCharacter.Weight = data.Weight
+	newCharacter.Title = data.Title
+	newCharacter.MartialStatus = data.MartialStatus
+	newCharacter.Summary = data.Summary
+	newCharacter.Image = data.Image
+	newCharacter.CreatedAt = time.Now()
+	newCharacter.UpdatedAt = time.Now()
+
+	return &newCharacter, nil
+}
@@ -0,0 +1,124 @@
+package character_processor_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/character_processor"
+	"github.com/weeb-vip/anime-sync/internal/slug"
+)
+
+// TestCharacterProcessorWorkflow tests create, update and delete of characters against the database
+func TestCharacterProcessorWorkflow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	cfg := &config.DBConfig{
+		Host:     "localhost",
+		Port:     3306,
+		User:     "weeb",
+		Password: "mysecretpassword",
+		DataBase: "weeb",
+		SSLMode:  "false",
+	}
+
+	database := db.NewDB(*cfg)
+	require.NotNil(t, database)
+
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	err = sqlDB.Ping()
+	require.NoError(t, err, "Database should be accessible")
+
+	cleanup := func() {
+		database.DB.Where("id LIKE ?", "char-proc-%").Delete(&anime_character.AnimeCharacter{})
+	}
+	cleanup()
+	defer cleanup()
+
+	var imageMessages []*kafka.Message
+	imageProducer := func(ctx context.Context, message *kafka.Message) error {
+		imageMessages = append(imageMessages, message)
+		return nil
+	}
+
+	processor := character_processor.NewCharacterProcessor(character_processor.Options{NoErrorOnDelete: false}, database, publisher.NewKafka[anime_processor.ImagePayload](imageProducer))
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	image := "https://example.com/char.jpg"
+	created := &character_processor.Schema{
+		ID:      "char-proc-001",
+		AnimeID: "char-proc-anime",
+		Name:    "Spike Spiegel",
+		Role:    "Main",
+		Image:   &image,
+	}
+
+	t.Run("CreateCharacterSendsImage", func(t *testing.T) {
+		imageMessages = nil
+
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, character_processor.Payload]{
+			Payload: character_processor.Payload{After: created},
+		})
+		require.NoError(t, err)
+
+		var saved anime_character.AnimeCharacter
+		err = database.DB.Where("id = ?", "char-proc-001").First(&saved).Error
+		require.NoError(t, err)
+		assert.Equal(t, "Spike Spiegel", saved.Name)
+		assert.Equal(t, "Main", saved.Role)
+
+		require.Len(t, imageMessages, 1)
+		assert.Equal(t, "char-proc-001", string(imageMessages[0].Key))
+		var imagePayload anime_processor.ImagePayload
+		require.NoError(t, json.Unmarshal(imageMessages[0].Value, &imagePayload))
+		assert.Equal(t, anime_processor.DataTypeCharacter, imagePayload.Data.Type)
+		assert.Equal(t, slug.ImageName("char-proc-001", "Spike Spiegel"), imagePayload.Data.Name)
+		assert.Equal(t, "spike_spiegel", imagePayload.Data.LegacyName)
+		assert.Equal(t, image, imagePayload.Data.URL)
+	})
+
+	t.Run("UpdateCharacterWithoutImage", func(t *testing.T) {
+		imageMessages = nil
+
+		updated := *created
+		updated.Role = "Supporting"
+		updated.Image = nil
+
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, character_processor.Payload]{
+			Payload: character_processor.Payload{Before: created, After: &updated},
+		})
+		require.NoError(t, err)
+
+		var saved anime_character.AnimeCharacter
+		err = database.DB.Where("id = ?", "char-proc-001").First(&saved).Error
+		require.NoError(t, err)
+		assert.Equal(t, "Supporting", saved.Role)
+		assert.Len(t, imageMessages, 0, "Image producer should not be called without an image")
+	})
+
+	t.Run("DeleteCharacter", func(t *testing.T) {
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, character_processor.Payload]{
+			Payload: character_processor.Payload{Before: created},
+		})
+		require.NoError(t, err)
+
+		var deleted anime_character.AnimeCharacter
+		err = database.DB.Where("id = ?", "char-proc-001").First(&deleted).Error
+		assert.Error(t, err, "Character should be deleted from database")
+	})
+}
@@ -0,0 +1,95 @@
+package character_staff_link_processor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character_staff_link"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	NoErrorOnDelete bool
+}
+
+type CharacterStaffLinkProcessor interface {
+	Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error)
+}
+
+type CharacterStaffLinkProcessorImpl struct {
+	Repository anime_character_staff_link.AnimeCharacterStaffLinkRepositoryImpl
+	Options    Options
+}
+
+func NewCharacterStaffLinkProcessor(opt Options, db *db.DB) CharacterStaffLinkProcessor {
+	return &CharacterStaffLinkProcessorImpl{
+		Repository: anime_character_staff_link.NewAnimeCharacterStaffLinkRepository(db),
+		Options:    opt,
+	}
+}
+
+func (p *CharacterStaffLinkProcessorImpl) Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error) {
+	log := logger.FromCtx(ctx)
+
+	payload := data.Payload
+
+	log.Debug("Payload", zap.Any("payload", payload))
+
+	if payload.After != nil {
+		// add or update db
+		newLink, err := p.parseToEntity(ctx, *payload.After)
+		if err != nil {
+			return data, err
+		}
+		err = p.Repository.Upsert(newLink)
+		if err != nil {
+			if errors.Is(err, anime_character_staff_link.ErrMissingReference) {
+				// the character or staff event has not been applied yet, returning the error
+				// hands the message to the backoff retry middleware so it is re-queued
+				log.Warn("Link references missing character or staff, deferring", zap.String("id", newLink.ID), zap.Error(err))
+			}
+			return data, err
+		}
+		return data, nil
+	}
+
+	if payload.Before != nil {
+		// delete from db
+		oldLink, err := p.parseToEntity(ctx, *payload.Before)
+		if err != nil {
+			return data, err
+		}
+
+		err = p.Repository.Delete(oldLink)
+		if err != nil {
+			if p.Options.NoErrorOnDelete {
+				log.Warn("WARN: error deleting from db: ", zap.Error(err))
+				return data, nil
+			} else {
+				return data, err
+			}
+		}
+	}
+
+	return data, nil
+}
+
+func (p *CharacterStaffLinkProcessorImpl) parseToEntity(ctx context.Context, data Schema) (*anime_character_staff_link.AnimeCharacterStaffLink, error) {
+	var newLink anime_character_staff_link.AnimeCharacterStaffLink
+
+	newLink.ID = data.ID
+	newLink.CharacterID = data.CharacterID
+	newLink.StaffID = data.StaffID
+	newLink.CharacterName = data.CharacterName
+	newLink.StaffGivenName = data.StaffGivenName
+	newLink.StaffFamilyName = data.StaffFamilyName
+	newLink.CreatedAt = time.Now()
+	newLink.UpdatedAt = time.Now()
+
+	return &newLink, nil
+}
@@ -0,0 +1,94 @@
+package character_staff_link_processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character_staff_link"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+)
+
+// fakeLinkRepository fails like MySQL does for links whose character or staff row is missing
+type fakeLinkRepository struct {
+	characters map[string]bool
+	staff      map[string]bool
+	links      map[string]*anime_character_staff_link.AnimeCharacterStaffLink
+	deleteErr  error
+}
+
+func (r *fakeLinkRepository) Upsert(link *anime_character_staff_link.AnimeCharacterStaffLink) error {
+	if !r.characters[link.CharacterID] || !r.staff[link.StaffID] {
+		return fmt.Errorf("%w: %w", anime_character_staff_link.ErrMissingReference, &mysql.MySQLError{Number: 1452, Message: "foreign key constraint fails"})
+	}
+	r.links[link.ID] = link
+	return nil
+}
+
+func (r *fakeLinkRepository) Delete(link *anime_character_staff_link.AnimeCharacterStaffLink) error {
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	delete(r.links, link.ID)
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	link := &Schema{ID: "link-1", CharacterID: "c1", StaffID: "s1", CharacterName: "Spike Spiegel"}
+
+	newProcessor := func(opt Options) (*CharacterStaffLinkProcessorImpl, *fakeLinkRepository) {
+		repository := &fakeLinkRepository{
+			characters: map[string]bool{},
+			staff:      map[string]bool{"s1": true},
+			links:      map[string]*anime_character_staff_link.AnimeCharacterStaffLink{},
+		}
+		return &CharacterStaffLinkProcessorImpl{Repository: repository, Options: opt}, repository
+	}
+	process := func(processor *CharacterStaffLinkProcessorImpl, payload Payload) error {
+		_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: payload})
+		return err
+	}
+
+	t.Run("OrphanLinkIsRetriedUntilItsCharacterArrives", func(t *testing.T) {
+		processor, repository := newProcessor(Options{})
+
+		err := process(processor, Payload{After: link})
+		require.Error(t, err)
+		assert.ErrorIs(t, err, anime_character_staff_link.ErrMissingReference)
+		assert.Equal(t, retryable.Retriable, retryable.Classify(err), "the retry middleware has to re-queue the link")
+		assert.Empty(t, repository.links)
+
+		repository.characters["c1"] = true
+		require.NoError(t, process(processor, Payload{After: link}))
+		require.Contains(t, repository.links, "link-1")
+		assert.Equal(t, "Spike Spiegel", repository.links["link-1"].CharacterName)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		processor, repository := newProcessor(Options{})
+		repository.characters["c1"] = true
+		require.NoError(t, process(processor, Payload{After: link}))
+
+		require.NoError(t, process(processor, Payload{Before: link}))
+		assert.Empty(t, repository.links)
+	})
+
+	t.Run("DeleteErrors", func(t *testing.T) {
+		processor, repository := newProcessor(Options{})
+		repository.deleteErr = errors.New("connection refused")
+		require.Error(t, process(processor, Payload{Before: link}))
+
+		processor.Options.NoErrorOnDelete = true
+		require.NoError(t, process(processor, Payload{Before: link}))
+	})
+}
@@ -2,74 +2,254 @@ package consumer
 
 import (
 	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/weeb-vip/anime-sync/config"
 	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"github.com/weeb-vip/anime-sync/internal/workerpool"
 	"go.uber.org/zap"
-	"time"
 )
 
 type Consumer[T any] interface {
 	Receive(ctx context.Context, process func(ctx context.Context, msg pulsar.Message) error) error
+	Close()
 }
 
 type ConsumerImpl[T any] struct {
 	client   pulsar.Client
 	consumer *pulsar.Consumer
 	config   config.PulsarConfig
+	policy   config.RetryPolicy
+	workers  int
 }
 
-func NewConsumer[T any](ctx context.Context, cfg config.PulsarConfig) Consumer[T] {
-	log := logger.FromCtx(ctx)
+// NewConsumer creates a consumer that processes messages of different keys on up to workers goroutines.
+// Messages delivered policy.MaxRetries times without going through are moved to the dead letter topic
+// of the policy
+func NewConsumer[T any](ctx context.Context, cfg config.PulsarConfig, workers int, policy config.RetryPolicy) (Consumer[T], error) {
 	client, err := pulsar.NewClient(pulsar.ClientOptions{
 		URL: cfg.URL,
 	})
-
 	if err != nil {
-		log.Fatal("Error creating pulsar client: ", zap.String("error", err.Error()))
-		return nil
+		return nil, err
 	}
 
 	return &ConsumerImpl[T]{
-		config: cfg,
-		client: client,
+		config:  cfg,
+		client:  client,
+		policy:  policy,
+		workers: workers,
+	}, nil
+}
+
+// consumerOptions subscribes to the configured topic. Every delivery runs the in process retries of
+// the processor, so the deliveries are capped by the retry policy as well. Held back messages are
+// nacked too and count as deliveries, see failedKeys
+func consumerOptions(cfg config.PulsarConfig, policy config.RetryPolicy) pulsar.ConsumerOptions {
+	options := pulsar.ConsumerOptions{
+		Topic:               cfg.Topic,
+		SubscriptionName:    cfg.SubscribtionName,
+		Type:                pulsar.Shared,
+		NackRedeliveryDelay: cfg.NackRedeliveryDelay(),
 	}
+	if policy.MaxRetries > 0 {
+		options.DLQ = &pulsar.DLQPolicy{
+			MaxDeliveries:   uint32(policy.MaxRetries),
+			DeadLetterTopic: policy.DeadLetterTopic(cfg.Topic),
+		}
+	}
+	return options
 }
 
+// Receive processes messages until ctx is cancelled. Messages with the same key are processed in
+// order on one worker, messages of different keys concurrently. Cancelling ctx stops waiting for the
+// next message, the messages being processed are finished and acked first while queued ones are
+// left for redelivery. Failed messages are nacked and redelivered after the nack redelivery delay,
+// later messages of their key are nacked without processing them until the failed one went through,
+// see failedKeys. Messages failing with a permanent error are logged and acked, redelivering them
+// can't help
 func (c *ConsumerImpl[T]) Receive(ctx context.Context, process func(ctx context.Context, msg pulsar.Message) error) error {
 	log := logger.FromCtx(ctx)
 	if c.consumer != nil {
 		return nil
 	}
 
-	consumer, err := c.client.Subscribe(pulsar.ConsumerOptions{
-		Topic:            c.config.Topic,
-		SubscriptionName: c.config.SubscribtionName,
-		Type:             pulsar.Shared,
-	})
-
+	consumer, err := c.client.Subscribe(consumerOptions(c.config, c.policy))
 	if err != nil {
-		log.Fatal("Error creating pulsar consumer: ", zap.String("error", err.Error()))
+		log.Error("Error creating pulsar consumer: ", zap.String("error", err.Error()))
 		return err
 	}
 
 	defer consumer.Close()
 
+	pool := workerpool.New(c.workers)
+	defer pool.Close()
+
+	failed := newFailedKeys(holdFactor * c.config.NackRedeliveryDelay())
+	ackErrs := make(chan error, 1)
 	for {
 		msg, err := consumer.Receive(ctx)
 		if err != nil {
-			log.Fatal("Error receiving message: ", zap.String("error", err.Error()))
+			if ctx.Err() != nil {
+				log.Info("Stopping pulsar consumer")
+				return nil
+			}
+			log.Error("Error receiving message: ", zap.String("error", err.Error()))
+			return err
+		}
+
+		select {
+		case err := <-ackErrs:
+			return err
+		default:
 		}
 
 		log.Info("Received message", zap.String("msgId", msg.ID().String()))
 
-		err = process(ctx, msg)
+		err = pool.Submit(ctx, MessageKey(msg), func() {
+			if ctx.Err() != nil {
+				return
+			}
+			err := handle(ctx, consumer, failed, msg, process)
+			if err != nil {
+				log.Error("Error acking message: ", zap.String("error", err.Error()))
+				select {
+				case ackErrs <- err:
+				default:
+				}
+			}
+		})
 		if err != nil {
-			log.Warn("error processing message: ", zap.String("error", err.Error()))
-			continue
+			log.Info("Stopping pulsar consumer")
+			return nil
 		}
-		consumer.Ack(msg)
-		time.Sleep(50 * time.Millisecond)
 	}
+}
+
+// holdFactor times the nack redelivery delay is how long the later messages of a failed message's key
+// are held back at most, in case the failed message is redelivered to another consumer of the subscription
+const holdFactor = 5
+
+// acker acks and nacks messages, pulsar.Consumer implements it
+type acker interface {
+	Ack(msg pulsar.Message) error
+	Nack(msg pulsar.Message)
+}
+
+// handle processes msg and acks it, or nacks it when it or an earlier message of its key failed.
+// A permanent failure is acked so it doesn't hold back its key. The returned error is the ack error
+func handle(ctx context.Context, consumer acker, failed *failedKeys, msg pulsar.Message, process func(ctx context.Context, msg pulsar.Message) error) error {
+	log := logger.FromCtx(ctx)
+	key := MessageKey(msg)
+
+	if failed.held(key, msg) {
+		log.Warn("Holding back message until the failed message of its key went through", zap.String("key", key), zap.String("msgId", msg.ID().String()))
+		consumer.Nack(msg)
+		return nil
+	}
+
+	err := process(ctx, msg)
+	if retryable.IsPermanent(err) {
+		log.Error("Dropping message that failed with a permanent error", zap.String("key", key), zap.String("msgId", msg.ID().String()), zap.Error(err))
+		failed.done(key, msg)
+		return consumer.Ack(msg)
+	}
+	if err != nil {
+		log.Warn("error processing message: ", zap.String("error", err.Error()))
+		failed.fail(key, msg)
+		consumer.Nack(msg)
+		return nil
+	}
+
+	failed.done(key, msg)
+	return consumer.Ack(msg)
+}
+
+// failedKeys remembers the first failed message of each key, so the messages after it are held back
+// until it was redelivered and went through and the key stays in order. A key is released after
+// holdFor even if the failed message didn't come back
+type failedKeys struct {
+	holdFor time.Duration
+
+	mu     sync.Mutex
+	failed map[string]failedMessage
+}
+
+type failedMessage struct {
+	id string
+	at time.Time
+}
+
+func newFailedKeys(holdFor time.Duration) *failedKeys {
+	return &failedKeys{holdFor: holdFor, failed: map[string]failedMessage{}}
+}
+
+// held reports whether msg has to wait for an earlier failed message of key
+func (f *failedKeys) held(key string, msg pulsar.Message) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failed, ok := f.failed[key]
+	if !ok || failed.id == msg.ID().String() {
+		return false
+	}
+	if time.Since(failed.at) > f.holdFor {
+		delete(f.failed, key)
+		return false
+	}
+	return true
+}
+
+func (f *failedKeys) fail(key string, msg pulsar.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.failed[key]; !ok {
+		f.failed[key] = failedMessage{id: msg.ID().String(), at: time.Now()}
+	}
+}
+
+func (f *failedKeys) done(key string, msg pulsar.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if failed, ok := f.failed[key]; ok && failed.id == msg.ID().String() {
+		delete(f.failed, key)
+	}
+}
+
+// MessageKey returns the key messages are ordered by, the message key or else the id of the
+// Debezium row. Messages without either share the empty key and are processed in order
+func MessageKey(msg pulsar.Message) string {
+	if key := msg.Key(); key != "" {
+		return key
+	}
+
+	var payload struct {
+		Before *struct {
+			ID string `json:"id"`
+		} `json:"before"`
+		After *struct {
+			ID string `json:"id"`
+		} `json:"after"`
+	}
+	if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
+		return ""
+	}
+	if payload.After != nil {
+		return payload.After.ID
+	}
+	if payload.Before != nil {
+		return payload.Before.ID
+	}
+	return ""
+}
 
+// Close closes the pulsar client
+func (c *ConsumerImpl[T]) Close() {
+	c.client.Close()
 }
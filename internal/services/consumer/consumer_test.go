@@ -0,0 +1,136 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/config"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+)
+
+type fakeMessage struct {
+	pulsar.Message
+	id      string
+	key     string
+	payload string
+}
+
+func (m fakeMessage) ID() pulsar.MessageID { return fakeMessageID{id: m.id} }
+func (m fakeMessage) Key() string          { return m.key }
+func (m fakeMessage) Payload() []byte      { return []byte(m.payload) }
+
+type fakeMessageID struct {
+	pulsar.MessageID
+	id string
+}
+
+func (id fakeMessageID) String() string { return id.id }
+
+type fakeAcker struct {
+	events []string
+}
+
+func (a *fakeAcker) Ack(msg pulsar.Message) error {
+	a.events = append(a.events, "ack "+msg.ID().String())
+	return nil
+}
+
+func (a *fakeAcker) Nack(msg pulsar.Message) {
+	a.events = append(a.events, "nack "+msg.ID().String())
+}
+
+func TestMessageKey(t *testing.T) {
+	assert.Equal(t, "key", MessageKey(fakeMessage{key: "key", payload: `{"after":{"id":"1"}}`}))
+	assert.Equal(t, "1", MessageKey(fakeMessage{payload: `{"before":null,"after":{"id":"1"}}`}))
+	assert.Equal(t, "2", MessageKey(fakeMessage{payload: `{"before":{"id":"2"},"after":null}`}))
+	assert.Equal(t, "", MessageKey(fakeMessage{payload: `not json`}))
+}
+
+func TestHandle(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	first := fakeMessage{id: "1", key: "a1"}
+	second := fakeMessage{id: "2", key: "a1"}
+	other := fakeMessage{id: "3", key: "a2"}
+
+	t.Run("HoldsBackTheKeyOfAFailedMessage", func(t *testing.T) {
+		consumer := &fakeAcker{}
+		failed := newFailedKeys(time.Minute)
+		var processed []string
+		broken := true
+		process := func(ctx context.Context, msg pulsar.Message) error {
+			if msg.ID().String() == "1" && broken {
+				return errors.New("database unavailable")
+			}
+			processed = append(processed, msg.ID().String())
+			return nil
+		}
+
+		require.NoError(t, handle(ctx, consumer, failed, first, process))
+		require.NoError(t, handle(ctx, consumer, failed, second, process))
+		require.NoError(t, handle(ctx, consumer, failed, other, process))
+		assert.Equal(t, []string{"3"}, processed, "the second message of a1 waits for the first")
+
+		// Pulsar redelivers both after the nack redelivery delay
+		broken = false
+		require.NoError(t, handle(ctx, consumer, failed, first, process))
+		require.NoError(t, handle(ctx, consumer, failed, second, process))
+
+		assert.Equal(t, []string{"3", "1", "2"}, processed)
+		assert.Equal(t, []string{"nack 1", "nack 2", "ack 3", "ack 1", "ack 2"}, consumer.events)
+	})
+
+	t.Run("ReleasesTheKeyAfterHoldFor", func(t *testing.T) {
+		consumer := &fakeAcker{}
+		failed := newFailedKeys(0)
+		process := func(ctx context.Context, msg pulsar.Message) error {
+			if msg.ID().String() == "1" {
+				return errors.New("database unavailable")
+			}
+			return nil
+		}
+
+		require.NoError(t, handle(ctx, consumer, failed, first, process))
+		time.Sleep(time.Millisecond)
+		require.NoError(t, handle(ctx, consumer, failed, second, process))
+
+		assert.Equal(t, []string{"nack 1", "ack 2"}, consumer.events)
+	})
+
+	t.Run("AcksPermanentFailures", func(t *testing.T) {
+		consumer := &fakeAcker{}
+		failed := newFailedKeys(time.Minute)
+		process := func(ctx context.Context, msg pulsar.Message) error {
+			if msg.ID().String() == "1" {
+				return retryable.NewPermanent(errors.New("payload does not parse"))
+			}
+			return nil
+		}
+
+		require.NoError(t, handle(ctx, consumer, failed, first, process))
+		require.NoError(t, handle(ctx, consumer, failed, second, process))
+
+		assert.Equal(t, []string{"ack 1", "ack 2"}, consumer.events, "a permanent failure doesn't hold back its key")
+	})
+}
+
+func TestConsumerOptions(t *testing.T) {
+	cfg := config.PulsarConfig{Topic: "anime", SubscribtionName: "sync", NackRedeliveryDelayMs: 500}
+
+	options := consumerOptions(cfg, config.RetryPolicy{MaxRetries: 5, DLQTopicSuffix: "-dlq"})
+	assert.Equal(t, "anime", options.Topic)
+	assert.Equal(t, pulsar.Shared, options.Type)
+	assert.Equal(t, 500*time.Millisecond, options.NackRedeliveryDelay)
+	require.NotNil(t, options.DLQ)
+	assert.Equal(t, uint32(5), options.DLQ.MaxDeliveries)
+	assert.Equal(t, "anime-dlq", options.DLQ.DeadLetterTopic)
+
+	assert.Nil(t, consumerOptions(cfg, config.RetryPolicy{}).DLQ)
+}
@@ -0,0 +1,204 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/dlq_resolution"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"go.uber.org/zap"
+)
+
+// retryHeaderKey is dropped on replay so a replayed message gets the full retry budget again
+const retryHeaderKey = "retry"
+
+type DeadLetterQueue interface {
+	List(ctx context.Context, topic string, includeResolved bool) ([]Message, error)
+	Inspect(ctx context.Context, topic string, ref Ref) (*Message, error)
+	Replay(ctx context.Context, topic string, refs []Ref) (int, error)
+	Discard(ctx context.Context, topic string, refs []Ref) (int, error)
+}
+
+// Reader reads every message currently stored in a topic without committing offsets
+type Reader interface {
+	ReadAll(ctx context.Context, topic string) ([]*kafka.Message, error)
+}
+
+type DeadLetterQueueImpl struct {
+	Reader     Reader
+	Repository dlq_resolution.DLQResolutionRepositoryImpl
+	Producer   func(ctx context.Context, topic string, message *kafka.Message) error
+	// TopicSuffix is stripped from the dead letter topic to find the source topic of messages
+	// dead lettered without the source topic header
+	TopicSuffix string
+}
+
+func NewDeadLetterQueue(db *db.DB, reader Reader, producer func(ctx context.Context, topic string, message *kafka.Message) error, topicSuffix string) DeadLetterQueue {
+	return &DeadLetterQueueImpl{
+		Reader:      reader,
+		Repository:  dlq_resolution.NewDLQResolutionRepository(db),
+		Producer:    producer,
+		TopicSuffix: topicSuffix,
+	}
+}
+
+// List returns the messages of the dead letter topic in partition and offset order,
+// replayed and discarded ones are left out unless includeResolved is set
+func (q *DeadLetterQueueImpl) List(ctx context.Context, topic string, includeResolved bool) ([]Message, error) {
+	raw, err := q.Reader.ReadAll(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	resolutions, err := q.Repository.FindByTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[Ref]dlq_resolution.ResolutionAction, len(resolutions))
+	for _, resolution := range resolutions {
+		resolved[Ref{Partition: resolution.Partition, Offset: resolution.Offset}] = resolution.Action
+	}
+
+	messages := make([]Message, 0, len(raw))
+	for _, message := range raw {
+		parsed := parseMessage(topic, q.TopicSuffix, message)
+		if action, ok := resolved[parsed.Ref]; ok {
+			if !includeResolved {
+				continue
+			}
+			parsed.Resolution = &action
+		}
+		messages = append(messages, parsed)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Ref.Partition != messages[j].Ref.Partition {
+			return messages[i].Ref.Partition < messages[j].Ref.Partition
+		}
+		return messages[i].Ref.Offset < messages[j].Ref.Offset
+	})
+	return messages, nil
+}
+
+func (q *DeadLetterQueueImpl) Inspect(ctx context.Context, topic string, ref Ref) (*Message, error) {
+	messages, err := q.List(ctx, topic, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		if message.Ref == ref {
+			return &message, nil
+		}
+	}
+	return nil, fmt.Errorf("message %s not found in %s", ref, topic)
+}
+
+// Replay produces the given messages, or every unresolved one when refs is empty, back onto
+// their source topic without the dlq and retry headers and records them as replayed
+func (q *DeadLetterQueueImpl) Replay(ctx context.Context, topic string, refs []Ref) (int, error) {
+	log := logger.FromCtx(ctx)
+
+	return q.resolve(ctx, topic, refs, dlq_resolution.ResolutionReplayed, func(message Message) error {
+		headers := make([]kafka.Header, 0, len(message.Headers))
+		for k, v := range message.Headers {
+			if k == retryHeaderKey || strings.HasPrefix(k, "dlq-") {
+				continue
+			}
+			headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+
+		if message.SourceTopic == "" {
+			return fmt.Errorf("source topic of message %s in %s is unknown, it has no %s header", message.Ref, topic, HeaderSourceTopic)
+		}
+
+		log.Info("Replaying dead lettered message", zap.String("ref", message.Ref.String()), zap.String("topic", message.SourceTopic))
+		return q.Producer(ctx, message.SourceTopic, &kafka.Message{
+			Key:     message.Key,
+			Value:   message.Value,
+			Headers: headers,
+		})
+	})
+}
+
+// Discard records the given messages, or every unresolved one when refs is empty, as discarded
+func (q *DeadLetterQueueImpl) Discard(ctx context.Context, topic string, refs []Ref) (int, error) {
+	return q.resolve(ctx, topic, refs, dlq_resolution.ResolutionDiscarded, func(message Message) error {
+		return nil
+	})
+}
+
+func (q *DeadLetterQueueImpl) resolve(ctx context.Context, topic string, refs []Ref, action dlq_resolution.ResolutionAction, apply func(message Message) error) (int, error) {
+	messages, err := q.List(ctx, topic, len(refs) > 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(refs) > 0 {
+		byRef := make(map[Ref]Message, len(messages))
+		for _, message := range messages {
+			byRef[message.Ref] = message
+		}
+
+		selected := make([]Message, 0, len(refs))
+		for _, ref := range refs {
+			message, ok := byRef[ref]
+			if !ok {
+				return 0, fmt.Errorf("message %s not found in %s", ref, topic)
+			}
+			selected = append(selected, message)
+		}
+		messages = selected
+	}
+
+	for i, message := range messages {
+		if err := apply(message); err != nil {
+			return i, err
+		}
+		err = q.Repository.Resolve(&dlq_resolution.DLQResolution{
+			Topic:     topic,
+			Partition: message.Ref.Partition,
+			Offset:    message.Ref.Offset,
+			Action:    action,
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// parseMessage reads the dlq headers of message. The source topic comes from its header, messages
+// dead lettered before the header was added fall back to topic without suffix, and get no source
+// topic when topic does not end in suffix, e.g. a dead letter topic set per entity
+func parseMessage(topic string, suffix string, message *kafka.Message) Message {
+	headers := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+
+	parsed := Message{
+		Ref:         Ref{Partition: message.TopicPartition.Partition, Offset: int64(message.TopicPartition.Offset)},
+		Key:         message.Key,
+		Value:       message.Value,
+		Headers:     headers,
+		Error:       headers[HeaderError],
+		Processor:   headers[HeaderProcessor],
+		SourceTopic: headers[HeaderSourceTopic],
+	}
+	if parsed.SourceTopic == "" && suffix != "" && strings.HasSuffix(topic, suffix) {
+		parsed.SourceTopic = strings.TrimSuffix(topic, suffix)
+	}
+	if retryCount, err := strconv.Atoi(headers[HeaderRetryCount]); err == nil {
+		parsed.RetryCount = retryCount
+	}
+	if failedAt, err := time.Parse(time.RFC3339, headers[HeaderFailedAt]); err == nil {
+		parsed.FailedAt = &failedAt
+	}
+	return parsed
+}
@@ -0,0 +1,179 @@
+package dlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/dlq_resolution"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+type fakeReader struct {
+	messages []*kafka.Message
+}
+
+func (r *fakeReader) ReadAll(ctx context.Context, topic string) ([]*kafka.Message, error) {
+	return r.messages, nil
+}
+
+type fakeResolutionRepository struct {
+	resolutions []dlq_resolution.DLQResolution
+}
+
+func (r *fakeResolutionRepository) Resolve(resolution *dlq_resolution.DLQResolution) error {
+	r.resolutions = append(r.resolutions, *resolution)
+	return nil
+}
+
+func (r *fakeResolutionRepository) FindByTopic(topic string) ([]dlq_resolution.DLQResolution, error) {
+	return r.resolutions, nil
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+	topic := "anime-db.public.anime-dlq"
+
+	newMessage := func(partition int32, offset int64, processor string) *kafka.Message {
+		return &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)},
+			Key:            []byte("anime-1"),
+			Value:          []byte(`{"payload":{}}`),
+			Headers: []kafka.Header{
+				{Key: "retry", Value: []byte("2")},
+				{Key: "trace", Value: []byte("abc")},
+				{Key: HeaderError, Value: []byte("invalid date")},
+				{Key: HeaderRetryCount, Value: []byte("2")},
+				{Key: HeaderProcessor, Value: []byte(processor)},
+				{Key: HeaderSourceTopic, Value: []byte("anime-db.public.anime")},
+				{Key: HeaderFailedAt, Value: []byte("2024-04-01T10:00:00Z")},
+			},
+		}
+	}
+
+	type produced struct {
+		topic   string
+		message *kafka.Message
+	}
+
+	setup := func() (*DeadLetterQueueImpl, *fakeResolutionRepository, *[]produced) {
+		repository := &fakeResolutionRepository{}
+		var replayed []produced
+		queue := &DeadLetterQueueImpl{
+			Reader: &fakeReader{messages: []*kafka.Message{
+				newMessage(1, 4, "episode_processor"),
+				newMessage(0, 7, "anime_processor"),
+				newMessage(0, 3, "anime_processor"),
+			}},
+			Repository: repository,
+			Producer: func(ctx context.Context, topic string, message *kafka.Message) error {
+				replayed = append(replayed, produced{topic: topic, message: message})
+				return nil
+			},
+		}
+		return queue, repository, &replayed
+	}
+
+	t.Run("ListParsesHeadersInOrder", func(t *testing.T) {
+		queue, _, _ := setup()
+
+		messages, err := queue.List(ctx, topic, false)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+
+		assert.Equal(t, []Ref{{0, 3}, {0, 7}, {1, 4}}, []Ref{messages[0].Ref, messages[1].Ref, messages[2].Ref})
+		assert.Equal(t, "invalid date", messages[0].Error)
+		assert.Equal(t, 2, messages[0].RetryCount)
+		assert.Equal(t, "anime_processor", messages[0].Processor)
+		assert.Equal(t, "anime-db.public.anime", messages[0].SourceTopic)
+		require.NotNil(t, messages[0].FailedAt)
+		assert.Nil(t, messages[0].Resolution)
+	})
+
+	t.Run("ReplayProducesToSourceTopicWithoutDLQHeaders", func(t *testing.T) {
+		queue, repository, replayed := setup()
+
+		count, err := queue.Replay(ctx, topic, []Ref{{Partition: 0, Offset: 7}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		require.Len(t, *replayed, 1)
+		assert.Equal(t, "anime-db.public.anime", (*replayed)[0].topic)
+		assert.Equal(t, []kafka.Header{{Key: "trace", Value: []byte("abc")}}, (*replayed)[0].message.Headers)
+
+		require.Len(t, repository.resolutions, 1)
+		assert.Equal(t, dlq_resolution.ResolutionReplayed, repository.resolutions[0].Action)
+
+		pending, err := queue.List(ctx, topic, false)
+		require.NoError(t, err)
+		assert.Len(t, pending, 2)
+
+		all, err := queue.List(ctx, topic, true)
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		require.NotNil(t, all[1].Resolution)
+		assert.Equal(t, dlq_resolution.ResolutionReplayed, *all[1].Resolution)
+	})
+
+	t.Run("DiscardAllSkipsResolved", func(t *testing.T) {
+		queue, repository, replayed := setup()
+		require.NoError(t, repository.Resolve(&dlq_resolution.DLQResolution{Topic: topic, Partition: 1, Offset: 4, Action: dlq_resolution.ResolutionReplayed}))
+
+		count, err := queue.Discard(ctx, topic, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Empty(t, *replayed)
+
+		pending, err := queue.List(ctx, topic, false)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("UnknownRefFails", func(t *testing.T) {
+		queue, _, _ := setup()
+
+		_, err := queue.Replay(ctx, topic, []Ref{{Partition: 2, Offset: 1}})
+		assert.Error(t, err)
+	})
+
+	t.Run("ReplayWithoutSourceTopicFails", func(t *testing.T) {
+		queue, repository, replayed := setup()
+		queue.TopicSuffix = "-dlq"
+		queue.Reader = &fakeReader{messages: []*kafka.Message{{
+			TopicPartition: kafka.TopicPartition{Partition: 0, Offset: 1},
+			Key:            []byte("anime-1"),
+		}}}
+
+		_, err := queue.Replay(ctx, "anime-dead-letters", nil)
+		require.Error(t, err)
+		assert.Empty(t, *replayed)
+		assert.Empty(t, repository.resolutions)
+	})
+}
+
+func TestParseMessage(t *testing.T) {
+	withHeader := &kafka.Message{Headers: []kafka.Header{{Key: HeaderSourceTopic, Value: []byte("anime-db.public.anime")}}}
+	withoutHeader := &kafka.Message{}
+
+	assert.Equal(t, "anime-db.public.anime", parseMessage("anime-dead-letters", "-dlq", withHeader).SourceTopic)
+	assert.Equal(t, "anime-db.public.anime", parseMessage("anime-db.public.anime-dead", "-dead", withHeader).SourceTopic)
+	assert.Equal(t, "anime-db.public.anime", parseMessage("anime-db.public.anime-dead", "-dead", withoutHeader).SourceTopic)
+	assert.Empty(t, parseMessage("anime-dead-letters", "-dlq", withoutHeader).SourceTopic)
+	assert.Empty(t, parseMessage("anime-db.public.anime-dlq", "", withoutHeader).SourceTopic)
+}
+
+func TestParseRef(t *testing.T) {
+	ref, err := ParseRef(" 2:15 ")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Partition: 2, Offset: 15}, ref)
+	assert.Equal(t, "2:15", ref.String())
+
+	for _, value := range []string{"", "2", "a:1", "1:b"} {
+		_, err := ParseRef(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,92 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// readTimeout bounds metadata, watermark and read calls against the brokers
+const readTimeout = 10 * time.Second
+
+type KafkaReader struct {
+	bootstrapServers string
+	groupID          string
+}
+
+// NewKafkaReader returns a reader that assigns every partition of a topic from its low watermark,
+// the group id is only used for the connection, no offsets are committed
+func NewKafkaReader(bootstrapServers string, groupID string) Reader {
+	return &KafkaReader{
+		bootstrapServers: bootstrapServers,
+		groupID:          groupID,
+	}
+}
+
+func (r *KafkaReader) ReadAll(ctx context.Context, topic string) ([]*kafka.Message, error) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  r.bootstrapServers,
+		"group.id":           r.groupID,
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	metadata, err := consumer.GetMetadata(&topic, false, int(readTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata for %s: %w", topic, err)
+	}
+	topicMetadata, ok := metadata.Topics[topic]
+	if !ok || topicMetadata.Error.Code() == kafka.ErrUnknownTopicOrPart {
+		return nil, nil
+	}
+
+	// remaining holds the offset each partition has to be read up to
+	remaining := map[int32]int64{}
+	var assignments []kafka.TopicPartition
+	for _, partition := range topicMetadata.Partitions {
+		low, high, err := consumer.QueryWatermarkOffsets(topic, partition.ID, int(readTimeout.Milliseconds()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query offsets of %s/%d: %w", topic, partition.ID, err)
+		}
+		if high <= low {
+			continue
+		}
+		remaining[partition.ID] = high
+		assignments = append(assignments, kafka.TopicPartition{Topic: &topic, Partition: partition.ID, Offset: kafka.Offset(low)})
+	}
+	if len(assignments) == 0 {
+		return nil, nil
+	}
+
+	if err := consumer.Assign(assignments); err != nil {
+		return nil, fmt.Errorf("failed to assign partitions: %w", err)
+	}
+
+	var messages []*kafka.Message
+	for len(remaining) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		message, err := consumer.ReadMessage(readTimeout)
+		if err != nil {
+			// the high watermark can point past the last readable message, e.g. after transaction markers
+			if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+				break
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", topic, err)
+		}
+
+		messages = append(messages, message)
+		partition := message.TopicPartition.Partition
+		if high, ok := remaining[partition]; ok && int64(message.TopicPartition.Offset)+1 >= high {
+			delete(remaining, partition)
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,60 @@
+package dlq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/dlq_resolution"
+)
+
+// Headers added to dead lettered messages next to the original headers
+const (
+	HeaderError       = "dlq-error"
+	HeaderErrorClass  = "dlq-error-class"
+	HeaderRetryCount  = "dlq-retry-count"
+	HeaderProcessor   = "dlq-processor"
+	HeaderSourceTopic = "dlq-source-topic"
+	HeaderFailedAt    = "dlq-failed-at"
+)
+
+// Ref points at a message in the dead letter topic
+type Ref struct {
+	Partition int32
+	Offset    int64
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d:%d", r.Partition, r.Offset)
+}
+
+// ParseRef parses a "partition:offset" reference as printed by dlq list
+func ParseRef(value string) (Ref, error) {
+	partition, offset, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return Ref{}, fmt.Errorf("invalid message reference %q, expected partition:offset", value)
+	}
+	p, err := strconv.ParseInt(partition, 10, 32)
+	if err != nil {
+		return Ref{}, fmt.Errorf("invalid partition in %q: %w", value, err)
+	}
+	o, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil {
+		return Ref{}, fmt.Errorf("invalid offset in %q: %w", value, err)
+	}
+	return Ref{Partition: int32(p), Offset: o}, nil
+}
+
+type Message struct {
+	Ref         Ref
+	Key         []byte
+	Value       []byte
+	Headers     map[string]string
+	Error       string
+	RetryCount  int
+	Processor   string
+	SourceTopic string
+	FailedAt    *time.Time
+	Resolution  *dlq_resolution.ResolutionAction
+}
@@ -2,32 +2,48 @@ package episode_processor
 
 import (
 	"context"
+	"fmt"
 	"github.com/ThatCatDev/ep/v2/event"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/changes"
 	"github.com/weeb-vip/anime-sync/internal/db"
 	anime_episode "github.com/weeb-vip/anime-sync/internal/db/repositories/anime_episode"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
 	"github.com/weeb-vip/anime-sync/internal/logger"
 	"go.uber.org/zap"
 	"time"
 )
 
+// positionEntity is the entity name episode rows are recorded under in sync_positions
+const positionEntity = "episode"
+
+// unstoredFields are schema fields without an episodes column
+var unstoredFields = []string{"title_synonyms"}
+
 type Options struct {
 	NoErrorOnDelete bool
+	// ForceReplay applies events even when a newer one was already applied to the row, e.g. during backfills
+	ForceReplay bool
 }
 
 type EpisodeProcessor interface {
 	Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error)
+	ProcessBatch(ctx context.Context, payloads []Payload) error
 }
 
 type EpisodeProcessorImpl struct {
-	Repository anime_episode.AnimeEpisodeRepositoryImpl
-	Options    Options
+	Transactor         db.Transactor
+	Repository         anime_episode.AnimeEpisodeRepositoryImpl
+	PositionRepository sync_position.SyncPositionRepositoryImpl
+	Options            Options
 }
 
 func NewAnimeProcessor(opt Options, db *db.DB) EpisodeProcessor {
 	return &EpisodeProcessorImpl{
-		Repository: anime_episode.NewAnimeRepository(db),
-		Options:    opt,
+		Transactor:         db,
+		Repository:         anime_episode.NewAnimeRepository(db),
+		PositionRepository: sync_position.NewSyncPositionRepository(db),
+		Options:            opt,
 	}
 }
 
@@ -42,7 +58,7 @@ func (p *EpisodeProcessorImpl) Process(ctx context.Context, data event.Event[*ka
 		if err != nil {
 			return data, err
 		}
-		err = p.Repository.Upsert(newAnime)
+		err = p.save(ctx, newAnime, payload.Source, nil)
 		if err != nil {
 			return data, err
 		}
@@ -55,7 +71,15 @@ func (p *EpisodeProcessorImpl) Process(ctx context.Context, data event.Event[*ka
 			return data, err
 		}
 
-		err = p.Repository.Delete(oldAnime)
+		err = p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			stale, err := p.isStale(ctx, tx, oldAnime.ID, payload.Source)
+			if err != nil || stale {
+				return err
+			}
+
+			// the recorded position is kept so older events can't bring the row back
+			return p.Repository.WithTx(tx).Delete(oldAnime)
+		})
 		if err != nil {
 			if p.Options.NoErrorOnDelete {
 				log.Warn("WARN: error deleting from db: ", zap.Error(err))
@@ -74,7 +98,12 @@ func (p *EpisodeProcessorImpl) Process(ctx context.Context, data event.Event[*ka
 		if err != nil {
 			return data, err
 		}
-		err = p.Repository.Upsert(newAnime)
+		changed := changes.Diff(payload.Before, payload.After)
+		if len(changed.Columns(unstoredFields...)) == 0 {
+			log.Info("Update changed no stored fields, skipping write", zap.String("id", newAnime.ID))
+		}
+
+		err = p.save(ctx, newAnime, payload.Source, changed)
 		if err != nil {
 			return data, err
 		}
@@ -88,6 +117,104 @@ func (p *EpisodeProcessorImpl) Process(ctx context.Context, data event.Event[*ka
 
 }
 
+// save upserts the episode unless a newer event was already applied to it. For updates changed holds
+// the changed fields and only those columns are written, it is nil for creates
+func (p *EpisodeProcessorImpl) save(ctx context.Context, episode *anime_episode.AnimeEpisode, source Source, changed changes.Set) error {
+	return p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		stale, err := p.isStale(ctx, tx, episode.ID, source)
+		if err != nil || stale {
+			return err
+		}
+
+		if changed == nil {
+			return p.Repository.WithTx(tx).Upsert(episode)
+		}
+		if columns := changed.Columns(unstoredFields...); len(columns) > 0 {
+			return p.Repository.WithTx(tx).UpdateColumns(episode, columns)
+		}
+		return nil
+	})
+}
+
+// ProcessBatch applies the create events of a snapshot at once with multi row upserts, the episode
+// counts of their anime are updated once per batch instead of once per row. Several events of the
+// same episode are folded into the last one. Updates and deletes are rejected, they have to go through Process
+func (p *EpisodeProcessorImpl) ProcessBatch(ctx context.Context, payloads []Payload) error {
+	log := logger.FromCtx(ctx)
+
+	var episodes []*anime_episode.AnimeEpisode
+	var positions []*sync_position.SyncPosition
+	index := map[string]int{}
+	for _, payload := range payloads {
+		if payload.Before != nil || payload.After == nil {
+			return fmt.Errorf("episode batches only take create events")
+		}
+
+		episode, err := p.parseToEntity(ctx, *payload.After)
+		if err != nil {
+			return err
+		}
+		position := &sync_position.SyncPosition{
+			Entity:   positionEntity,
+			EntityID: episode.ID,
+			Lsn:      int64(payload.Source.Lsn),
+			TsMs:     payload.Source.TsMs,
+			TxID:     int64(payload.Source.TxId),
+		}
+
+		if i, ok := index[episode.ID]; ok {
+			episodes[i] = episode
+			positions[i] = position
+			continue
+		}
+		index[episode.ID] = len(episodes)
+		episodes = append(episodes, episode)
+		positions = append(positions, position)
+	}
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	log.Info("Applying episode batch", zap.Int("events", len(payloads)), zap.Int("episodes", len(episodes)))
+
+	return p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		stale, err := p.PositionRepository.WithTx(tx).AdvanceMany(positions)
+		if err != nil {
+			return err
+		}
+
+		fresh := make([]*anime_episode.AnimeEpisode, 0, len(episodes))
+		for i, episode := range episodes {
+			if stale[i] && !p.Options.ForceReplay {
+				log.Warn("Skipping stale episode event, a newer one was already applied", zap.String("id", episode.ID), zap.Int64("lsn", positions[i].Lsn), zap.Int64("tsMs", positions[i].TsMs))
+				continue
+			}
+			fresh = append(fresh, episode)
+		}
+
+		return p.Repository.WithTx(tx).UpsertMany(fresh)
+	})
+}
+
+// isStale records the source position of the event for the row and reports whether it has to be
+// skipped because a newer event was already applied
+func (p *EpisodeProcessorImpl) isStale(ctx context.Context, tx *db.DB, id string, source Source) (bool, error) {
+	position := &sync_position.SyncPosition{
+		Entity:   positionEntity,
+		EntityID: id,
+		Lsn:      int64(source.Lsn),
+		TsMs:     source.TsMs,
+		TxID:     int64(source.TxId),
+	}
+	stale, err := p.PositionRepository.WithTx(tx).Check(position, p.Options.ForceReplay)
+	if err != nil || !stale {
+		return false, err
+	}
+
+	logger.FromCtx(ctx).Warn("Skipping stale episode event, a newer one was already applied", zap.String("id", id), zap.Int64("lsn", position.Lsn), zap.Int64("tsMs", position.TsMs))
+	return true, nil
+}
+
 func (p *EpisodeProcessorImpl) parseToEntity(ctx context.Context, data Schema) (*anime_episode.AnimeEpisode, error) {
 	var newEpisode anime_episode.AnimeEpisode
 
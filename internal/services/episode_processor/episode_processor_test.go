@@ -0,0 +1,169 @@
+package episode_processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	anime_episode "github.com/weeb-vip/anime-sync/internal/db/repositories/anime_episode"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/sync_position"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor/synctest"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transaction(ctx context.Context, fn func(ctx context.Context, tx *db.DB) error) error {
+	return fn(ctx, nil)
+}
+
+type fakeEpisodeRepository struct {
+	episodes map[string]*anime_episode.AnimeEpisode
+	// updatedColumns records the columns of every UpdateColumns call
+	updatedColumns [][]string
+	// batches records the episodes of every UpsertMany call
+	batches [][]*anime_episode.AnimeEpisode
+}
+
+func (r *fakeEpisodeRepository) Upsert(episode *anime_episode.AnimeEpisode) error {
+	r.episodes[episode.ID] = episode
+	return nil
+}
+
+func (r *fakeEpisodeRepository) UpsertMany(episodes []*anime_episode.AnimeEpisode) error {
+	r.batches = append(r.batches, episodes)
+	for _, episode := range episodes {
+		r.episodes[episode.ID] = episode
+	}
+	return nil
+}
+
+func (r *fakeEpisodeRepository) UpdateColumns(episode *anime_episode.AnimeEpisode, columns []string) error {
+	r.updatedColumns = append(r.updatedColumns, columns)
+	r.episodes[episode.ID] = episode
+	return nil
+}
+
+func (r *fakeEpisodeRepository) Delete(episode *anime_episode.AnimeEpisode) error {
+	delete(r.episodes, episode.ID)
+	return nil
+}
+
+func (r *fakeEpisodeRepository) WithTx(tx *db.DB) anime_episode.AnimeEpisodeRepositoryImpl {
+	return r
+}
+
+// newTestProcessor returns a processor on fakes, the stored position of episode-test is lsn 200
+func newTestProcessor(options Options) (*EpisodeProcessorImpl, *fakeEpisodeRepository) {
+	repository := &fakeEpisodeRepository{episodes: map[string]*anime_episode.AnimeEpisode{}}
+	positions := &synctest.PositionRepository{Positions: map[string]sync_position.SyncPosition{
+		positionEntity + "/episode-test": {Entity: positionEntity, EntityID: "episode-test", Lsn: 200, TsMs: 200},
+	}}
+
+	return &EpisodeProcessorImpl{
+		Transactor:         fakeTransactor{},
+		Repository:         repository,
+		PositionRepository: positions,
+		Options:            options,
+	}, repository
+}
+
+func TestSkipsStaleEvents(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	title := "Episode"
+	tests := []struct {
+		name    string
+		options Options
+		lsn     int
+		stored  bool
+	}{
+		{"Newer", Options{}, 300, true},
+		{"Stale", Options{}, 150, false},
+		{"ForceReplay", Options{ForceReplay: true}, 150, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, repository := newTestProcessor(tt.options)
+
+			payload := Payload{
+				After:  &Schema{Id: "episode-test", TitleEn: &title},
+				Source: Source{Lsn: tt.lsn, TsMs: int64(tt.lsn)},
+			}
+			_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: payload})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.stored, repository.episodes["episode-test"] != nil)
+		})
+	}
+}
+
+func TestUpdatesOnlyApplyChangedFields(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	title := "Episode"
+	newTitle := "Renamed episode"
+	synonyms := `["Ep"]`
+	before := &Schema{Id: "episode-test", TitleEn: &title}
+
+	tests := []struct {
+		name    string
+		after   *Schema
+		columns [][]string
+	}{
+		{"TitleEn", &Schema{Id: "episode-test", TitleEn: &newTitle}, [][]string{{"title_en"}}},
+		{"OnlyUnstoredFields", &Schema{Id: "episode-test", TitleEn: &title, TitleSynonyms: &synonyms}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, repository := newTestProcessor(Options{})
+
+			_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: Payload{Before: before, After: tt.after}})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.columns, repository.updatedColumns)
+		})
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	first := "First"
+	last := "Last"
+
+	t.Run("FoldsEventsAndSkipsStaleOnes", func(t *testing.T) {
+		processor, repository := newTestProcessor(Options{})
+
+		err := processor.ProcessBatch(ctx, []Payload{
+			{After: &Schema{Id: "episode-other", TitleEn: &first}, Source: Source{Lsn: 100, TsMs: 100}},
+			{After: &Schema{Id: "episode-test", TitleEn: &first}, Source: Source{Lsn: 150, TsMs: 150}},
+			{After: &Schema{Id: "episode-other", TitleEn: &last}, Source: Source{Lsn: 110, TsMs: 110}},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, repository.batches, 1)
+		require.Len(t, repository.batches[0], 1)
+		assert.Equal(t, "episode-other", repository.batches[0][0].ID)
+		assert.Equal(t, &last, repository.batches[0][0].TitleEn)
+		assert.Nil(t, repository.episodes["episode-test"])
+	})
+
+	t.Run("RejectsUpdates", func(t *testing.T) {
+		processor, repository := newTestProcessor(Options{})
+
+		err := processor.ProcessBatch(ctx, []Payload{
+			{Before: &Schema{Id: "episode-test"}, After: &Schema{Id: "episode-test", TitleEn: &last}},
+		})
+		require.Error(t, err)
+		assert.Empty(t, repository.batches)
+	})
+}
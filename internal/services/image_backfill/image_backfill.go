@@ -0,0 +1,186 @@
+package image_backfill
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_staff"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/ratelimit"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"go.uber.org/zap"
+)
+
+const defaultBatchSize = 500
+
+type ImageBackfill interface {
+	Run(ctx context.Context) (Result, error)
+}
+
+type ImageBackfillImpl struct {
+	AnimeRepository     anime.AnimeRepositoryImpl
+	CharacterRepository anime_character.AnimeCharacterRepositoryImpl
+	StaffRepository     anime_staff.AnimeStaffRepositoryImpl
+	Options             Options
+	Images              publisher.Publisher[anime_processor.ImagePayload]
+	// Report is called for every image request, after it was produced or instead of producing it in a dry run
+	Report func(id string, image *anime_processor.ImagePayload)
+}
+
+func NewImageBackfill(opt Options, db *db.DB, images publisher.Publisher[anime_processor.ImagePayload], report func(id string, image *anime_processor.ImagePayload)) ImageBackfill {
+	return &ImageBackfillImpl{
+		AnimeRepository:     anime.NewAnimeRepository(db),
+		CharacterRepository: anime_character.NewAnimeCharacterRepository(db),
+		StaffRepository:     anime_staff.NewAnimeStaffRepository(db),
+		Options:             opt,
+		Images:              images,
+		Report:              report,
+	}
+}
+
+// row is a scanned row with its image request, nil when it has no image url
+type row struct {
+	id    string
+	image *anime_processor.ImagePayload
+}
+
+// Run produces an image sync request for every selected anime, character and staff member with an
+// image url, named like the processors name them. Requests carry the legacy name as well, so running
+// a backfill moves images stored under the names used before image names were slugged
+func (b *ImageBackfillImpl) Run(ctx context.Context) (Result, error) {
+	var result Result
+
+	selected := make(map[string]bool, len(b.Options.Types))
+	for _, entityType := range b.Options.Types {
+		if entityType != TypeAnime && entityType != TypeCharacters && entityType != TypeStaff {
+			return result, fmt.Errorf("unknown type %q, expected one of %v", entityType, Types)
+		}
+		selected[entityType] = true
+	}
+
+	pace := ratelimit.New(b.Options.RatePerSecond)
+	for _, entityType := range Types {
+		if len(selected) > 0 && !selected[entityType] {
+			continue
+		}
+		if err := b.backfill(ctx, entityType, pace, &result); err != nil {
+			return result, fmt.Errorf("backfill %s: %w", entityType, err)
+		}
+	}
+	return result, nil
+}
+
+func (b *ImageBackfillImpl) backfill(ctx context.Context, entityType string, pace *ratelimit.Limiter, result *Result) error {
+	log := logger.FromCtx(ctx).With(zap.String("type", entityType))
+
+	batchSize := b.Options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	afterID := ""
+	for {
+		rows, err := b.page(entityType, afterID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			result.Scanned++
+			afterID = row.id
+
+			if row.image == nil {
+				result.Skipped++
+				continue
+			}
+
+			if !b.Options.DryRun {
+				if err := b.send(ctx, pace, row.id, row.image); err != nil {
+					return err
+				}
+			}
+			result.Sent++
+			if b.Report != nil {
+				b.Report(row.id, row.image)
+			}
+		}
+
+		log.Info("Backfilled image batch", zap.Int64("scanned", result.Scanned), zap.Int64("sent", result.Sent), zap.Int64("skipped", result.Skipped), zap.Bool("dryRun", b.Options.DryRun))
+		if len(rows) < batchSize {
+			return nil
+		}
+	}
+}
+
+// page reads the next batch of rows of entityType after afterID and builds their image requests
+func (b *ImageBackfillImpl) page(entityType string, afterID string, limit int) ([]row, error) {
+	switch entityType {
+	case TypeCharacters:
+		characters, err := b.CharacterRepository.FindPage(anime_character.Filter{
+			UpdatedSince: b.Options.UpdatedSince,
+			AnimeIDs:     b.Options.IDs,
+			AnimeStatus:  b.Options.Status,
+		}, afterID, limit)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]row, len(characters))
+		for i, character := range characters {
+			image, _ := anime_processor.NewImagePayload(anime_processor.DataTypeCharacter, character.ID, imageURL(character.Image), character.Name)
+			rows[i] = row{id: character.ID, image: image}
+		}
+		return rows, nil
+	case TypeStaff:
+		staff, err := b.StaffRepository.FindPage(anime_staff.Filter{
+			UpdatedSince: b.Options.UpdatedSince,
+			AnimeIDs:     b.Options.IDs,
+			AnimeStatus:  b.Options.Status,
+		}, afterID, limit)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]row, len(staff))
+		for i, member := range staff {
+			name := strings.TrimSpace(member.GivenName + " " + member.FamilyName)
+			image, _ := anime_processor.NewImagePayload(anime_processor.DataTypeStaff, member.ID, imageURL(member.Image), name)
+			rows[i] = row{id: member.ID, image: image}
+		}
+		return rows, nil
+	default:
+		animes, err := b.AnimeRepository.FindPage(anime.Filter{
+			UpdatedSince: b.Options.UpdatedSince,
+			IDs:          b.Options.IDs,
+			Status:       b.Options.Status,
+		}, afterID, limit)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]row, len(animes))
+		for i, a := range animes {
+			image, _ := anime_processor.NewAnimeImagePayload(a.ID, a.TitleEn, a.TitleJp, imageURL(a.ImageURL))
+			rows[i] = row{id: a.ID, image: image}
+		}
+		return rows, nil
+	}
+}
+
+// imageURL treats an empty image url like a missing one
+func imageURL(url *string) *string {
+	if url == nil || *url == "" {
+		return nil
+	}
+	return url
+}
+
+func (b *ImageBackfillImpl) send(ctx context.Context, pace *ratelimit.Limiter, id string, image *anime_processor.ImagePayload) error {
+	if err := pace.Wait(ctx); err != nil {
+		return err
+	}
+
+	return b.Images.Publish(ctx, anime_processor.ImageMessage(anime_processor.UpdateAction, id, image))
+}
@@ -0,0 +1,155 @@
+package image_backfill
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_character"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_staff"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/slug"
+)
+
+type fakeAnimeRepository struct {
+	anime.AnimeRepositoryImpl
+	animes  []anime.Anime
+	filters []anime.Filter
+}
+
+func (r *fakeAnimeRepository) FindPage(filter anime.Filter, afterID string, limit int) ([]anime.Anime, error) {
+	r.filters = append(r.filters, filter)
+	var page []anime.Anime
+	for _, a := range r.animes {
+		if a.ID > afterID && len(page) < limit {
+			page = append(page, a)
+		}
+	}
+	return page, nil
+}
+
+type fakeCharacterRepository struct {
+	anime_character.AnimeCharacterRepositoryImpl
+	characters []anime_character.AnimeCharacter
+	filters    []anime_character.Filter
+}
+
+func (r *fakeCharacterRepository) FindPage(filter anime_character.Filter, afterID string, limit int) ([]anime_character.AnimeCharacter, error) {
+	r.filters = append(r.filters, filter)
+	var page []anime_character.AnimeCharacter
+	for _, c := range r.characters {
+		if c.ID > afterID && len(page) < limit {
+			page = append(page, c)
+		}
+	}
+	return page, nil
+}
+
+type fakeStaffRepository struct {
+	anime_staff.AnimeStaffRepositoryImpl
+	staff []anime_staff.AnimeStaff
+}
+
+func (r *fakeStaffRepository) FindPage(filter anime_staff.Filter, afterID string, limit int) ([]anime_staff.AnimeStaff, error) {
+	var page []anime_staff.AnimeStaff
+	for _, s := range r.staff {
+		if s.ID > afterID && len(page) < limit {
+			page = append(page, s)
+		}
+	}
+	return page, nil
+}
+
+func TestImageBackfill(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	str := func(value string) *string { return &value }
+	animes := []anime.Anime{
+		{ID: "a1", TitleEn: str("Cowboy Bebop"), ImageURL: str("https://cdn.example/bebop.jpg")},
+		{ID: "a2", TitleJp: str("Mushi Shi"), ImageURL: str("https://cdn.example/mushishi.jpg")},
+		{ID: "a3", TitleEn: str("No Image")},
+		{ID: "a4", TitleEn: str("Empty Image"), ImageURL: str("")},
+		{ID: "a5", ImageURL: str("https://cdn.example/untitled.jpg")},
+	}
+	characters := []anime_character.AnimeCharacter{
+		{ID: "c1", AnimeID: "a1", Name: "Spike Spiegel", Image: str("https://cdn.example/spike.jpg")},
+		{ID: "c2", AnimeID: "a1", Name: "Jet Black"},
+	}
+	staff := []anime_staff.AnimeStaff{
+		{ID: "s1", GivenName: "Shinichiro", FamilyName: "Watanabe", Image: str("https://cdn.example/watanabe.jpg")},
+	}
+
+	newBackfill := func(opt Options) (*ImageBackfillImpl, *publisher.Memory[anime_processor.ImagePayload]) {
+		images := &publisher.Memory[anime_processor.ImagePayload]{}
+		return &ImageBackfillImpl{
+			AnimeRepository:     &fakeAnimeRepository{animes: animes},
+			CharacterRepository: &fakeCharacterRepository{characters: characters},
+			StaffRepository:     &fakeStaffRepository{staff: staff},
+			Options:             opt,
+			Images:              images,
+		}, images
+	}
+
+	t.Run("SendsEveryRowWithAnImage", func(t *testing.T) {
+		backfill, images := newBackfill(Options{BatchSize: 2})
+		result, err := backfill.Run(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, Result{Scanned: 8, Sent: 5, Skipped: 3}, result)
+		assert.Equal(t, []anime_processor.ImagePayload{
+			{Data: anime_processor.ImageSchema{Name: slug.ImageName("a1", "Cowboy Bebop"), URL: "https://cdn.example/bebop.jpg", Type: anime_processor.DataTypeAnime, LegacyName: "cowboy_bebop"}},
+			{Data: anime_processor.ImageSchema{Name: slug.ImageName("a2", "Mushi Shi"), URL: "https://cdn.example/mushishi.jpg", Type: anime_processor.DataTypeAnime, LegacyName: "mushi_shi"}},
+			{Data: anime_processor.ImageSchema{Name: slug.Suffix("a5"), URL: "https://cdn.example/untitled.jpg", Type: anime_processor.DataTypeAnime}},
+			{Data: anime_processor.ImageSchema{Name: slug.ImageName("c1", "Spike Spiegel"), URL: "https://cdn.example/spike.jpg", Type: anime_processor.DataTypeCharacter, LegacyName: "spike_spiegel"}},
+			{Data: anime_processor.ImageSchema{Name: slug.ImageName("s1", "Shinichiro Watanabe"), URL: "https://cdn.example/watanabe.jpg", Type: anime_processor.DataTypeStaff, LegacyName: "shinichiro_watanabe"}},
+		}, images.Values())
+
+		messages := images.Messages()
+		assert.Equal(t, "a1", messages[0].Key)
+		assert.Equal(t, "anime", messages[0].Entity)
+		assert.Equal(t, "c1", messages[3].Key)
+		assert.Equal(t, "character", messages[3].Entity)
+	})
+
+	t.Run("DryRunOnlyReports", func(t *testing.T) {
+		backfill, images := newBackfill(Options{DryRun: true, Types: []string{TypeAnime}})
+		var reported []string
+		backfill.Report = func(id string, image *anime_processor.ImagePayload) {
+			reported = append(reported, id)
+		}
+
+		result, err := backfill.Run(ctx)
+		require.NoError(t, err)
+
+		assert.Zero(t, images.Calls())
+		assert.Equal(t, []string{"a1", "a2", "a5"}, reported)
+		assert.Equal(t, Result{Scanned: 5, Sent: 3, Skipped: 2}, result)
+	})
+
+	t.Run("PassesFiltersToTheRepositories", func(t *testing.T) {
+		backfill, _ := newBackfill(Options{Types: []string{TypeAnime, TypeCharacters}, IDs: []string{"a1"}, Status: "Currently Airing"})
+
+		_, err := backfill.Run(ctx)
+		require.NoError(t, err)
+
+		animeFilters := backfill.AnimeRepository.(*fakeAnimeRepository).filters
+		require.NotEmpty(t, animeFilters)
+		assert.Equal(t, anime.Filter{IDs: []string{"a1"}, Status: "Currently Airing"}, animeFilters[0])
+
+		characterFilters := backfill.CharacterRepository.(*fakeCharacterRepository).filters
+		require.NotEmpty(t, characterFilters)
+		assert.Equal(t, anime_character.Filter{AnimeIDs: []string{"a1"}, AnimeStatus: "Currently Airing"}, characterFilters[0])
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		backfill, _ := newBackfill(Options{Types: []string{"episodes"}})
+		_, err := backfill.Run(ctx)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,35 @@
+package image_backfill
+
+import "time"
+
+// entity types a backfill can run over, in the order they are sent
+const (
+	TypeAnime      = "anime"
+	TypeCharacters = "characters"
+	TypeStaff      = "staff"
+)
+
+var Types = []string{TypeAnime, TypeCharacters, TypeStaff}
+
+type Options struct {
+	// Types limits the backfill to some of Types
+	Types     []string
+	BatchSize int
+	// RatePerSecond caps how many image requests are produced per second, 0 produces as fast as kafka accepts them
+	RatePerSecond float64
+	// DryRun reports the requests that would be produced without producing them
+	DryRun bool
+	// UpdatedSince, IDs and Status select the anime to backfill, characters and staff are selected by the
+	// anime they belong to. Zero values select all of them
+	UpdatedSince *time.Time
+	IDs          []string
+	Status       string
+}
+
+type Result struct {
+	Scanned int64
+	// Sent counts the requests produced, or that would have been produced in a dry run
+	Sent int64
+	// Skipped counts rows without an image url, they have nothing to sync
+	Skipped int64
+}
@@ -0,0 +1,197 @@
+package outbox_relay
+
+import (
+	"context"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/outbox"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	BatchSize    int
+	PollInterval time.Duration
+	// Retention is how long sent messages are kept before Cleanup deletes them
+	Retention time.Duration
+	// CleanupInterval is how often Run deletes the sent messages older than Retention
+	CleanupInterval time.Duration
+}
+
+type OutboxRelay interface {
+	Run(ctx context.Context) error
+	RelayBatch(ctx context.Context) (int, error)
+	Cleanup(ctx context.Context) (int64, error)
+}
+
+type OutboxRelayImpl struct {
+	Transactor db.Transactor
+	Repository outbox.OutboxRepositoryImpl
+	Options    Options
+	Producer   func(ctx context.Context, topic string, message *kafka.Message) error
+}
+
+func NewOutboxRelay(opt Options, db *db.DB, producer func(ctx context.Context, topic string, message *kafka.Message) error) OutboxRelay {
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 100
+	}
+	if opt.PollInterval <= 0 {
+		opt.PollInterval = time.Second
+	}
+	if opt.Retention <= 0 {
+		opt.Retention = 24 * time.Hour
+	}
+	if opt.CleanupInterval <= 0 {
+		opt.CleanupInterval = time.Minute
+	}
+
+	return &OutboxRelayImpl{
+		Transactor: db,
+		Repository: outbox.NewOutboxRepository(db),
+		Options:    opt,
+		Producer:   producer,
+	}
+}
+
+// Run relays pending outbox rows until ctx is cancelled. Full batches are followed
+// immediately by the next one, otherwise the relay waits for the poll interval. Sent rows
+// older than the retention are deleted every cleanup interval
+func (r *OutboxRelayImpl) Run(ctx context.Context) error {
+	log := logger.FromCtx(ctx)
+	log.Info("Starting outbox relay", zap.Int("batchSize", r.Options.BatchSize), zap.Duration("pollInterval", r.Options.PollInterval))
+
+	var cleaned time.Time
+	for {
+		if time.Since(cleaned) >= r.Options.CleanupInterval {
+			cleaned = time.Now()
+			if _, err := r.Cleanup(ctx); err != nil {
+				log.Error("Error cleaning up outbox", zap.Error(err))
+			}
+		}
+
+		// a started batch is finished on shutdown so the rows it published are marked as sent
+		sent, err := r.RelayBatch(context.WithoutCancel(ctx))
+		if err != nil {
+			log.Error("Error relaying outbox batch", zap.Error(err))
+		}
+
+		if err == nil && sent == r.Options.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Stopping outbox relay")
+			return nil
+		case <-time.After(r.Options.PollInterval):
+		}
+	}
+}
+
+// RelayBatch publishes one batch of pending rows in id order and marks them sent.
+// Publishing stops at the first failure so messages are never reordered, the failed row
+// keeps its place and is retried with the next batch, however long kafka is unavailable. A row
+// kafka rejects for good, e.g. one that is too large, is dead lettered instead so it can't block
+// the rows behind it, and the batch goes on.
+// Relays take turns through the relay lock, a relay that doesn't get it skips the batch.
+// No transaction is open while publishing, the rows are marked in a short one afterwards
+func (r *OutboxRelayImpl) RelayBatch(ctx context.Context) (int, error) {
+	log := logger.FromCtx(ctx)
+
+	unlock, locked, err := r.Repository.TryLock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !locked {
+		log.Debug("Another outbox relay is publishing, skipping batch")
+		return 0, nil
+	}
+	defer unlock()
+
+	messages, err := r.Repository.FetchPending(r.Options.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var sentIDs []int64
+	var failures []failure
+	for _, message := range messages {
+		headers := make([]kafka.Header, 0, len(message.Headers))
+		for k, v := range message.Headers {
+			headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+
+		err = r.Producer(ctx, message.Topic, &kafka.Message{
+			Key:     message.Key,
+			Value:   message.Payload,
+			Headers: headers,
+		})
+		if err != nil {
+			if retryable.IsPermanent(err) {
+				log.Error("Dead lettering outbox message", zap.Int64("id", message.ID), zap.String("topic", message.Topic), zap.Int("attempts", message.Attempts+1), zap.Error(err))
+				failures = append(failures, failure{id: message.ID, err: err, dead: true})
+				continue
+			}
+
+			log.Warn("Failed to publish outbox message", zap.Int64("id", message.ID), zap.String("topic", message.Topic), zap.Int("attempts", message.Attempts+1), zap.Error(err))
+			failures = append(failures, failure{id: message.ID, err: err})
+			break
+		}
+		sentIDs = append(sentIDs, message.ID)
+	}
+
+	err = r.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		repository := r.Repository.WithTx(tx)
+		for _, failed := range failures {
+			markFailed := repository.MarkFailed
+			if failed.dead {
+				markFailed = repository.MarkDead
+			}
+			if err := markFailed(failed.id, failed.err); err != nil {
+				return err
+			}
+		}
+		return repository.MarkSent(sentIDs)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := len(sentIDs)
+	if sent > 0 {
+		log.Info("Relayed outbox messages", zap.Int("count", sent))
+	}
+	return sent, nil
+}
+
+// failure is a row RelayBatch failed to publish
+type failure struct {
+	id   int64
+	err  error
+	dead bool
+}
+
+// Cleanup deletes the messages sent before the retention, in batches of BatchSize
+func (r *OutboxRelayImpl) Cleanup(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-r.Options.Retention)
+
+	var deleted int64
+	for ctx.Err() == nil {
+		count, err := r.Repository.DeleteSent(before, r.Options.BatchSize)
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+		if count < int64(r.Options.BatchSize) {
+			break
+		}
+	}
+
+	if deleted > 0 {
+		logger.FromCtx(ctx).Info("Deleted sent outbox messages", zap.Int64("count", deleted))
+	}
+	return deleted, nil
+}
@@ -0,0 +1,244 @@
+package outbox_relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/outbox"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transaction(ctx context.Context, fn func(ctx context.Context, tx *db.DB) error) error {
+	return fn(ctx, nil)
+}
+
+type fakeOutboxRepository struct {
+	messages []outbox.Message
+	locked   bool
+}
+
+func (r *fakeOutboxRepository) TryLock(ctx context.Context) (func(), bool, error) {
+	if r.locked {
+		return nil, false, nil
+	}
+	r.locked = true
+	return func() { r.locked = false }, true, nil
+}
+
+func (r *fakeOutboxRepository) Enqueue(message *outbox.Message) error {
+	message.ID = int64(len(r.messages) + 1)
+	r.messages = append(r.messages, *message)
+	return nil
+}
+
+func (r *fakeOutboxRepository) EnqueueMany(messages []*outbox.Message) error {
+	for _, message := range messages {
+		if err := r.Enqueue(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepository) FetchPending(limit int) ([]outbox.Message, error) {
+	var pending []outbox.Message
+	for _, message := range r.messages {
+		if message.SentAt == nil && message.DeadAt == nil && len(pending) < limit {
+			pending = append(pending, message)
+		}
+	}
+	return pending, nil
+}
+
+func (r *fakeOutboxRepository) MarkSent(ids []int64) error {
+	now := time.Now()
+	for _, id := range ids {
+		r.messages[id-1].SentAt = &now
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepository) MarkFailed(id int64, cause error) error {
+	lastError := cause.Error()
+	r.messages[id-1].Attempts++
+	r.messages[id-1].LastError = &lastError
+	return nil
+}
+
+func (r *fakeOutboxRepository) MarkDead(id int64, cause error) error {
+	now := time.Now()
+	if err := r.MarkFailed(id, cause); err != nil {
+		return err
+	}
+	r.messages[id-1].DeadAt = &now
+	return nil
+}
+
+func (r *fakeOutboxRepository) DeleteSent(before time.Time, limit int) (int64, error) {
+	var kept []outbox.Message
+	var deleted int64
+	for _, message := range r.messages {
+		if message.SentAt != nil && message.SentAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+		kept = append(kept, message)
+	}
+	r.messages = kept
+	return deleted, nil
+}
+
+func (r *fakeOutboxRepository) WithTx(tx *db.DB) outbox.OutboxRepositoryImpl {
+	return r
+}
+
+func TestRelayBatch(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	repository := &fakeOutboxRepository{}
+	for _, topic := range []string{"algolia-sync", "image-sync", "algolia-sync"} {
+		require.NoError(t, repository.Enqueue(&outbox.Message{Topic: topic, Payload: []byte(topic)}))
+	}
+
+	var published []string
+	brokerDown := true
+	relay := &OutboxRelayImpl{
+		Transactor: fakeTransactor{},
+		Repository: repository,
+		Options:    Options{BatchSize: 10},
+		Producer: func(ctx context.Context, topic string, message *kafka.Message) error {
+			if topic == "image-sync" && brokerDown {
+				return errors.New("broker unavailable")
+			}
+			published = append(published, topic)
+			return nil
+		},
+	}
+
+	t.Run("StopsAtFirstFailure", func(t *testing.T) {
+		sent, err := relay.RelayBatch(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, []string{"algolia-sync"}, published)
+		assert.NotNil(t, repository.messages[0].SentAt)
+		assert.Nil(t, repository.messages[1].SentAt)
+		assert.Equal(t, 1, repository.messages[1].Attempts)
+		assert.Nil(t, repository.messages[2].SentAt, "later messages must wait for the failed one")
+	})
+
+	t.Run("SkipsWhileAnotherRelayPublishes", func(t *testing.T) {
+		brokerDown = false
+		repository.locked = true
+
+		sent, err := relay.RelayBatch(ctx)
+		require.NoError(t, err)
+
+		assert.Zero(t, sent)
+		assert.Equal(t, []string{"algolia-sync"}, published)
+		repository.locked = false
+	})
+
+	t.Run("RetriesFailedMessageInOrder", func(t *testing.T) {
+		brokerDown = false
+
+		sent, err := relay.RelayBatch(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, sent)
+		assert.Equal(t, []string{"algolia-sync", "image-sync", "algolia-sync"}, published)
+		for _, message := range repository.messages {
+			assert.NotNil(t, message.SentAt)
+		}
+		assert.False(t, repository.locked, "the relay lock is released after the batch")
+	})
+}
+
+func TestRelayBatchDeadLetters(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	repository := &fakeOutboxRepository{}
+	for _, topic := range []string{"too-large", "algolia-sync", "image-sync"} {
+		require.NoError(t, repository.Enqueue(&outbox.Message{Topic: topic, Payload: []byte(topic)}))
+	}
+
+	var published []string
+	brokerDown := true
+	relay := &OutboxRelayImpl{
+		Transactor: fakeTransactor{},
+		Repository: repository,
+		Options:    Options{BatchSize: 10},
+		Producer: func(ctx context.Context, topic string, message *kafka.Message) error {
+			if topic == "too-large" {
+				return kafka.NewError(kafka.ErrMsgSizeTooLarge, "Broker: Message size too large", false)
+			}
+			if topic == "image-sync" && brokerDown {
+				return kafka.NewError(kafka.ErrAllBrokersDown, "Local: All broker connections are down", false)
+			}
+			published = append(published, topic)
+			return nil
+		},
+	}
+
+	sent, err := relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "a message kafka rejects is dead lettered right away")
+	assert.Equal(t, []string{"algolia-sync"}, published)
+	assert.Equal(t, 1, repository.messages[0].Attempts)
+	assert.NotNil(t, repository.messages[0].DeadAt)
+	assert.Nil(t, repository.messages[0].SentAt)
+
+	for i := 0; i < 20; i++ {
+		sent, err = relay.RelayBatch(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, sent)
+	}
+	assert.Equal(t, 21, repository.messages[2].Attempts)
+	assert.Nil(t, repository.messages[2].DeadAt, "messages are not dead lettered while kafka is unavailable")
+
+	brokerDown = false
+	sent, err = relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"algolia-sync", "image-sync"}, published, "dead lettered messages are not retried")
+}
+
+func TestCleanup(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	repository := &fakeOutboxRepository{messages: []outbox.Message{
+		{ID: 1, Topic: "algolia-sync", SentAt: &old},
+		{ID: 2, Topic: "algolia-sync", SentAt: &old},
+		{ID: 3, Topic: "algolia-sync", SentAt: &old},
+		{ID: 4, Topic: "too-large", DeadAt: &old},
+		{ID: 5, Topic: "algolia-sync", SentAt: &recent},
+		{ID: 6, Topic: "algolia-sync"},
+	}}
+	relay := &OutboxRelayImpl{
+		Transactor: fakeTransactor{},
+		Repository: repository,
+		Options:    Options{BatchSize: 2, Retention: 24 * time.Hour},
+	}
+
+	deleted, err := relay.Cleanup(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), deleted)
+	var kept []int64
+	for _, message := range repository.messages {
+		kept = append(kept, message.ID)
+	}
+	assert.Equal(t, []int64{4, 5, 6}, kept, "dead lettered, recently sent and pending messages are kept")
+}
@@ -4,9 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
 	"log"
+	"time"
 )
 
+// defaultMaxRetries is used when no retry policy is configured
+const defaultMaxRetries = 3
+
+// Options configures how often and how fast a failing message is retried, zero intervals and
+// multiplier use the exponential backoff defaults
+type Options struct {
+	MaxRetries      int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+}
+
 type ProcessorFunc[T any] func(ctx context.Context, data T) error
 
 type ProcessorImpl[T any] interface {
@@ -15,10 +29,17 @@ type ProcessorImpl[T any] interface {
 }
 
 type Processor[T any] struct {
+	Options Options
 }
 
 func NewProcessor[T any]() *Processor[T] {
-	return &Processor[T]{}
+	return NewProcessorWithOptions[T](Options{MaxRetries: defaultMaxRetries})
+}
+
+func NewProcessorWithOptions[T any](opt Options) *Processor[T] {
+	return &Processor[T]{
+		Options: opt,
+	}
 }
 
 func (p *Processor[T]) Parse(ctx context.Context, payload string) (*T, error) {
@@ -27,7 +48,7 @@ func (p *Processor[T]) Parse(ctx context.Context, payload string) (*T, error) {
 	var data T
 	err := json.Unmarshal([]byte(payload), &data)
 	if err != nil {
-		return nil, err
+		return nil, retryable.NewPermanent(err)
 	}
 	return &data, nil
 
@@ -42,13 +63,32 @@ func (p *Processor[T]) Process(ctx context.Context, payload string, fn Processor
 	// do something with data
 
 	operation := func() error {
-		return fn(ctx, *data)
+		err := fn(ctx, *data)
+		if retryable.IsPermanent(err) {
+			// retrying won't help, e.g. the payload has a date that doesn't parse
+			return backoff.Permanent(err)
+		}
+		return err
 	}
 
-	err = backoff.Retry(operation, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 3))
+	err = backoff.Retry(operation, backoff.WithContext(backoff.WithMaxRetries(p.backOff(), uint64(p.Options.MaxRetries)), ctx))
 	if err != nil {
 		// Handle error.
 		return err
 	}
 	return nil
 }
+
+func (p *Processor[T]) backOff() *backoff.ExponentialBackOff {
+	exponentialBackOff := backoff.NewExponentialBackOff()
+	if p.Options.InitialInterval != 0 {
+		exponentialBackOff.InitialInterval = p.Options.InitialInterval
+	}
+	if p.Options.MaxInterval != 0 {
+		exponentialBackOff.MaxInterval = p.Options.MaxInterval
+	}
+	if p.Options.Multiplier != 0 {
+		exponentialBackOff.Multiplier = p.Options.Multiplier
+	}
+	return exponentialBackOff
+}
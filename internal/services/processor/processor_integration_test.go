@@ -157,7 +157,7 @@ func TestProcessorIntegration(t *testing.T) {
 
 		// Process and save to database
 		processorFunc := func(ctx context.Context, data anime.Anime) error {
-			return repository.Upsert(&data, nil)
+			return repository.Upsert(&data)
 		}
 
 		err = processor.Process(context.Background(), string(payloadJSON), processorFunc)
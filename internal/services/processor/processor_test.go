@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/weeb-vip/anime-sync/internal/retryable"
+)
+
+func TestProcessRetryPolicy(t *testing.T) {
+	processor := NewProcessorWithOptions[TestAnime](Options{MaxRetries: 2, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond})
+	payload := `{"id":"test-1","title":"Test Anime","episode":1}`
+
+	t.Run("RetriesRetriableErrors", func(t *testing.T) {
+		calls := 0
+		err := processor.Process(context.Background(), payload, func(ctx context.Context, data TestAnime) error {
+			calls++
+			return errors.New("connection reset")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("StopsOnPermanentErrors", func(t *testing.T) {
+		calls := 0
+		err := processor.Process(context.Background(), payload, func(ctx context.Context, data TestAnime) error {
+			calls++
+			return retryable.NewPermanent(errors.New("invalid season"))
+		})
+		assert.True(t, retryable.IsPermanent(err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ParseErrorsArePermanent", func(t *testing.T) {
+		err := processor.Process(context.Background(), "invalid json", func(ctx context.Context, data TestAnime) error {
+			return nil
+		})
+		assert.True(t, retryable.IsPermanent(err))
+	})
+}
@@ -2,16 +2,10 @@ package pulsar_anime_postgres_processor
 
 import (
 	"context"
-	"encoding/json"
-	"strconv"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
 	"github.com/weeb-vip/anime-sync/internal/db"
-	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
-	"github.com/weeb-vip/anime-sync/internal/logger"
-	"github.com/weeb-vip/anime-sync/internal/producer"
-	"go.uber.org/zap"
-	"strings"
-	"time"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
 )
 
 type Options struct {
@@ -20,256 +14,20 @@ type Options struct {
 
 type PulsarAnimePostgresProcessorImpl interface {
 	Process(ctx context.Context, data Payload) error
-	parseToEntity(ctx context.Context, data Schema) (*anime.Anime, error)
 }
 
+// PulsarAnimePostgresProcessor adapts anime change events consumed from Pulsar to the sync service
 type PulsarAnimePostgresProcessor struct {
-	Repository    anime.AnimeRepositoryImpl
-	Options       Options
-	Producer      producer.Producer[Schema]
-	ProducerImage producer.Producer[ImageSchema]
-	KafkaProducer func(ctx context.Context, message *kafka.Message) error
+	Service *anime_processor.AnimeSyncService
 }
 
-func NewPulsarAnimePostgresProcessor(opt Options, db *db.DB, producer producer.Producer[Schema], producerImage producer.Producer[ImageSchema], kafkaProducer func(ctx context.Context, message *kafka.Message) error) PulsarAnimePostgresProcessorImpl {
+func NewPulsarAnimePostgresProcessor(opt Options, db *db.DB, search publisher.Publisher[ProducerPayload], images publisher.Publisher[ImagePayload]) PulsarAnimePostgresProcessorImpl {
+	options := anime_processor.Options{NoErrorOnDelete: opt.NoErrorOnDelete}
 	return &PulsarAnimePostgresProcessor{
-		Repository:    anime.NewAnimeRepository(db),
-		Options:       opt,
-		Producer:      producer,
-		ProducerImage: producerImage,
-		KafkaProducer: kafkaProducer,
+		Service: anime_processor.NewAnimeSyncService(options, db, search, images),
 	}
 }
 
 func (p *PulsarAnimePostgresProcessor) Process(ctx context.Context, data Payload) error {
-	log := logger.FromCtx(ctx)
-
-	if data.Before == nil && data.After != nil {
-		// add to db
-		newAnime, err := p.parseToEntity(ctx, *data.After)
-		if err != nil {
-			return err
-		}
-		err = p.Repository.Upsert(newAnime, nil)
-		if err != nil {
-			return err
-		}
-		jsonAnime, err := json.Marshal(ProducerPayload{
-			Action: CreateAction,
-			Data:   data.After,
-		})
-		if err != nil {
-			return err
-		}
-		var title string
-		if data.After.TitleEn != nil {
-			title = strings.ToLower(*data.After.TitleEn)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else if data.After.TitleJp != nil {
-			title = strings.ToLower(*data.After.TitleJp)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else {
-			return nil
-		}
-		imageURL := ""
-		if data.After.ImageUrl != nil {
-			imageURL = *data.After.ImageUrl
-		}
-		payload := &ImagePayload{
-			Data: ImageSchema{
-				Name: title,
-				URL:  imageURL,
-				Type: DataTypeAnime,
-			},
-		}
-
-		jsonImage, err := json.Marshal(payload)
-		if err != nil {
-			return err
-		}
-
-		err = p.Producer.Send(ctx, jsonAnime)
-		if err != nil {
-			return err
-		}
-
-		if data.After.ImageUrl != nil {
-			log.Info("Sending update to producer", zap.String("title", title), zap.String("imageURL", imageURL))
-			log.Info("Sending image to Kafka", zap.String("imageURL", *data.After.ImageUrl))
-			err = p.KafkaProducer(ctx, &kafka.Message{
-				Value: jsonImage,
-			})
-
-			if err != nil {
-				return err
-			}
-
-		}
-	}
-
-	if data.After == nil && data.Before != nil {
-		// delete from db
-		oldAnime, err := p.parseToEntity(ctx, *data.Before)
-		if err != nil {
-			return err
-		}
-
-		err = p.Repository.Delete(oldAnime)
-		if err != nil {
-			if p.Options.NoErrorOnDelete {
-				log.Warn("WARN: error deleting from db: ", zap.Error(err))
-				return nil
-			} else {
-				return err
-			}
-		}
-		return nil
-
-	}
-
-	if data.Before != nil && data.After != nil {
-		// update db
-		newAnime, err := p.parseToEntity(ctx, *data.After)
-		if err != nil {
-			return err
-		}
-		var oldTitle *string
-		if data.Before.TitleEn != nil && *data.Before.TitleEn != *data.After.TitleEn {
-			oldTitle = data.Before.TitleEn
-		}
-		if data.Before.TitleEn == nil && data.Before.TitleJp != nil && *data.Before.TitleJp != *data.After.TitleJp {
-			oldTitle = data.Before.TitleJp
-		}
-		err = p.Repository.Upsert(newAnime, oldTitle)
-		if err != nil {
-			return err
-		}
-		// convert new anime to json
-		jsonAnime, err := json.Marshal(ProducerPayload{
-			Action: CreateAction,
-			Data:   data.After,
-		})
-		if err != nil {
-			return err
-		}
-		var title string
-		if data.After.TitleEn != nil {
-			title = strings.ToLower(*data.After.TitleEn)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else if data.After.TitleJp != nil {
-			title = strings.ToLower(*data.After.TitleJp)
-			title = strings.ReplaceAll(title, " ", "_")
-		} else {
-			return nil
-		}
-		imageURL := ""
-		if data.After.ImageUrl != nil {
-			imageURL = *data.After.ImageUrl
-		}
-		payload := &ImagePayload{
-			Data: ImageSchema{
-				Name: title,
-				URL:  imageURL,
-				Type: DataTypeAnime,
-			},
-		}
-
-		jsonImage, err := json.Marshal(payload)
-		if err != nil {
-			return err
-		}
-		err = p.Producer.Send(ctx, jsonAnime)
-		if err != nil {
-			return err
-		}
-		if data.After.ImageUrl != nil {
-			log.Info("Sending update to producer", zap.String("title", title), zap.String("imageURL", imageURL))
-			log.Info("Sending image to Kafka producer", zap.String("title", title), zap.String("imageURL", imageURL))
-			err = p.KafkaProducer(ctx, &kafka.Message{
-				Value: jsonImage,
-			})
-
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	if data.Before != nil && data.After == nil {
-		log.Warn("WARN: data.After is nil, skipping update")
-	}
-
-	return nil
-
-}
-
-func (p *PulsarAnimePostgresProcessor) parseToEntity(ctx context.Context, data Schema) (*anime.Anime, error) {
-	log := logger.FromCtx(ctx)
-	var newAnime anime.Anime
-
-	var animeStartDate *string
-	if data.StartDate != nil {
-		startDate, err := time.Parse(time.RFC3339, *data.StartDate)
-		if err != nil {
-			return nil, err
-		}
-		animeStartDateFormatted := startDate.Format("2006-01-02 15:04:05")
-		animeStartDate = &animeStartDateFormatted
-
-	}
-
-	var animeEndDate *string
-	if data.EndDate != nil {
-		endDate, err := time.Parse(time.RFC3339, *data.EndDate)
-		if err != nil {
-			return nil, err
-		}
-		animeEndDateFormatted := endDate.Format("2006-01-02 15:04:05")
-		animeEndDate = &animeEndDateFormatted
-	}
-	var record_type *anime.RECORD_TYPE
-	if data.Type != nil {
-		record := anime.RECORD_TYPE(*data.Type)
-		record_type = &record
-
-	}
-
-	newAnime.ID = data.ID
-	newAnime.Ranking = data.Ranking
-	newAnime.AnidbID = data.AnidbID
-	newAnime.TheTVDBID = data.TheTVDBID
-	newAnime.Type = record_type
-	newAnime.TitleEn = data.TitleEn
-	newAnime.TitleJp = data.TitleJp
-	newAnime.TitleRomaji = data.TitleRomaji
-	newAnime.TitleKanji = data.TitleKanji
-	newAnime.TitleSynonyms = data.TitleSynonyms
-	newAnime.ImageURL = data.ImageUrl
-	newAnime.Synopsis = data.Synopsis
-	newAnime.Episodes = data.Episodes
-	newAnime.Status = data.Status
-	newAnime.StartDate = animeStartDate
-	newAnime.EndDate = animeEndDate
-	newAnime.Genres = data.Genres
-	newAnime.Duration = data.Duration
-	newAnime.Broadcast = data.Broadcast
-	newAnime.Source = data.Source
-	newAnime.Licensors = data.Licensors
-	newAnime.Studios = data.Studios
-
-	// Convert rating from string to float64
-	var animeRating *float64
-	if data.Rating != nil {
-		if ratingFloat, err := strconv.ParseFloat(*data.Rating, 64); err == nil {
-			animeRating = &ratingFloat
-		} else {
-			log.Warn("Failed to parse rating as float", zap.String("rating", *data.Rating), zap.Error(err))
-		}
-	}
-	newAnime.Rating = animeRating
-
-	newAnime.CreatedAt = time.Now()
-	newAnime.UpdatedAt = time.Now()
-
-	return &newAnime, nil
+	return p.Service.Sync(ctx, data)
 }
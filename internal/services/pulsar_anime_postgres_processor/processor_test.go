@@ -0,0 +1,14 @@
+package pulsar_anime_postgres_processor
+
+import (
+	"testing"
+
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor/synctest"
+)
+
+func TestPulsarAdapter(t *testing.T) {
+	synctest.Run(t, func(service *anime_processor.AnimeSyncService) synctest.Adapter {
+		return (&PulsarAnimePostgresProcessor{Service: service}).Process
+	})
+}
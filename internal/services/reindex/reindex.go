@@ -0,0 +1,265 @@
+package reindex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_season"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_tag"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/reindex_checkpoint"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/ratelimit"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_season_processor"
+	"go.uber.org/zap"
+)
+
+const defaultBatchSize = 500
+
+type Reindexer interface {
+	Run(ctx context.Context) error
+}
+
+type ReindexerImpl struct {
+	AnimeRepository      anime.AnimeRepositoryImpl
+	SeasonRepository     anime_season.AnimeSeasonRepositoryImpl
+	AnimeTagRepository   anime_tag.AnimeTagRepositoryImpl
+	CheckpointRepository reindex_checkpoint.ReindexCheckpointRepositoryImpl
+	// TitleHistoryRepository provides the old titles of anime documents
+	TitleHistoryRepository anime_title_history.AnimeTitleHistoryRepositoryImpl
+	Options                Options
+	// Search gets the encoded anime and season documents, they share the algolia topic
+	Search publisher.Publisher[json.RawMessage]
+	Report func(progress Progress)
+}
+
+func NewReindexer(opt Options, db *db.DB, search publisher.Publisher[json.RawMessage], report func(progress Progress)) Reindexer {
+	return &ReindexerImpl{
+		AnimeRepository:        anime.NewAnimeRepository(db),
+		SeasonRepository:       anime_season.NewAnimeSeasonRepository(db),
+		AnimeTagRepository:     anime_tag.NewAnimeTagRepository(db),
+		CheckpointRepository:   reindex_checkpoint.NewReindexCheckpointRepository(db),
+		TitleHistoryRepository: anime_title_history.NewAnimeTitleHistoryRepository(db),
+		Options:                opt,
+		Search:                 search,
+		Report:                 report,
+	}
+}
+
+// document is a search document ready to publish with the id of the row it was built from
+type document struct {
+	id      string
+	message publisher.Message[json.RawMessage]
+}
+
+// encoded returns message with its value encoded, so documents of different types can be published together
+func encoded[T any](message publisher.Message[T]) (publisher.Message[json.RawMessage], error) {
+	value, err := json.Marshal(message.Value)
+	if err != nil {
+		return publisher.Message[json.RawMessage]{}, err
+	}
+	return publisher.Message[json.RawMessage]{
+		Key:           message.Key,
+		Action:        message.Action,
+		Entity:        message.Entity,
+		SchemaVersion: message.SchemaVersion,
+		Value:         value,
+	}, nil
+}
+
+// Run publishes a search document for every selected row, table by table. Progress is checkpointed
+// after every batch, a failed or interrupted run continues after the last published row with Resume.
+// Documents of the batch that was running may be published twice, the search index upserts them
+func (r *ReindexerImpl) Run(ctx context.Context) error {
+	selected := make(map[string]bool, len(r.Options.Tables))
+	for _, table := range r.Options.Tables {
+		if table != TableAnime && table != TableAnimeSeasons {
+			return fmt.Errorf("unknown table %q, expected one of %v", table, Tables)
+		}
+		selected[table] = true
+	}
+
+	pace := ratelimit.New(r.Options.RatePerSecond)
+	for _, table := range Tables {
+		if len(selected) > 0 && !selected[table] {
+			continue
+		}
+		if err := r.reindexTable(ctx, table, pace); err != nil {
+			return fmt.Errorf("reindex %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (r *ReindexerImpl) reindexTable(ctx context.Context, table string, pace *ratelimit.Limiter) error {
+	log := logger.FromCtx(ctx).With(zap.String("table", table), zap.String("checkpoint", r.Options.Checkpoint))
+
+	checkpoint := &reindex_checkpoint.ReindexCheckpoint{Name: r.Options.Checkpoint, Table: table}
+	if r.Options.Resume {
+		stored, err := r.CheckpointRepository.Find(r.Options.Checkpoint, table)
+		if err != nil {
+			return err
+		}
+		if stored != nil && stored.CompletedAt != nil {
+			log.Info("Table already reindexed, skipping", zap.Int64("published", stored.Published))
+			r.report(Progress{Table: table, Published: stored.Published, Total: stored.Published, LastID: stored.LastID, Done: true})
+			return nil
+		}
+		if stored != nil {
+			log.Info("Resuming reindex", zap.String("lastID", stored.LastID), zap.Int64("published", stored.Published))
+			checkpoint = stored
+		}
+	}
+
+	// the total covers rows published before the checkpoint as well, like Published does
+	total, err := r.count(table)
+	if err != nil {
+		return err
+	}
+
+	batchSize := r.Options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	for {
+		documents, err := r.page(table, checkpoint.LastID, batchSize)
+		if err != nil {
+			return r.stop(ctx, checkpoint, err)
+		}
+
+		for _, document := range documents {
+			if err := pace.Wait(ctx); err != nil {
+				return r.stop(ctx, checkpoint, err)
+			}
+			if err := r.Search.Publish(ctx, document.message); err != nil {
+				return r.stop(ctx, checkpoint, err)
+			}
+			checkpoint.LastID = document.id
+			checkpoint.Published++
+		}
+
+		if len(documents) > 0 {
+			if err := r.CheckpointRepository.Save(checkpoint); err != nil {
+				return err
+			}
+			log.Info("Reindexed batch", zap.Int64("published", checkpoint.Published), zap.Int64("total", total), zap.String("lastID", checkpoint.LastID))
+			r.report(Progress{Table: table, Published: checkpoint.Published, Total: total, LastID: checkpoint.LastID})
+		}
+
+		if len(documents) < batchSize {
+			break
+		}
+	}
+
+	completedAt := time.Now()
+	checkpoint.CompletedAt = &completedAt
+	if err := r.CheckpointRepository.Save(checkpoint); err != nil {
+		return err
+	}
+	log.Info("Table reindexed", zap.Int64("published", checkpoint.Published))
+	r.report(Progress{Table: table, Published: checkpoint.Published, Total: checkpoint.Published, LastID: checkpoint.LastID, Done: true})
+	return nil
+}
+
+// stop saves how far the table got before returning err, so the run can be resumed after the last published row
+func (r *ReindexerImpl) stop(ctx context.Context, checkpoint *reindex_checkpoint.ReindexCheckpoint, err error) error {
+	if saveErr := r.CheckpointRepository.Save(checkpoint); saveErr != nil {
+		logger.FromCtx(ctx).Error("Failed to save reindex checkpoint", zap.String("table", checkpoint.Table), zap.Error(saveErr))
+	}
+	return err
+}
+
+func (r *ReindexerImpl) report(progress Progress) {
+	if r.Report != nil {
+		r.Report(progress)
+	}
+}
+
+func (r *ReindexerImpl) animeFilter() anime.Filter {
+	return anime.Filter{
+		UpdatedSince: r.Options.UpdatedSince,
+		IDs:          r.Options.IDs,
+		Status:       r.Options.Status,
+	}
+}
+
+func (r *ReindexerImpl) seasonFilter() anime_season.Filter {
+	return anime_season.Filter{
+		UpdatedSince: r.Options.UpdatedSince,
+		AnimeIDs:     r.Options.IDs,
+		AnimeStatus:  r.Options.Status,
+	}
+}
+
+func (r *ReindexerImpl) count(table string) (int64, error) {
+	if table == TableAnimeSeasons {
+		return r.SeasonRepository.Count(r.seasonFilter())
+	}
+	return r.AnimeRepository.Count(r.animeFilter())
+}
+
+// page builds the search documents of the next batch of rows after afterID
+func (r *ReindexerImpl) page(table string, afterID string, limit int) ([]document, error) {
+	if table == TableAnimeSeasons {
+		return r.seasonPage(afterID, limit)
+	}
+	return r.animePage(afterID, limit)
+}
+
+func (r *ReindexerImpl) animePage(afterID string, limit int) ([]document, error) {
+	animes, err := r.AnimeRepository.FindPage(r.animeFilter(), afterID, limit)
+	if err != nil || len(animes) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, len(animes))
+	for i, a := range animes {
+		ids[i] = a.ID
+	}
+	tagNames, err := r.AnimeTagRepository.GetTagNamesForAnimes(ids)
+	if err != nil {
+		return nil, err
+	}
+	oldTitles, err := r.TitleHistoryRepository.GetOldTitlesForAnimes(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]document, 0, len(animes))
+	for i := range animes {
+		schema := anime_processor.SchemaFromEntity(&animes[i])
+		schema.Tags = tagNames[animes[i].ID]
+		schema.OldTitles = anime_processor.PreviousTitles(schema, oldTitles[animes[i].ID])
+
+		message, err := encoded(anime_processor.SearchMessage(anime_processor.UpdateAction, schema))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document{id: animes[i].ID, message: message})
+	}
+	return documents, nil
+}
+
+func (r *ReindexerImpl) seasonPage(afterID string, limit int) ([]document, error) {
+	seasons, err := r.SeasonRepository.FindPage(r.seasonFilter(), afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]document, 0, len(seasons))
+	for i := range seasons {
+		message, err := encoded(anime_season_processor.SearchMessage(anime_season_processor.UpdateAction, anime_season_processor.SchemaFromEntity(&seasons[i])))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document{id: seasons[i].ID, message: message})
+	}
+	return documents, nil
+}
@@ -0,0 +1,293 @@
+package reindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_season"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_tag"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_title_history"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/reindex_checkpoint"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_season_processor"
+)
+
+type fakeAnimeRepository struct {
+	anime.AnimeRepositoryImpl
+	animes  []anime.Anime
+	filters []anime.Filter
+}
+
+func (r *fakeAnimeRepository) FindPage(filter anime.Filter, afterID string, limit int) ([]anime.Anime, error) {
+	r.filters = append(r.filters, filter)
+	var page []anime.Anime
+	for _, a := range r.animes {
+		if a.ID > afterID && len(page) < limit {
+			page = append(page, a)
+		}
+	}
+	return page, nil
+}
+
+func (r *fakeAnimeRepository) Count(filter anime.Filter) (int64, error) {
+	return int64(len(r.animes)), nil
+}
+
+type fakeSeasonRepository struct {
+	anime_season.AnimeSeasonRepositoryImpl
+	seasons []anime_season.AnimeSeason
+}
+
+func (r *fakeSeasonRepository) FindPage(filter anime_season.Filter, afterID string, limit int) ([]anime_season.AnimeSeason, error) {
+	var page []anime_season.AnimeSeason
+	for _, s := range r.seasons {
+		if s.ID > afterID && len(page) < limit {
+			page = append(page, s)
+		}
+	}
+	return page, nil
+}
+
+func (r *fakeSeasonRepository) Count(filter anime_season.Filter) (int64, error) {
+	return int64(len(r.seasons)), nil
+}
+
+type fakeAnimeTagRepository struct {
+	anime_tag.AnimeTagRepositoryImpl
+	tags map[string][]string
+}
+
+func (r *fakeAnimeTagRepository) GetTagNamesForAnimes(animeIDs []string) (map[string][]string, error) {
+	tagNames := map[string][]string{}
+	for _, id := range animeIDs {
+		if tags, ok := r.tags[id]; ok {
+			tagNames[id] = tags
+		}
+	}
+	return tagNames, nil
+}
+
+type fakeTitleHistoryRepository struct {
+	anime_title_history.AnimeTitleHistoryRepositoryImpl
+	oldTitles map[string][]string
+}
+
+func (r *fakeTitleHistoryRepository) GetOldTitlesForAnimes(animeIDs []string) (map[string][]string, error) {
+	return r.oldTitles, nil
+}
+
+type fakeCheckpointRepository struct {
+	checkpoints map[string]reindex_checkpoint.ReindexCheckpoint
+}
+
+func (r *fakeCheckpointRepository) Find(name string, table string) (*reindex_checkpoint.ReindexCheckpoint, error) {
+	checkpoint, ok := r.checkpoints[name+"/"+table]
+	if !ok {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+func (r *fakeCheckpointRepository) Save(checkpoint *reindex_checkpoint.ReindexCheckpoint) error {
+	r.checkpoints[checkpoint.Name+"/"+checkpoint.Table] = *checkpoint
+	return nil
+}
+
+func TestReindexer(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	titles := map[string]string{"a1": "Cowboy Bebop", "a2": "Trigun", "a3": "Monster", "a4": "Mushishi", "a5": "Planetes"}
+	newReindexer := func(opt Options, produce func(ctx context.Context, message *kafka.Message) error) (*ReindexerImpl, *fakeCheckpointRepository) {
+		var animes []anime.Anime
+		for id, title := range titles {
+			title := title
+			animes = append(animes, anime.Anime{ID: id, TitleEn: &title})
+		}
+		sort.Slice(animes, func(i, j int) bool { return animes[i].ID < animes[j].ID })
+
+		animeID := "a1"
+		checkpoints := &fakeCheckpointRepository{checkpoints: map[string]reindex_checkpoint.ReindexCheckpoint{}}
+		if opt.Checkpoint == "" {
+			opt.Checkpoint = "test"
+		}
+		return &ReindexerImpl{
+			AnimeRepository: &fakeAnimeRepository{animes: animes},
+			SeasonRepository: &fakeSeasonRepository{seasons: []anime_season.AnimeSeason{
+				{ID: "s1", Season: "SPRING_1998", Status: anime_season.StatusConfirmed, AnimeID: &animeID},
+			}},
+			AnimeTagRepository:   &fakeAnimeTagRepository{tags: map[string][]string{"a1": {"Action", "Space"}}},
+			CheckpointRepository: checkpoints,
+			TitleHistoryRepository: &fakeTitleHistoryRepository{oldTitles: map[string][]string{
+				"a1": {"Cowboy Bebop", "Kaubōi Bibappu"},
+			}},
+			Options: opt,
+			Search:  publisher.NewKafka[json.RawMessage](produce),
+		}, checkpoints
+	}
+
+	collect := func(messages *[][]byte) func(ctx context.Context, message *kafka.Message) error {
+		return func(ctx context.Context, message *kafka.Message) error {
+			*messages = append(*messages, message.Value)
+			return nil
+		}
+	}
+
+	t.Run("PublishesEveryRowWithTags", func(t *testing.T) {
+		var messages [][]byte
+		reindexer, checkpoints := newReindexer(Options{BatchSize: 2}, collect(&messages))
+		var progress []Progress
+		reindexer.Report = func(p Progress) { progress = append(progress, p) }
+
+		require.NoError(t, reindexer.Run(ctx))
+		require.Len(t, messages, 6)
+
+		var first anime_processor.ProducerPayload
+		require.NoError(t, json.Unmarshal(messages[0], &first))
+		assert.Equal(t, anime_processor.UpdateAction, first.Action)
+		assert.Equal(t, "a1", first.Data.ID)
+		assert.Equal(t, "Cowboy Bebop", *first.Data.TitleEn)
+		assert.Equal(t, []string{"Action", "Space"}, first.Data.Tags)
+		assert.Equal(t, []string{"Kaubōi Bibappu"}, first.Data.OldTitles, "the current title is not an old title")
+
+		var second anime_processor.ProducerPayload
+		require.NoError(t, json.Unmarshal(messages[1], &second))
+		assert.Empty(t, second.Data.Tags)
+
+		var season anime_season_processor.ProducerPayload
+		require.NoError(t, json.Unmarshal(messages[5], &season))
+		assert.Equal(t, "s1", season.Data.ID)
+		assert.Equal(t, "SPRING_1998", season.Data.Season)
+
+		animeCheckpoint := checkpoints.checkpoints["test/anime"]
+		assert.Equal(t, int64(5), animeCheckpoint.Published)
+		assert.Equal(t, "a5", animeCheckpoint.LastID)
+		assert.NotNil(t, animeCheckpoint.CompletedAt)
+		assert.NotNil(t, checkpoints.checkpoints["test/anime_seasons"].CompletedAt)
+
+		assert.Equal(t, []Progress{
+			{Table: TableAnime, Published: 2, Total: 5, LastID: "a2"},
+			{Table: TableAnime, Published: 4, Total: 5, LastID: "a4"},
+			{Table: TableAnime, Published: 5, Total: 5, LastID: "a5"},
+			{Table: TableAnime, Published: 5, Total: 5, LastID: "a5", Done: true},
+			{Table: TableAnimeSeasons, Published: 1, Total: 1, LastID: "s1"},
+			{Table: TableAnimeSeasons, Published: 1, Total: 1, LastID: "s1", Done: true},
+		}, progress)
+	})
+
+	t.Run("KeysDocumentsByRow", func(t *testing.T) {
+		reindexer, _ := newReindexer(Options{}, collect(&[][]byte{}))
+		search := &publisher.Memory[json.RawMessage]{}
+		reindexer.Search = search
+
+		require.NoError(t, reindexer.Run(ctx))
+		messages := search.Messages()
+		require.Len(t, messages, 6)
+		assert.Equal(t, "a1", messages[0].Key)
+		assert.Equal(t, anime_processor.EntityAnime, messages[0].Entity)
+		assert.Equal(t, anime_processor.UpdateAction, messages[0].Action)
+		assert.Equal(t, "s1", messages[5].Key)
+		assert.Equal(t, anime_season_processor.EntityAnimeSeason, messages[5].Entity)
+	})
+
+	t.Run("PassesFiltersToTheRepository", func(t *testing.T) {
+		since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		var messages [][]byte
+		reindexer, _ := newReindexer(Options{Tables: []string{TableAnime}, IDs: []string{"a1"}, Status: "Finished Airing", UpdatedSince: &since}, collect(&messages))
+
+		require.NoError(t, reindexer.Run(ctx))
+
+		filters := reindexer.AnimeRepository.(*fakeAnimeRepository).filters
+		require.NotEmpty(t, filters)
+		assert.Equal(t, anime.Filter{UpdatedSince: &since, IDs: []string{"a1"}, Status: "Finished Airing"}, filters[0])
+		assert.Len(t, messages, 5, "seasons are not reindexed when only anime is selected")
+	})
+
+	t.Run("ResumesAfterFailure", func(t *testing.T) {
+		var messages [][]byte
+		failAt := 3
+		produce := func(ctx context.Context, message *kafka.Message) error {
+			if len(messages) == failAt {
+				return errors.New("broker unavailable")
+			}
+			messages = append(messages, message.Value)
+			return nil
+		}
+		reindexer, checkpoints := newReindexer(Options{BatchSize: 2}, produce)
+
+		err := reindexer.Run(ctx)
+		require.Error(t, err)
+		assert.Equal(t, "a3", checkpoints.checkpoints["test/anime"].LastID)
+		assert.Equal(t, int64(3), checkpoints.checkpoints["test/anime"].Published)
+
+		failAt = -1
+		reindexer.Options.Resume = true
+		require.NoError(t, reindexer.Run(ctx))
+
+		var ids []string
+		for _, message := range messages {
+			var payload struct {
+				Data struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(message, &payload))
+			ids = append(ids, payload.Data.ID)
+		}
+		assert.Equal(t, []string{"a1", "a2", "a3", "a4", "a5", "s1"}, ids)
+		assert.Equal(t, int64(5), checkpoints.checkpoints["test/anime"].Published)
+	})
+
+	t.Run("ResumeSkipsFinishedTables", func(t *testing.T) {
+		var messages [][]byte
+		reindexer, checkpoints := newReindexer(Options{Resume: true}, collect(&messages))
+		completedAt := time.Now()
+		checkpoints.checkpoints["test/anime"] = reindex_checkpoint.ReindexCheckpoint{Name: "test", Table: TableAnime, LastID: "a5", Published: 5, CompletedAt: &completedAt}
+
+		require.NoError(t, reindexer.Run(ctx))
+		assert.Len(t, messages, 1)
+	})
+
+	t.Run("WithoutResumeStartsOver", func(t *testing.T) {
+		var messages [][]byte
+		reindexer, checkpoints := newReindexer(Options{Tables: []string{TableAnime}}, collect(&messages))
+		checkpoints.checkpoints["test/anime"] = reindex_checkpoint.ReindexCheckpoint{Name: "test", Table: TableAnime, LastID: "a3", Published: 3}
+
+		require.NoError(t, reindexer.Run(ctx))
+		assert.Len(t, messages, 5)
+	})
+
+	t.Run("UnknownTable", func(t *testing.T) {
+		reindexer, _ := newReindexer(Options{Tables: []string{"episodes"}}, collect(&[][]byte{}))
+		require.Error(t, reindexer.Run(ctx))
+	})
+
+	t.Run("StopsWhenCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		var messages [][]byte
+		produce := func(ctx context.Context, message *kafka.Message) error {
+			messages = append(messages, message.Value)
+			if len(messages) == 2 {
+				cancel()
+			}
+			return nil
+		}
+		reindexer, checkpoints := newReindexer(Options{}, produce)
+
+		err := reindexer.Run(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "a2", checkpoints.checkpoints["test/anime"].LastID)
+	})
+}
@@ -0,0 +1,36 @@
+package reindex
+
+import "time"
+
+// tables a reindex can run over, in the order they are published
+const (
+	TableAnime        = "anime"
+	TableAnimeSeasons = "anime_seasons"
+)
+
+var Tables = []string{TableAnime, TableAnimeSeasons}
+
+type Options struct {
+	// Checkpoint names the run, its progress is stored under this name after every batch
+	Checkpoint string
+	// Resume continues from the stored checkpoint instead of starting over, finished tables are skipped
+	Resume bool
+	// Tables limits the run to some of Tables
+	Tables    []string
+	BatchSize int
+	// RatePerSecond caps how many documents are published per second, 0 publishes as fast as kafka accepts them
+	RatePerSecond float64
+	// UpdatedSince, IDs and Status select the anime to reindex, seasons are selected by the anime they belong to
+	UpdatedSince *time.Time
+	IDs          []string
+	Status       string
+}
+
+// Progress is reported after every published batch and once a table is done
+type Progress struct {
+	Table     string
+	Published int64
+	Total     int64
+	LastID    string
+	Done      bool
+}
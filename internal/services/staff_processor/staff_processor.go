@@ -0,0 +1,181 @@
+package staff_processor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_staff"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	NoErrorOnDelete bool
+}
+
+type StaffProcessor interface {
+	Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error)
+}
+
+type StaffProcessorImpl struct {
+	Transactor db.Transactor
+	Repository anime_staff.AnimeStaffRepositoryImpl
+	Options    Options
+	Search     publisher.Publisher[ProducerPayload]
+	Images     publisher.Publisher[anime_processor.ImagePayload]
+}
+
+func NewStaffProcessor(opt Options, db *db.DB, search publisher.Publisher[ProducerPayload], images publisher.Publisher[anime_processor.ImagePayload]) StaffProcessor {
+	return &StaffProcessorImpl{
+		Transactor: db,
+		Repository: anime_staff.NewAnimeStaffRepository(db),
+		Options:    opt,
+		Search:     search,
+		Images:     images,
+	}
+}
+
+func (p *StaffProcessorImpl) Process(ctx context.Context, data event.Event[*kafka.Message, Payload]) (event.Event[*kafka.Message, Payload], error) {
+	log := logger.FromCtx(ctx)
+
+	payload := data.Payload
+
+	log.Debug("Payload", zap.Any("payload", payload))
+
+	if payload.Before == nil && payload.After != nil {
+		// add to db
+		newStaff, err := p.parseToEntity(ctx, *payload.After)
+		if err != nil {
+			return data, err
+		}
+		err = p.save(ctx, newStaff, CreateAction, payload.After)
+		if err != nil {
+			return data, err
+		}
+	}
+
+	if payload.After == nil && payload.Before != nil {
+		// delete from db
+		oldStaff, err := p.parseToEntity(ctx, *payload.Before)
+		if err != nil {
+			return data, err
+		}
+
+		var deleteErr error
+		err = p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+			deleteErr = p.Repository.WithTx(tx).Delete(oldStaff)
+			if deleteErr != nil {
+				return deleteErr
+			}
+
+			return p.sendSearchDocument(ctx, DeleteAction, payload.Before)
+		})
+		if err != nil {
+			// only db errors are ignored, a failed algolia delete has to be retried
+			if deleteErr != nil && p.Options.NoErrorOnDelete {
+				log.Warn("WARN: error deleting from db: ", zap.Error(err))
+				return data, nil
+			} else {
+				return data, err
+			}
+		}
+		return data, nil
+	}
+
+	if payload.Before != nil && payload.After != nil {
+		// update db
+		newStaff, err := p.parseToEntity(ctx, *payload.After)
+		if err != nil {
+			return data, err
+		}
+		err = p.save(ctx, newStaff, UpdateAction, payload.After)
+		if err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+// save upserts the staff row and publishes its search document and image in one transaction
+func (p *StaffProcessorImpl) save(ctx context.Context, staff *anime_staff.AnimeStaff, action Action, data *Schema) error {
+	return p.Transactor.Transaction(ctx, func(ctx context.Context, tx *db.DB) error {
+		err := p.Repository.WithTx(tx).Upsert(staff)
+		if err != nil {
+			return err
+		}
+
+		err = p.sendSearchDocument(ctx, action, data)
+		if err != nil {
+			return err
+		}
+
+		return p.sendImage(ctx, action, *data)
+	})
+}
+
+// sendSearchDocument publishes the staff row to the algolia topic
+func (p *StaffProcessorImpl) sendSearchDocument(ctx context.Context, action Action, data *Schema) error {
+	log := logger.FromCtx(ctx)
+
+	err := p.Search.Publish(ctx, publisher.Message[ProducerPayload]{
+		Key:           data.ID,
+		Action:        action,
+		Entity:        EntityStaff,
+		SchemaVersion: SchemaVersion,
+		Value:         ProducerPayload{Action: action, Data: data},
+	})
+	if err != nil {
+		log.Error("Error publishing search document", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// sendImage forwards the staff image to the image sync topic
+func (p *StaffProcessorImpl) sendImage(ctx context.Context, action Action, data Schema) error {
+	log := logger.FromCtx(ctx)
+
+	if data.Image == nil || *data.Image == "" {
+		log.Warn("Image is nil, skipping image producer", zap.String("id", data.ID))
+		return nil
+	}
+
+	name := strings.TrimSpace(data.GivenName + " " + data.FamilyName)
+	image, _ := anime_processor.NewImagePayload(anime_processor.DataTypeStaff, data.ID, data.Image, name)
+
+	log.Info("Sending staff image to image sync", zap.String("name", image.Data.Name), zap.String("imageURL", *data.Image))
+	err := p.Images.Publish(ctx, anime_processor.ImageMessage(action, data.ID, image))
+	if err != nil {
+		log.Error("Error publishing image", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (p *StaffProcessorImpl) parseToEntity(ctx context.Context, data Schema) (*anime_staff.AnimeStaff, error) {
+	var newStaff anime_staff.AnimeStaff
+
+	newStaff.ID = data.ID
+	newStaff.GivenName = data.GivenName
+	newStaff.FamilyName = data.FamilyName
+	newStaff.Image = data.Image
+	newStaff.Birthday = data.Birthday
+	newStaff.BirthPlace = data.BirthPlace
+	newStaff.BloodType = data.BloodType
+	newStaff.Hobbies = data.Hobbies
+	newStaff.Summary = data.Summary
+	newStaff.Language = data.Language
+	newStaff.CreatedAt = time.Now()
+	newStaff.UpdatedAt = time.Now()
+
+	return &newStaff, nil
+}
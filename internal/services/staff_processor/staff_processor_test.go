@@ -0,0 +1,97 @@
+package staff_processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ThatCatDev/ep/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/weeb-vip/anime-sync/internal/db"
+	"github.com/weeb-vip/anime-sync/internal/db/repositories/anime_staff"
+	"github.com/weeb-vip/anime-sync/internal/logger"
+	"github.com/weeb-vip/anime-sync/internal/publisher"
+	"github.com/weeb-vip/anime-sync/internal/services/anime_processor"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transaction(ctx context.Context, fn func(ctx context.Context, tx *db.DB) error) error {
+	return fn(ctx, nil)
+}
+
+type fakeStaffRepository struct {
+	anime_staff.AnimeStaffRepositoryImpl
+	staff map[string]*anime_staff.AnimeStaff
+}
+
+func (r *fakeStaffRepository) Upsert(staff *anime_staff.AnimeStaff) error {
+	r.staff[staff.ID] = staff
+	return nil
+}
+
+func (r *fakeStaffRepository) Delete(staff *anime_staff.AnimeStaff) error {
+	delete(r.staff, staff.ID)
+	return nil
+}
+
+func (r *fakeStaffRepository) WithTx(tx *db.DB) anime_staff.AnimeStaffRepositoryImpl {
+	return r
+}
+
+func TestAlgoliaActions(t *testing.T) {
+	ctx := logger.WithCtx(context.Background(), zap.NewNop())
+
+	image := "https://example.com/staff.jpg"
+	before := &Schema{ID: "staff-test", GivenName: "Hayao", FamilyName: "Miyazaki", Image: &image}
+	after := &Schema{ID: "staff-test", GivenName: "Hayao", FamilyName: "Miyazaki (宮崎駿)", Image: &image}
+
+	tests := []struct {
+		name           string
+		payload        Payload
+		action         Action
+		familyName     string
+		imagesProduced int
+	}{
+		{"Create", Payload{After: before}, CreateAction, "Miyazaki", 1},
+		{"Update", Payload{Before: before, After: after}, UpdateAction, "Miyazaki (宮崎駿)", 1},
+		{"Delete", Payload{Before: before}, DeleteAction, "Miyazaki", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeStaffRepository{staff: map[string]*anime_staff.AnimeStaff{}}
+			search := &publisher.Memory[ProducerPayload]{}
+			images := &publisher.Memory[anime_processor.ImagePayload]{}
+
+			processor := &StaffProcessorImpl{
+				Transactor: fakeTransactor{},
+				Repository: repository,
+				Search:     search,
+				Images:     images,
+			}
+
+			_, err := processor.Process(ctx, event.Event[*kafka.Message, Payload]{Payload: tt.payload})
+			require.NoError(t, err)
+
+			messages := search.Messages()
+			require.Len(t, messages, 1)
+			assert.Equal(t, "staff-test", messages[0].Key)
+			assert.Equal(t, map[string]string{
+				publisher.HeaderAction:        tt.action,
+				publisher.HeaderEntity:        EntityStaff,
+				publisher.HeaderSchemaVersion: SchemaVersion,
+			}, messages[0].Headers())
+
+			produced := messages[0].Value
+			assert.Equal(t, tt.action, produced.Action)
+			require.NotNil(t, produced.Data)
+			assert.Equal(t, "staff-test", produced.Data.ID)
+			assert.Equal(t, tt.familyName, produced.Data.FamilyName)
+			assert.Len(t, images.Messages(), tt.imagesProduced)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package slug
+
+import "strings"
+
+// hiragana in modified Hepburn, katakana is mapped onto hiragana before the lookup
+var hiragana = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "wo", 'ん': "n", 'ゔ': "vu",
+}
+
+// small kana that change the vowel of the kana before them
+var smallKana = map[rune]string{
+	'ゃ': "a", 'ゅ': "u", 'ょ': "o",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "a",
+}
+
+const (
+	sokuon   = 'っ'
+	katakana = 'ァ' - 'ぁ'
+)
+
+// romaji transliterates hiragana and katakana in s and leaves every other character as it is.
+// Long vowel marks are dropped, as they are in most romanized titles
+func romaji(s string) string {
+	var b strings.Builder
+	double := false
+	// syllable is the last transliterated kana, it is kept open so small kana can change its vowel
+	syllable := ""
+	flush := func() {
+		if syllable == "" {
+			return
+		}
+		if double {
+			if strings.HasPrefix(syllable, "ch") {
+				b.WriteByte('t')
+			} else {
+				b.WriteByte(syllable[0])
+			}
+			double = false
+		}
+		b.WriteString(syllable)
+		syllable = ""
+	}
+
+	for _, r := range s {
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= katakana
+		}
+		if vowel, ok := smallKana[r]; ok {
+			syllable = combine(syllable, r, vowel)
+			continue
+		}
+		if r == sokuon {
+			flush()
+			double = true
+			continue
+		}
+		if r == 'ー' {
+			continue
+		}
+		flush()
+		if latin, ok := hiragana[r]; ok {
+			syllable = latin
+			continue
+		}
+		double = false
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// combine joins a syllable with the small kana following it: き+ゃ is kya, し+ゃ is sha, ふ+ぁ is fa
+func combine(syllable string, small rune, vowel string) string {
+	if syllable == "" || strings.IndexByte("aiueo", syllable[len(syllable)-1]) < 0 {
+		return syllable + vowel
+	}
+	base := syllable[:len(syllable)-1]
+	switch {
+	case base == "":
+		return syllable + vowel
+	case small == 'ゃ' || small == 'ゅ' || small == 'ょ':
+		if strings.HasSuffix(base, "sh") || strings.HasSuffix(base, "ch") || base == "j" {
+			return base + vowel
+		}
+		return base + "y" + vowel
+	case base == "ts":
+		return "ts" + vowel
+	default:
+		return base + vowel
+	}
+}
@@ -0,0 +1,84 @@
+package slug
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// suffixLength is how many hex characters of the id hash are appended to image names
+const suffixLength = 10
+
+// letters that do not decompose into an ASCII letter and a combining mark
+var latin = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// Make returns s as lower case ASCII words joined by underscores. Unicode is normalized first so
+// full width and accented Latin letters keep their base letter, kana is transliterated to romaji.
+// Characters of other scripts and anything that is not a letter or digit are dropped, a title
+// without any Latin or kana characters has an empty slug
+func Make(s string) string {
+	s = norm.NFKC.String(s)
+	s = romaji(s)
+	stripped, _, err := transform.String(transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err == nil {
+		s = stripped
+	}
+
+	var b strings.Builder
+	separate := false
+	for _, r := range strings.ToLower(s) {
+		if replacement, ok := latin[r]; ok {
+			if separate && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteString(replacement)
+			separate = false
+			continue
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if separate && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			separate = false
+			continue
+		}
+		separate = true
+	}
+	return b.String()
+}
+
+// ImageName returns the object name of the image of the entity with the given id: the slug of the
+// first name with a non empty slug followed by a suffix derived from the id, so entities sharing a
+// name get different images and renames of other fields never move an image. Without any usable
+// name the suffix alone is the name
+func ImageName(id string, names ...string) string {
+	suffix := Suffix(id)
+	for _, name := range names {
+		if slug := Make(name); slug != "" {
+			return slug + "-" + suffix
+		}
+	}
+	return suffix
+}
+
+// Suffix is the stable part of image names for id
+func Suffix(id string) string {
+	sum := sha1.Sum([]byte(id))
+	return hex.EncodeToString(sum[:])[:suffixLength]
+}
+
+// LegacyImageName is the name images were stored under before they were slugged: the lower cased
+// name with spaces replaced by underscores. It is sent along with the new name so the image service
+// can move stored images instead of downloading them again
+func LegacyImageName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "_")
+}
@@ -0,0 +1,66 @@
+package slug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Spaces", "Cowboy Bebop", "cowboy_bebop"},
+		{"UnsafeCharacters", "Fate/stay night: Unlimited Blade Works?", "fate_stay_night_unlimited_blade_works"},
+		{"Diacritics", "Pokémon: Mewtwo no Gyakushū", "pokemon_mewtwo_no_gyakushu"},
+		{"FullWidth", "ＳＰＹ×ＦＡＭＩＬＹ", "spy_family"},
+		{"SpecialLatin", "Straße Ærø", "strasse_aero"},
+		{"Digits", "Mob Psycho 100 II", "mob_psycho_100_ii"},
+		{"Hiragana", "となりのトトロ", "tonarinototoro"},
+		{"Digraphs", "しょうじょ きゅうしゅう", "shoujo_kyuushuu"},
+		{"Sokuon", "マッチ ぶっちゃけ", "matchi_butchake"},
+		{"LongVowel", "ラーメン", "ramen"},
+		{"ForeignSounds", "ファイト ティー", "faito_ti"},
+		{"HalfWidthKatakana", "ｶﾞﾝﾀﾞﾑ", "gandamu"},
+		{"OtherScriptsAreDropped", "進撃の巨人", "no"},
+		{"Empty", "   ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Make(tt.input))
+		})
+	}
+}
+
+func TestImageName(t *testing.T) {
+	id := "5b3c2a3e-8e4f-4d2a-9c1b-1f2e3d4c5b6a"
+
+	t.Run("SlugAndSuffix", func(t *testing.T) {
+		assert.Equal(t, "cowboy_bebop-"+Suffix(id), ImageName(id, "Cowboy Bebop"))
+		assert.Len(t, Suffix(id), suffixLength)
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		assert.Equal(t, ImageName(id, "Cowboy Bebop"), ImageName(id, "Cowboy Bebop"))
+	})
+
+	t.Run("SameTitleDifferentIDs", func(t *testing.T) {
+		assert.NotEqual(t, ImageName(id, "Hunter x Hunter"), ImageName("other", "Hunter x Hunter"))
+	})
+
+	t.Run("FallsBackToTheNextName", func(t *testing.T) {
+		assert.Equal(t, "kauboibibappu-"+Suffix(id), ImageName(id, "", "カウボーイビバップ"))
+		assert.Equal(t, "gintama-"+Suffix(id), ImageName(id, "銀魂", "gintama"))
+	})
+
+	t.Run("WithoutNames", func(t *testing.T) {
+		assert.Equal(t, Suffix(id), ImageName(id))
+		assert.Equal(t, Suffix(id), ImageName(id, "", "銀魂"))
+	})
+}
+
+func TestLegacyImageName(t *testing.T) {
+	assert.Equal(t, "fate/stay_night", LegacyImageName("Fate/stay Night"))
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// parentContextKey keeps the statement context from before the span was started, it is put back once the span ends
+const parentContextKey = "tracing:parent_context"
+
+// GormPlugin starts a client span around every GORM statement. Statements are only traced when their
+// context already carries a span, so migrations and startup queries don't produce traces of their own.
+// The SQL is recorded without its values
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("tracing:before_create", p.before("create")),
+		callbacks.Create().After("*").Register("tracing:after_create", p.after),
+		callbacks.Query().Before("*").Register("tracing:before_query", p.before("query")),
+		callbacks.Query().After("*").Register("tracing:after_query", p.after),
+		callbacks.Update().Before("*").Register("tracing:before_update", p.before("update")),
+		callbacks.Update().After("*").Register("tracing:after_update", p.after),
+		callbacks.Delete().Before("*").Register("tracing:before_delete", p.before("delete")),
+		callbacks.Delete().After("*").Register("tracing:after_delete", p.after),
+		callbacks.Row().Before("*").Register("tracing:before_row", p.before("row")),
+		callbacks.Row().After("*").Register("tracing:after_row", p.after),
+		callbacks.Raw().Before("*").Register("tracing:before_raw", p.before("raw")),
+		callbacks.Raw().After("*").Register("tracing:after_raw", p.after),
+	)
+}
+
+func (GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil || !trace.SpanContextFromContext(parent).IsValid() {
+			return
+		}
+
+		ctx, _ := Tracer().Start(parent, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperation(operation)),
+		)
+		db.InstanceSet(parentContextKey, parent)
+		db.Statement.Context = ctx
+	}
+}
+
+func (GormPlugin) after(db *gorm.DB) {
+	parent, ok := db.InstanceGet(parentContextKey)
+	if !ok {
+		return
+	}
+
+	span := trace.SpanFromContext(db.Statement.Context)
+	span.SetAttributes(
+		semconv.DBStatement(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Statement.Table != "" {
+		span.SetAttributes(semconv.DBSQLTable(db.Statement.Table))
+	}
+
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// not found is an answer, not a failure
+		err = nil
+	}
+	End(span, err)
+
+	db.Statement.Context = parent.(context.Context)
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderCarrier reads and writes trace context in the headers of a kafka message
+type HeaderCarrier struct {
+	Message *kafka.Message
+}
+
+func (c HeaderCarrier) Get(key string) string {
+	for _, header := range c.Message.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the header so a message passed on keeps a single traceparent
+func (c HeaderCarrier) Set(key string, value string) {
+	for i, header := range c.Message.Headers {
+		if header.Key == key {
+			c.Message.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.Message.Headers = append(c.Message.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.Message.Headers))
+	for _, header := range c.Message.Headers {
+		keys = append(keys, header.Key)
+	}
+	return keys
+}
+
+// Extract returns ctx carrying the trace context found in the headers of a consumed event
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// Inject writes the trace context of ctx into the headers of a consumed event, so messages
+// re-queued or dead lettered from them continue the trace
+func Inject(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// StartConsumer starts the span processing a message consumed from topic, as child of the trace
+// context in its headers
+func StartConsumer(ctx context.Context, topic string, headers map[string]string, message *kafka.Message) (context.Context, trace.Span) {
+	attributes := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationDeliver,
+			semconv.MessagingDestinationName(topic),
+		),
+	}
+	if message != nil {
+		attributes = append(attributes, trace.WithAttributes(
+			semconv.MessagingKafkaDestinationPartition(int(message.TopicPartition.Partition)),
+			semconv.MessagingKafkaMessageOffset(int(message.TopicPartition.Offset)),
+			semconv.MessagingKafkaMessageKey(string(message.Key)),
+		))
+	}
+
+	return Tracer().Start(Extract(ctx, headers), topic+" process", attributes...)
+}
+
+// StartProducer starts the span producing message to topic and injects its context into the message headers
+func StartProducer(ctx context.Context, topic string, message *kafka.Message) (context.Context, trace.Span) {
+	ctx, span := Tracer().Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingKafkaMessageKey(string(message.Key)),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Message: message})
+	return ctx, span
+}
+
+// StartBatchConsumer starts the span applying a batch of messages consumed from topic. A batch
+// carries the traces of many changes, so instead of continuing one of them the span links to each
+func StartBatchConsumer(ctx context.Context, topic string, messages []*kafka.Message) (context.Context, trace.Span) {
+	var links []trace.Link
+	for _, message := range messages {
+		linked := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), HeaderCarrier{Message: message}))
+		if linked.IsValid() {
+			links = append(links, trace.Link{SpanContext: linked})
+		}
+	}
+
+	return Tracer().Start(ctx, topic+" process batch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationDeliver,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(len(messages)),
+		),
+	)
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/weeb-vip/anime-sync/config"
+)
+
+func TestInit(t *testing.T) {
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	t.Run("NoneKeepsPassingTraceContextOn", func(t *testing.T) {
+		shutdown, err := Init(context.Background(), config.TracingConfig{Exporter: ExporterNone})
+		require.NoError(t, err)
+		defer shutdown(context.Background())
+
+		const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		ctx, span := StartConsumer(context.Background(), "anime", map[string]string{"traceparent": traceparent}, nil)
+		assert.False(t, span.IsRecording())
+
+		message := &kafka.Message{}
+		_, producerSpan := StartProducer(ctx, "algolia-sync", message)
+		producerSpan.End()
+		span.End()
+
+		assert.Equal(t, traceparent, HeaderCarrier{Message: message}.Get("traceparent"))
+	})
+
+	t.Run("Stdout", func(t *testing.T) {
+		shutdown, err := Init(context.Background(), config.TracingConfig{Exporter: ExporterStdout, ServiceName: "anime-sync", SampleRatio: 1})
+		require.NoError(t, err)
+		defer shutdown(context.Background())
+
+		_, span := Tracer().Start(context.Background(), "test")
+		assert.True(t, span.IsRecording())
+		span.End()
+	})
+
+	t.Run("UnknownExporter", func(t *testing.T) {
+		_, err := Init(context.Background(), config.TracingConfig{Exporter: "jaeger"})
+		require.Error(t, err)
+	})
+}
+
+func TestHeaderCarrier(t *testing.T) {
+	message := &kafka.Message{Headers: []kafka.Header{
+		{Key: "retry", Value: []byte("1")},
+		{Key: "traceparent", Value: []byte("old")},
+	}}
+	carrier := HeaderCarrier{Message: message}
+
+	carrier.Set("traceparent", "new")
+	carrier.Set("tracestate", "vendor=1")
+
+	assert.Equal(t, "new", carrier.Get("traceparent"))
+	assert.Equal(t, "vendor=1", carrier.Get("tracestate"))
+	assert.Equal(t, "", carrier.Get("baggage"))
+	assert.Equal(t, []string{"retry", "traceparent", "tracestate"}, carrier.Keys())
+}
+
+func TestStartConsumerWithoutTraceContext(t *testing.T) {
+	ctx, span := StartConsumer(context.Background(), "anime", map[string]string{}, &kafka.Message{})
+	defer span.End()
+
+	assert.Equal(t, span, trace.SpanFromContext(ctx))
+}
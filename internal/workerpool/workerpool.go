@@ -0,0 +1,83 @@
+package workerpool
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// queueSize is how many tasks wait per worker before Submit blocks
+const queueSize = 64
+
+// Pool runs tasks on a fixed number of workers. Tasks with the same key always run on the same
+// worker in the order they were submitted, tasks with different keys run concurrently
+type Pool struct {
+	queues  []chan func()
+	workers sync.WaitGroup
+	// tasks counts the submitted tasks that have not finished yet
+	tasks sync.WaitGroup
+}
+
+// New starts a pool of workers, less than one starts a single worker
+func New(workers int) *Pool {
+	pool := &Pool{queues: make([]chan func(), max(workers, 1))}
+	for i := range pool.queues {
+		queue := make(chan func(), queueSize)
+		pool.queues[i] = queue
+
+		pool.workers.Add(1)
+		go func() {
+			defer pool.workers.Done()
+			for task := range queue {
+				task()
+			}
+		}()
+	}
+	return pool
+}
+
+// Size returns the number of workers
+func (p *Pool) Size() int {
+	return len(p.queues)
+}
+
+// Submit queues task on the worker of key. It blocks while the queue of that worker is full and
+// gives up when ctx is done, the task is not run then
+func (p *Pool) Submit(ctx context.Context, key string, task func()) error {
+	p.tasks.Add(1)
+	run := func() {
+		defer p.tasks.Done()
+		task()
+	}
+
+	select {
+	case p.queues[p.worker(key)] <- run:
+		return nil
+	case <-ctx.Done():
+		p.tasks.Done()
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until every submitted task finished. It must not be called concurrently with Submit
+func (p *Pool) Wait() {
+	p.tasks.Wait()
+}
+
+// Close runs the queued tasks and stops the workers, the pool cannot be used afterwards
+func (p *Pool) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.workers.Wait()
+}
+
+func (p *Pool) worker(key string) int {
+	if len(p.queues) == 1 {
+		return 0
+	}
+	hash := fnv.New32a()
+	//nolint:errcheck
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(len(p.queues)))
+}
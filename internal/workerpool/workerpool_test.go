@@ -0,0 +1,83 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("KeepsOrderPerKey", func(t *testing.T) {
+		pool := New(4)
+		defer pool.Close()
+
+		var mu sync.Mutex
+		handled := map[string][]int{}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("anime-%d", i%5)
+			i := i
+			require.NoError(t, pool.Submit(ctx, key, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				handled[key] = append(handled[key], i)
+			}))
+		}
+		pool.Wait()
+
+		for key, order := range handled {
+			assert.IsIncreasing(t, order, key)
+			assert.Len(t, order, 20, key)
+		}
+	})
+
+	t.Run("RunsKeysConcurrently", func(t *testing.T) {
+		pool := New(4)
+		defer pool.Close()
+
+		var running, peak atomic.Int32
+		for i := 0; i < 16; i++ {
+			require.NoError(t, pool.Submit(ctx, fmt.Sprintf("anime-%d", i), func() {
+				now := running.Add(1)
+				for {
+					old := peak.Load()
+					if now <= old || peak.CompareAndSwap(old, now) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+			}))
+		}
+		pool.Wait()
+
+		assert.Greater(t, peak.Load(), int32(1))
+	})
+
+	t.Run("SubmitGivesUpWhenCancelled", func(t *testing.T) {
+		pool := New(1)
+		defer pool.Close()
+
+		release := make(chan struct{})
+		require.NoError(t, pool.Submit(ctx, "anime-1", func() { <-release }))
+		for i := 0; i < queueSize; i++ {
+			require.NoError(t, pool.Submit(ctx, "anime-1", func() {}))
+		}
+
+		cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		ran := false
+		assert.ErrorIs(t, pool.Submit(cancelled, "anime-1", func() { ran = true }), context.DeadlineExceeded)
+
+		close(release)
+		pool.Wait()
+		assert.False(t, ran)
+	})
+}